@@ -41,6 +41,17 @@ codegen: api/chat-server.proto
 	docker cp protobuf:/api/gen api
 	docker stop protobuf
 
+# 在临时的 MySQL 容器上运行带 mysql 标签的测试
+.PHONY: test-mysql
+test-mysql:
+	if [ ! -z "$(shell docker ps --filter name=chat-mysql-test -q)" ]; then \
+		docker stop chat-mysql-test; \
+	fi
+	docker run --name chat-mysql-test -d --rm -e MYSQL_ALLOW_EMPTY_PASSWORD=yes -p 13306:3306 docker.io/library/mysql:8.0
+	until docker exec chat-mysql-test mysqladmin ping -h127.0.0.1 --silent; do sleep 1; done
+	CHAT_TEST_MYSQL_ADDRESS=127.0.0.1:13306 go test -tags mysql -count=1 ./internal/storage/; \
+		rc=$$?; docker stop chat-mysql-test; exit $$rc
+
 .PHONY: image
 image:
 	docker build --build-arg version="$${BUILD_VERSION}" --tag=${REGISTRY_ENDPOINT}/${REGISTRY_NAMESPACE}/${NAME}:${VERSION} .
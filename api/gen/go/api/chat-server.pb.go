@@ -23,22 +23,46 @@ const (
 type ClientMessage_Type int32
 
 const (
-	ClientMessage_CMTErr  ClientMessage_Type = 0
-	ClientMessage_CMTAuth ClientMessage_Type = 1
-	ClientMessage_CMTChat ClientMessage_Type = 2
+	ClientMessage_CMTErr      ClientMessage_Type = 0
+	ClientMessage_CMTAuth     ClientMessage_Type = 1
+	ClientMessage_CMTChat     ClientMessage_Type = 2
+	ClientMessage_CMTRoom     ClientMessage_Type = 3
+	ClientMessage_CMTPresence ClientMessage_Type = 4
+	ClientMessage_CMTRead     ClientMessage_Type = 5
+	ClientMessage_CMTTyping   ClientMessage_Type = 6
+	ClientMessage_CMTEdit     ClientMessage_Type = 7
+	ClientMessage_CMTDelete   ClientMessage_Type = 8
+	ClientMessage_CMTReact    ClientMessage_Type = 9
+	ClientMessage_CMTThread   ClientMessage_Type = 10
 )
 
 // Enum value maps for ClientMessage_Type.
 var (
 	ClientMessage_Type_name = map[int32]string{
-		0: "CMTErr",
-		1: "CMTAuth",
-		2: "CMTChat",
+		0:  "CMTErr",
+		1:  "CMTAuth",
+		2:  "CMTChat",
+		3:  "CMTRoom",
+		4:  "CMTPresence",
+		5:  "CMTRead",
+		6:  "CMTTyping",
+		7:  "CMTEdit",
+		8:  "CMTDelete",
+		9:  "CMTReact",
+		10: "CMTThread",
 	}
 	ClientMessage_Type_value = map[string]int32{
-		"CMTErr":  0,
-		"CMTAuth": 1,
-		"CMTChat": 2,
+		"CMTErr":      0,
+		"CMTAuth":     1,
+		"CMTChat":     2,
+		"CMTRoom":     3,
+		"CMTPresence": 4,
+		"CMTRead":     5,
+		"CMTTyping":   6,
+		"CMTEdit":     7,
+		"CMTDelete":   8,
+		"CMTReact":    9,
+		"CMTThread":   10,
 	}
 )
 
@@ -66,28 +90,119 @@ func (x ClientMessage_Type) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ClientMessage_Type.Descriptor instead.
 func (ClientMessage_Type) EnumDescriptor() ([]byte, []int) {
-	return file_api_chat_server_proto_rawDescGZIP(), []int{0, 0}
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 0}
+}
+
+type ClientMessage_Room_Op int32
+
+const (
+	ClientMessage_Room_Create ClientMessage_Room_Op = 0
+	ClientMessage_Room_Join   ClientMessage_Room_Op = 1
+	ClientMessage_Room_Leave  ClientMessage_Room_Op = 2
+	ClientMessage_Room_List   ClientMessage_Room_Op = 3
+)
+
+// Enum value maps for ClientMessage_Room_Op.
+var (
+	ClientMessage_Room_Op_name = map[int32]string{
+		0: "Create",
+		1: "Join",
+		2: "Leave",
+		3: "List",
+	}
+	ClientMessage_Room_Op_value = map[string]int32{
+		"Create": 0,
+		"Join":   1,
+		"Leave":  2,
+		"List":   3,
+	}
+)
+
+func (x ClientMessage_Room_Op) Enum() *ClientMessage_Room_Op {
+	p := new(ClientMessage_Room_Op)
+	*p = x
+	return p
+}
+
+func (x ClientMessage_Room_Op) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ClientMessage_Room_Op) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_chat_server_proto_enumTypes[1].Descriptor()
+}
+
+func (ClientMessage_Room_Op) Type() protoreflect.EnumType {
+	return &file_api_chat_server_proto_enumTypes[1]
+}
+
+func (x ClientMessage_Room_Op) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ClientMessage_Room_Op.Descriptor instead.
+func (ClientMessage_Room_Op) EnumDescriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 3, 0}
 }
 
 type ServerMessage_Type int32
 
 const (
-	ServerMessage_SMTErr  ServerMessage_Type = 0
-	ServerMessage_SMTAuth ServerMessage_Type = 1
-	ServerMessage_SMTChat ServerMessage_Type = 2
+	ServerMessage_SMTErr      ServerMessage_Type = 0
+	ServerMessage_SMTAuth     ServerMessage_Type = 1
+	ServerMessage_SMTChat     ServerMessage_Type = 2
+	ServerMessage_SMTRoom     ServerMessage_Type = 3
+	ServerMessage_SMTPresence ServerMessage_Type = 4
+	ServerMessage_SMTShutdown ServerMessage_Type = 5
+	ServerMessage_SMTAck      ServerMessage_Type = 6
+	ServerMessage_SMTRead     ServerMessage_Type = 7
+	ServerMessage_SMTUnread   ServerMessage_Type = 8
+	ServerMessage_SMTTyping   ServerMessage_Type = 9
+	// 消息被修改，chat 是修改后的完整消息，序号属于接收方的信箱或者房间
+	// 修改和删除只通知在线会话，补发历史消息时返回修改后的内容
+	ServerMessage_SMTEdit   ServerMessage_Type = 10
+	ServerMessage_SMTDelete ServerMessage_Type = 11
+	ServerMessage_SMTReact  ServerMessage_Type = 12
+	ServerMessage_SMTThread ServerMessage_Type = 13
+	// 投递回执，接收方的客户端收到私聊消息后发给发送方，包括上线时收到的离线消息
+	ServerMessage_SMTDelivery ServerMessage_Type = 14
 )
 
 // Enum value maps for ServerMessage_Type.
 var (
 	ServerMessage_Type_name = map[int32]string{
-		0: "SMTErr",
-		1: "SMTAuth",
-		2: "SMTChat",
+		0:  "SMTErr",
+		1:  "SMTAuth",
+		2:  "SMTChat",
+		3:  "SMTRoom",
+		4:  "SMTPresence",
+		5:  "SMTShutdown",
+		6:  "SMTAck",
+		7:  "SMTRead",
+		8:  "SMTUnread",
+		9:  "SMTTyping",
+		10: "SMTEdit",
+		11: "SMTDelete",
+		12: "SMTReact",
+		13: "SMTThread",
+		14: "SMTDelivery",
 	}
 	ServerMessage_Type_value = map[string]int32{
-		"SMTErr":  0,
-		"SMTAuth": 1,
-		"SMTChat": 2,
+		"SMTErr":      0,
+		"SMTAuth":     1,
+		"SMTChat":     2,
+		"SMTRoom":     3,
+		"SMTPresence": 4,
+		"SMTShutdown": 5,
+		"SMTAck":      6,
+		"SMTRead":     7,
+		"SMTUnread":   8,
+		"SMTTyping":   9,
+		"SMTEdit":     10,
+		"SMTDelete":   11,
+		"SMTReact":    12,
+		"SMTThread":   13,
+		"SMTDelivery": 14,
 	}
 )
 
@@ -102,11 +217,11 @@ func (x ServerMessage_Type) String() string {
 }
 
 func (ServerMessage_Type) Descriptor() protoreflect.EnumDescriptor {
-	return file_api_chat_server_proto_enumTypes[1].Descriptor()
+	return file_api_chat_server_proto_enumTypes[2].Descriptor()
 }
 
 func (ServerMessage_Type) Type() protoreflect.EnumType {
-	return &file_api_chat_server_proto_enumTypes[1]
+	return &file_api_chat_server_proto_enumTypes[2]
 }
 
 func (x ServerMessage_Type) Number() protoreflect.EnumNumber {
@@ -115,7 +230,7 @@ func (x ServerMessage_Type) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ServerMessage_Type.Descriptor instead.
 func (ServerMessage_Type) EnumDescriptor() ([]byte, []int) {
-	return file_api_chat_server_proto_rawDescGZIP(), []int{1, 0}
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 0}
 }
 
 type ServerMessage_Err_Code int32
@@ -124,6 +239,13 @@ const (
 	ServerMessage_Err_ProtocolMismatch ServerMessage_Err_Code = 0
 	ServerMessage_Err_AuthFailed       ServerMessage_Err_Code = 1
 	ServerMessage_Err_PersonNotFound   ServerMessage_Err_Code = 2
+	ServerMessage_Err_RoomNotFound     ServerMessage_Err_Code = 3
+	ServerMessage_Err_RoomExists       ServerMessage_Err_Code = 4
+	ServerMessage_Err_NotRoomMember    ServerMessage_Err_Code = 5
+	ServerMessage_Err_UserExists       ServerMessage_Err_Code = 6
+	ServerMessage_Err_Internal         ServerMessage_Err_Code = 7
+	ServerMessage_Err_MessageNotFound  ServerMessage_Err_Code = 8
+	ServerMessage_Err_NotMessageSender ServerMessage_Err_Code = 9
 )
 
 // Enum value maps for ServerMessage_Err_Code.
@@ -132,11 +254,25 @@ var (
 		0: "ProtocolMismatch",
 		1: "AuthFailed",
 		2: "PersonNotFound",
+		3: "RoomNotFound",
+		4: "RoomExists",
+		5: "NotRoomMember",
+		6: "UserExists",
+		7: "Internal",
+		8: "MessageNotFound",
+		9: "NotMessageSender",
 	}
 	ServerMessage_Err_Code_value = map[string]int32{
 		"ProtocolMismatch": 0,
 		"AuthFailed":       1,
 		"PersonNotFound":   2,
+		"RoomNotFound":     3,
+		"RoomExists":       4,
+		"NotRoomMember":    5,
+		"UserExists":       6,
+		"Internal":         7,
+		"MessageNotFound":  8,
+		"NotMessageSender": 9,
 	}
 )
 
@@ -151,11 +287,11 @@ func (x ServerMessage_Err_Code) String() string {
 }
 
 func (ServerMessage_Err_Code) Descriptor() protoreflect.EnumDescriptor {
-	return file_api_chat_server_proto_enumTypes[2].Descriptor()
+	return file_api_chat_server_proto_enumTypes[3].Descriptor()
 }
 
 func (ServerMessage_Err_Code) Type() protoreflect.EnumType {
-	return &file_api_chat_server_proto_enumTypes[2]
+	return &file_api_chat_server_proto_enumTypes[3]
 }
 
 func (x ServerMessage_Err_Code) Number() protoreflect.EnumNumber {
@@ -164,22 +300,176 @@ func (x ServerMessage_Err_Code) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ServerMessage_Err_Code.Descriptor instead.
 func (ServerMessage_Err_Code) EnumDescriptor() ([]byte, []int) {
-	return file_api_chat_server_proto_rawDescGZIP(), []int{1, 0, 0}
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 0, 0}
 }
 
-type ClientMessage struct {
+type ServerMessage_Presence_Status int32
+
+const (
+	ServerMessage_Presence_Offline ServerMessage_Presence_Status = 0
+	ServerMessage_Presence_Online  ServerMessage_Presence_Status = 1
+	ServerMessage_Presence_Away    ServerMessage_Presence_Status = 2
+)
+
+// Enum value maps for ServerMessage_Presence_Status.
+var (
+	ServerMessage_Presence_Status_name = map[int32]string{
+		0: "Offline",
+		1: "Online",
+		2: "Away",
+	}
+	ServerMessage_Presence_Status_value = map[string]int32{
+		"Offline": 0,
+		"Online":  1,
+		"Away":    2,
+	}
+)
+
+func (x ServerMessage_Presence_Status) Enum() *ServerMessage_Presence_Status {
+	p := new(ServerMessage_Presence_Status)
+	*p = x
+	return p
+}
+
+func (x ServerMessage_Presence_Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServerMessage_Presence_Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_chat_server_proto_enumTypes[4].Descriptor()
+}
+
+func (ServerMessage_Presence_Status) Type() protoreflect.EnumType {
+	return &file_api_chat_server_proto_enumTypes[4]
+}
+
+func (x ServerMessage_Presence_Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServerMessage_Presence_Status.Descriptor instead.
+func (ServerMessage_Presence_Status) EnumDescriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 6, 0}
+}
+
+type ServerMessage_Ack_Status int32
+
+const (
+	// 已保存，接收方不在线，进入离线队列，上线后投递并发送投递回执
+	ServerMessage_Ack_Stored ServerMessage_Ack_Status = 0
+	// 已保存并且放进了接收方的发送队列，客户端收到后同样有投递回执
+	ServerMessage_Ack_Delivered ServerMessage_Ack_Status = 1
+	ServerMessage_Ack_Failed    ServerMessage_Ack_Status = 2
+)
+
+// Enum value maps for ServerMessage_Ack_Status.
+var (
+	ServerMessage_Ack_Status_name = map[int32]string{
+		0: "Stored",
+		1: "Delivered",
+		2: "Failed",
+	}
+	ServerMessage_Ack_Status_value = map[string]int32{
+		"Stored":    0,
+		"Delivered": 1,
+		"Failed":    2,
+	}
+)
+
+func (x ServerMessage_Ack_Status) Enum() *ServerMessage_Ack_Status {
+	p := new(ServerMessage_Ack_Status)
+	*p = x
+	return p
+}
+
+func (x ServerMessage_Ack_Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServerMessage_Ack_Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_chat_server_proto_enumTypes[5].Descriptor()
+}
+
+func (ServerMessage_Ack_Status) Type() protoreflect.EnumType {
+	return &file_api_chat_server_proto_enumTypes[5]
+}
+
+func (x ServerMessage_Ack_Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServerMessage_Ack_Status.Descriptor instead.
+func (ServerMessage_Ack_Status) EnumDescriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 8, 0}
+}
+
+type ClusterEnvelope_Type int32
+
+const (
+	// 发给 username 在对方节点上的所有会话
+	ClusterEnvelope_CETDeliver ClusterEnvelope_Type = 0
+	// 发送方节点上在线的用户
+	ClusterEnvelope_CETSessions ClusterEnvelope_Type = 1
+	// token 已经注销，断开 username 在对方节点上使用这组 token 登录的会话
+	ClusterEnvelope_CETRevoke ClusterEnvelope_Type = 2
+)
+
+// Enum value maps for ClusterEnvelope_Type.
+var (
+	ClusterEnvelope_Type_name = map[int32]string{
+		0: "CETDeliver",
+		1: "CETSessions",
+		2: "CETRevoke",
+	}
+	ClusterEnvelope_Type_value = map[string]int32{
+		"CETDeliver":  0,
+		"CETSessions": 1,
+		"CETRevoke":   2,
+	}
+)
+
+func (x ClusterEnvelope_Type) Enum() *ClusterEnvelope_Type {
+	p := new(ClusterEnvelope_Type)
+	*p = x
+	return p
+}
+
+func (x ClusterEnvelope_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ClusterEnvelope_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_chat_server_proto_enumTypes[6].Descriptor()
+}
+
+func (ClusterEnvelope_Type) Type() protoreflect.EnumType {
+	return &file_api_chat_server_proto_enumTypes[6]
+}
+
+func (x ClusterEnvelope_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ClusterEnvelope_Type.Descriptor instead.
+func (ClusterEnvelope_Type) EnumDescriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{10, 0}
+}
+
+// Chat 通过 metadata authorization: Bearer <accessToken> 携带 token，此时 Auth 不需要密码
+type Token struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Type ClientMessage_Type  `protobuf:"varint,1,opt,name=type,proto3,enum=api.ClientMessage_Type" json:"type,omitempty"`
-	Err  *ClientMessage_Err  `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
-	Auth *ClientMessage_Auth `protobuf:"bytes,3,opt,name=auth,proto3" json:"auth,omitempty"`
-	Chat *ClientMessage_Chat `protobuf:"bytes,4,opt,name=chat,proto3" json:"chat,omitempty"`
+	AccessToken string `protobuf:"bytes,1,opt,name=accessToken,proto3" json:"accessToken,omitempty"`
+	// unix 毫秒时间戳
+	AccessTokenExpiresAt  int64  `protobuf:"varint,2,opt,name=accessTokenExpiresAt,proto3" json:"accessTokenExpiresAt,omitempty"`
+	RefreshToken          string `protobuf:"bytes,3,opt,name=refreshToken,proto3" json:"refreshToken,omitempty"`
+	RefreshTokenExpiresAt int64  `protobuf:"varint,4,opt,name=refreshTokenExpiresAt,proto3" json:"refreshTokenExpiresAt,omitempty"`
 }
 
-func (x *ClientMessage) Reset() {
-	*x = ClientMessage{}
+func (x *Token) Reset() {
+	*x = Token{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_api_chat_server_proto_msgTypes[0]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -187,13 +477,13 @@ func (x *ClientMessage) Reset() {
 	}
 }
 
-func (x *ClientMessage) String() string {
+func (x *Token) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientMessage) ProtoMessage() {}
+func (*Token) ProtoMessage() {}
 
-func (x *ClientMessage) ProtoReflect() protoreflect.Message {
+func (x *Token) ProtoReflect() protoreflect.Message {
 	mi := &file_api_chat_server_proto_msgTypes[0]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -205,51 +495,49 @@ func (x *ClientMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientMessage.ProtoReflect.Descriptor instead.
-func (*ClientMessage) Descriptor() ([]byte, []int) {
+// Deprecated: Use Token.ProtoReflect.Descriptor instead.
+func (*Token) Descriptor() ([]byte, []int) {
 	return file_api_chat_server_proto_rawDescGZIP(), []int{0}
 }
 
-func (x *ClientMessage) GetType() ClientMessage_Type {
+func (x *Token) GetAccessToken() string {
 	if x != nil {
-		return x.Type
+		return x.AccessToken
 	}
-	return ClientMessage_CMTErr
+	return ""
 }
 
-func (x *ClientMessage) GetErr() *ClientMessage_Err {
+func (x *Token) GetAccessTokenExpiresAt() int64 {
 	if x != nil {
-		return x.Err
+		return x.AccessTokenExpiresAt
 	}
-	return nil
+	return 0
 }
 
-func (x *ClientMessage) GetAuth() *ClientMessage_Auth {
+func (x *Token) GetRefreshToken() string {
 	if x != nil {
-		return x.Auth
+		return x.RefreshToken
 	}
-	return nil
+	return ""
 }
 
-func (x *ClientMessage) GetChat() *ClientMessage_Chat {
+func (x *Token) GetRefreshTokenExpiresAt() int64 {
 	if x != nil {
-		return x.Chat
+		return x.RefreshTokenExpiresAt
 	}
-	return nil
+	return 0
 }
 
-type ServerMessage struct {
+type RefreshTokenReq struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Type ServerMessage_Type  `protobuf:"varint,1,opt,name=type,proto3,enum=api.ServerMessage_Type" json:"type,omitempty"`
-	Err  *ServerMessage_Err  `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
-	Chat *ServerMessage_Chat `protobuf:"bytes,3,opt,name=chat,proto3" json:"chat,omitempty"`
+	RefreshToken string `protobuf:"bytes,1,opt,name=refreshToken,proto3" json:"refreshToken,omitempty"`
 }
 
-func (x *ServerMessage) Reset() {
-	*x = ServerMessage{}
+func (x *RefreshTokenReq) Reset() {
+	*x = RefreshTokenReq{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_api_chat_server_proto_msgTypes[1]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -257,13 +545,13 @@ func (x *ServerMessage) Reset() {
 	}
 }
 
-func (x *ServerMessage) String() string {
+func (x *RefreshTokenReq) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerMessage) ProtoMessage() {}
+func (*RefreshTokenReq) ProtoMessage() {}
 
-func (x *ServerMessage) ProtoReflect() protoreflect.Message {
+func (x *RefreshTokenReq) ProtoReflect() protoreflect.Message {
 	mi := &file_api_chat_server_proto_msgTypes[1]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -275,43 +563,29 @@ func (x *ServerMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerMessage.ProtoReflect.Descriptor instead.
-func (*ServerMessage) Descriptor() ([]byte, []int) {
+// Deprecated: Use RefreshTokenReq.ProtoReflect.Descriptor instead.
+func (*RefreshTokenReq) Descriptor() ([]byte, []int) {
 	return file_api_chat_server_proto_rawDescGZIP(), []int{1}
 }
 
-func (x *ServerMessage) GetType() ServerMessage_Type {
-	if x != nil {
-		return x.Type
-	}
-	return ServerMessage_SMTErr
-}
-
-func (x *ServerMessage) GetErr() *ServerMessage_Err {
-	if x != nil {
-		return x.Err
-	}
-	return nil
-}
-
-func (x *ServerMessage) GetChat() *ServerMessage_Chat {
+func (x *RefreshTokenReq) GetRefreshToken() string {
 	if x != nil {
-		return x.Chat
+		return x.RefreshToken
 	}
-	return nil
+	return ""
 }
 
-type ClientMessage_Err struct {
+type RevokeTokenReq struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Code    string `protobuf:"bytes,1,opt,name=Code,proto3" json:"Code,omitempty"`
-	Message string `protobuf:"bytes,2,opt,name=Message,proto3" json:"Message,omitempty"`
+	// access token 或者 refresh token 都可以
+	Token string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
 }
 
-func (x *ClientMessage_Err) Reset() {
-	*x = ClientMessage_Err{}
+func (x *RevokeTokenReq) Reset() {
+	*x = RevokeTokenReq{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_api_chat_server_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -319,13 +593,13 @@ func (x *ClientMessage_Err) Reset() {
 	}
 }
 
-func (x *ClientMessage_Err) String() string {
+func (x *RevokeTokenReq) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientMessage_Err) ProtoMessage() {}
+func (*RevokeTokenReq) ProtoMessage() {}
 
-func (x *ClientMessage_Err) ProtoReflect() protoreflect.Message {
+func (x *RevokeTokenReq) ProtoReflect() protoreflect.Message {
 	mi := &file_api_chat_server_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -337,35 +611,26 @@ func (x *ClientMessage_Err) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientMessage_Err.ProtoReflect.Descriptor instead.
-func (*ClientMessage_Err) Descriptor() ([]byte, []int) {
-	return file_api_chat_server_proto_rawDescGZIP(), []int{0, 0}
-}
-
-func (x *ClientMessage_Err) GetCode() string {
-	if x != nil {
-		return x.Code
-	}
-	return ""
+// Deprecated: Use RevokeTokenReq.ProtoReflect.Descriptor instead.
+func (*RevokeTokenReq) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *ClientMessage_Err) GetMessage() string {
+func (x *RevokeTokenReq) GetToken() string {
 	if x != nil {
-		return x.Message
+		return x.Token
 	}
 	return ""
 }
 
-type ClientMessage_Auth struct {
+type RevokeTokenRes struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
-
-	Username string `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
 }
 
-func (x *ClientMessage_Auth) Reset() {
-	*x = ClientMessage_Auth{}
+func (x *RevokeTokenRes) Reset() {
+	*x = RevokeTokenRes{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_api_chat_server_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -373,13 +638,13 @@ func (x *ClientMessage_Auth) Reset() {
 	}
 }
 
-func (x *ClientMessage_Auth) String() string {
+func (x *RevokeTokenRes) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientMessage_Auth) ProtoMessage() {}
+func (*RevokeTokenRes) ProtoMessage() {}
 
-func (x *ClientMessage_Auth) ProtoReflect() protoreflect.Message {
+func (x *RevokeTokenRes) ProtoReflect() protoreflect.Message {
 	mi := &file_api_chat_server_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -391,29 +656,28 @@ func (x *ClientMessage_Auth) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientMessage_Auth.ProtoReflect.Descriptor instead.
-func (*ClientMessage_Auth) Descriptor() ([]byte, []int) {
-	return file_api_chat_server_proto_rawDescGZIP(), []int{0, 1}
-}
-
-func (x *ClientMessage_Auth) GetUsername() string {
-	if x != nil {
-		return x.Username
-	}
-	return ""
+// Deprecated: Use RevokeTokenRes.ProtoReflect.Descriptor instead.
+func (*RevokeTokenRes) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{3}
 }
 
-type ClientMessage_Chat struct {
+// GetHistoryReq peer 和 room 二选一。before 和 after 是会话中的序号，即 Chat 的 conversationSeq
+// 有 before 或者都为 0 时返回之前的最后 limit 条，否则返回 after 之后的前 limit 条
+type GetHistoryReq struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	To      string `protobuf:"bytes,1,opt,name=to,proto3" json:"to,omitempty"`
-	Content string `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Peer   string `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	Room   string `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Before int64  `protobuf:"varint,3,opt,name=before,proto3" json:"before,omitempty"`
+	After  int64  `protobuf:"varint,4,opt,name=after,proto3" json:"after,omitempty"`
+	// 为 0 时使用服务端默认值，超过上限时取上限
+	Limit int32 `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
 }
 
-func (x *ClientMessage_Chat) Reset() {
-	*x = ClientMessage_Chat{}
+func (x *GetHistoryReq) Reset() {
+	*x = GetHistoryReq{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_api_chat_server_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -421,13 +685,13 @@ func (x *ClientMessage_Chat) Reset() {
 	}
 }
 
-func (x *ClientMessage_Chat) String() string {
+func (x *GetHistoryReq) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientMessage_Chat) ProtoMessage() {}
+func (*GetHistoryReq) ProtoMessage() {}
 
-func (x *ClientMessage_Chat) ProtoReflect() protoreflect.Message {
+func (x *GetHistoryReq) ProtoReflect() protoreflect.Message {
 	mi := &file_api_chat_server_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -439,36 +703,59 @@ func (x *ClientMessage_Chat) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientMessage_Chat.ProtoReflect.Descriptor instead.
-func (*ClientMessage_Chat) Descriptor() ([]byte, []int) {
-	return file_api_chat_server_proto_rawDescGZIP(), []int{0, 2}
+// Deprecated: Use GetHistoryReq.ProtoReflect.Descriptor instead.
+func (*GetHistoryReq) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *ClientMessage_Chat) GetTo() string {
+func (x *GetHistoryReq) GetPeer() string {
 	if x != nil {
-		return x.To
+		return x.Peer
 	}
 	return ""
 }
 
-func (x *ClientMessage_Chat) GetContent() string {
+func (x *GetHistoryReq) GetRoom() string {
 	if x != nil {
-		return x.Content
+		return x.Room
 	}
 	return ""
 }
 
-type ServerMessage_Err struct {
+func (x *GetHistoryReq) GetBefore() int64 {
+	if x != nil {
+		return x.Before
+	}
+	return 0
+}
+
+func (x *GetHistoryReq) GetAfter() int64 {
+	if x != nil {
+		return x.After
+	}
+	return 0
+}
+
+func (x *GetHistoryReq) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetHistoryRes struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Code    ServerMessage_Err_Code `protobuf:"varint,1,opt,name=code,proto3,enum=api.ServerMessage_Err_Code" json:"code,omitempty"`
-	Message string                 `protobuf:"bytes,2,opt,name=Message,proto3" json:"Message,omitempty"`
+	// 按序号升序
+	Messages []*ServerMessage_Chat `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	// 查询方向上还有更多消息
+	HasMore bool `protobuf:"varint,2,opt,name=hasMore,proto3" json:"hasMore,omitempty"`
 }
 
-func (x *ServerMessage_Err) Reset() {
-	*x = ServerMessage_Err{}
+func (x *GetHistoryRes) Reset() {
+	*x = GetHistoryRes{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_api_chat_server_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -476,13 +763,13 @@ func (x *ServerMessage_Err) Reset() {
 	}
 }
 
-func (x *ServerMessage_Err) String() string {
+func (x *GetHistoryRes) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerMessage_Err) ProtoMessage() {}
+func (*GetHistoryRes) ProtoMessage() {}
 
-func (x *ServerMessage_Err) ProtoReflect() protoreflect.Message {
+func (x *GetHistoryRes) ProtoReflect() protoreflect.Message {
 	mi := &file_api_chat_server_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -494,33 +781,43 @@ func (x *ServerMessage_Err) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerMessage_Err.ProtoReflect.Descriptor instead.
-func (*ServerMessage_Err) Descriptor() ([]byte, []int) {
-	return file_api_chat_server_proto_rawDescGZIP(), []int{1, 0}
+// Deprecated: Use GetHistoryRes.ProtoReflect.Descriptor instead.
+func (*GetHistoryRes) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *ServerMessage_Err) GetCode() ServerMessage_Err_Code {
+func (x *GetHistoryRes) GetMessages() []*ServerMessage_Chat {
 	if x != nil {
-		return x.Code
+		return x.Messages
 	}
-	return ServerMessage_Err_ProtocolMismatch
+	return nil
 }
 
-func (x *ServerMessage_Err) GetMessage() string {
+func (x *GetHistoryRes) GetHasMore() bool {
 	if x != nil {
-		return x.Message
+		return x.HasMore
 	}
-	return ""
+	return false
 }
 
-type ServerMessage_Auth struct {
+type SearchMessagesReq struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	// 不能为空，空白分隔的多个词都出现才算匹配
+	Keyword string `protobuf:"bytes,1,opt,name=keyword,proto3" json:"keyword,omitempty"`
+	// 只搜索这个用户发送的消息
+	From string `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	// unix 毫秒时间戳，范围 [since, until)，0 表示不限制
+	Since int64 `protobuf:"varint,3,opt,name=since,proto3" json:"since,omitempty"`
+	Until int64 `protobuf:"varint,4,opt,name=until,proto3" json:"until,omitempty"`
+	// 为 0 时使用服务端默认值，超过上限时取上限
+	Limit int32 `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
 }
 
-func (x *ServerMessage_Auth) Reset() {
-	*x = ServerMessage_Auth{}
+func (x *SearchMessagesReq) Reset() {
+	*x = SearchMessagesReq{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_api_chat_server_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -528,13 +825,13 @@ func (x *ServerMessage_Auth) Reset() {
 	}
 }
 
-func (x *ServerMessage_Auth) String() string {
+func (x *SearchMessagesReq) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerMessage_Auth) ProtoMessage() {}
+func (*SearchMessagesReq) ProtoMessage() {}
 
-func (x *ServerMessage_Auth) ProtoReflect() protoreflect.Message {
+func (x *SearchMessagesReq) ProtoReflect() protoreflect.Message {
 	mi := &file_api_chat_server_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -546,37 +843,2183 @@ func (x *ServerMessage_Auth) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerMessage_Auth.ProtoReflect.Descriptor instead.
-func (*ServerMessage_Auth) Descriptor() ([]byte, []int) {
-	return file_api_chat_server_proto_rawDescGZIP(), []int{1, 1}
+// Deprecated: Use SearchMessagesReq.ProtoReflect.Descriptor instead.
+func (*SearchMessagesReq) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{6}
 }
 
-type ServerMessage_Chat struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	From    string `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
-	Content string `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+func (x *SearchMessagesReq) GetKeyword() string {
+	if x != nil {
+		return x.Keyword
+	}
+	return ""
 }
 
-func (x *ServerMessage_Chat) Reset() {
-	*x = ServerMessage_Chat{}
+func (x *SearchMessagesReq) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *SearchMessagesReq) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *SearchMessagesReq) GetUntil() int64 {
+	if x != nil {
+		return x.Until
+	}
+	return 0
+}
+
+func (x *SearchMessagesReq) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type SearchMessagesRes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 按时间倒序
+	Messages []*ServerMessage_Chat `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+}
+
+func (x *SearchMessagesRes) Reset() {
+	*x = SearchMessagesRes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SearchMessagesRes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchMessagesRes) ProtoMessage() {}
+
+func (x *SearchMessagesRes) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchMessagesRes.ProtoReflect.Descriptor instead.
+func (*SearchMessagesRes) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SearchMessagesRes) GetMessages() []*ServerMessage_Chat {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+type ClientMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type     ClientMessage_Type      `protobuf:"varint,1,opt,name=type,proto3,enum=api.ClientMessage_Type" json:"type,omitempty"`
+	Err      *ClientMessage_Err      `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
+	Auth     *ClientMessage_Auth     `protobuf:"bytes,3,opt,name=auth,proto3" json:"auth,omitempty"`
+	Chat     *ClientMessage_Chat     `protobuf:"bytes,4,opt,name=chat,proto3" json:"chat,omitempty"`
+	Room     *ClientMessage_Room     `protobuf:"bytes,5,opt,name=room,proto3" json:"room,omitempty"`
+	Presence *ClientMessage_Presence `protobuf:"bytes,6,opt,name=presence,proto3" json:"presence,omitempty"`
+	Read     *ClientMessage_Read     `protobuf:"bytes,7,opt,name=read,proto3" json:"read,omitempty"`
+	Typing   *ClientMessage_Typing   `protobuf:"bytes,8,opt,name=typing,proto3" json:"typing,omitempty"`
+	Edit     *ClientMessage_Edit     `protobuf:"bytes,9,opt,name=edit,proto3" json:"edit,omitempty"`
+	Delete   *ClientMessage_Delete   `protobuf:"bytes,10,opt,name=delete,proto3" json:"delete,omitempty"`
+	React    *ClientMessage_React    `protobuf:"bytes,11,opt,name=react,proto3" json:"react,omitempty"`
+	Thread   *ClientMessage_Thread   `protobuf:"bytes,12,opt,name=thread,proto3" json:"thread,omitempty"`
+}
+
+func (x *ClientMessage) Reset() {
+	*x = ClientMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage) ProtoMessage() {}
+
+func (x *ClientMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage.ProtoReflect.Descriptor instead.
+func (*ClientMessage) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ClientMessage) GetType() ClientMessage_Type {
+	if x != nil {
+		return x.Type
+	}
+	return ClientMessage_CMTErr
+}
+
+func (x *ClientMessage) GetErr() *ClientMessage_Err {
+	if x != nil {
+		return x.Err
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetAuth() *ClientMessage_Auth {
+	if x != nil {
+		return x.Auth
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetChat() *ClientMessage_Chat {
+	if x != nil {
+		return x.Chat
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetRoom() *ClientMessage_Room {
+	if x != nil {
+		return x.Room
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetPresence() *ClientMessage_Presence {
+	if x != nil {
+		return x.Presence
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetRead() *ClientMessage_Read {
+	if x != nil {
+		return x.Read
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetTyping() *ClientMessage_Typing {
+	if x != nil {
+		return x.Typing
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetEdit() *ClientMessage_Edit {
+	if x != nil {
+		return x.Edit
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetDelete() *ClientMessage_Delete {
+	if x != nil {
+		return x.Delete
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetReact() *ClientMessage_React {
+	if x != nil {
+		return x.React
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetThread() *ClientMessage_Thread {
+	if x != nil {
+		return x.Thread
+	}
+	return nil
+}
+
+type ServerMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type        ServerMessage_Type         `protobuf:"varint,1,opt,name=type,proto3,enum=api.ServerMessage_Type" json:"type,omitempty"`
+	Err         *ServerMessage_Err         `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
+	Chat        *ServerMessage_Chat        `protobuf:"bytes,3,opt,name=chat,proto3" json:"chat,omitempty"`
+	Room        *ServerMessage_Room        `protobuf:"bytes,4,opt,name=room,proto3" json:"room,omitempty"`
+	Auth        *ServerMessage_Auth        `protobuf:"bytes,5,opt,name=auth,proto3" json:"auth,omitempty"`
+	Presence    *ServerMessage_Presence    `protobuf:"bytes,6,opt,name=presence,proto3" json:"presence,omitempty"`
+	Shutdown    *ServerMessage_Shutdown    `protobuf:"bytes,7,opt,name=shutdown,proto3" json:"shutdown,omitempty"`
+	Ack         *ServerMessage_Ack         `protobuf:"bytes,8,opt,name=ack,proto3" json:"ack,omitempty"`
+	ReadReceipt *ServerMessage_ReadReceipt `protobuf:"bytes,9,opt,name=readReceipt,proto3" json:"readReceipt,omitempty"`
+	Unread      *ServerMessage_Unread      `protobuf:"bytes,10,opt,name=unread,proto3" json:"unread,omitempty"`
+	Typing      *ServerMessage_Typing      `protobuf:"bytes,11,opt,name=typing,proto3" json:"typing,omitempty"`
+	Delete      *ServerMessage_Delete      `protobuf:"bytes,12,opt,name=delete,proto3" json:"delete,omitempty"`
+	React       *ServerMessage_React       `protobuf:"bytes,13,opt,name=react,proto3" json:"react,omitempty"`
+	Thread      *ServerMessage_Thread      `protobuf:"bytes,14,opt,name=thread,proto3" json:"thread,omitempty"`
+	Delivery    *ServerMessage_Delivery    `protobuf:"bytes,15,opt,name=delivery,proto3" json:"delivery,omitempty"`
+}
+
+func (x *ServerMessage) Reset() {
+	*x = ServerMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage) ProtoMessage() {}
+
+func (x *ServerMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage.ProtoReflect.Descriptor instead.
+func (*ServerMessage) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ServerMessage) GetType() ServerMessage_Type {
+	if x != nil {
+		return x.Type
+	}
+	return ServerMessage_SMTErr
+}
+
+func (x *ServerMessage) GetErr() *ServerMessage_Err {
+	if x != nil {
+		return x.Err
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetChat() *ServerMessage_Chat {
+	if x != nil {
+		return x.Chat
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetRoom() *ServerMessage_Room {
+	if x != nil {
+		return x.Room
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetAuth() *ServerMessage_Auth {
+	if x != nil {
+		return x.Auth
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetPresence() *ServerMessage_Presence {
+	if x != nil {
+		return x.Presence
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetShutdown() *ServerMessage_Shutdown {
+	if x != nil {
+		return x.Shutdown
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetAck() *ServerMessage_Ack {
+	if x != nil {
+		return x.Ack
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetReadReceipt() *ServerMessage_ReadReceipt {
+	if x != nil {
+		return x.ReadReceipt
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetUnread() *ServerMessage_Unread {
+	if x != nil {
+		return x.Unread
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetTyping() *ServerMessage_Typing {
+	if x != nil {
+		return x.Typing
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetDelete() *ServerMessage_Delete {
+	if x != nil {
+		return x.Delete
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetReact() *ServerMessage_React {
+	if x != nil {
+		return x.React
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetThread() *ServerMessage_Thread {
+	if x != nil {
+		return x.Thread
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetDelivery() *ServerMessage_Delivery {
+	if x != nil {
+		return x.Delivery
+	}
+	return nil
+}
+
+// ClusterEnvelope 节点之间转发的消息
+type ClusterEnvelope struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type ClusterEnvelope_Type `protobuf:"varint,1,opt,name=type,proto3,enum=api.ClusterEnvelope_Type" json:"type,omitempty"`
+	// 发送方节点
+	Node     string `protobuf:"bytes,2,opt,name=node,proto3" json:"node,omitempty"`
+	Username string `protobuf:"bytes,3,opt,name=username,proto3" json:"username,omitempty"`
+	// 不为空时跳过这个会话，同步给自己的其他设备时使用
+	ExceptSession string         `protobuf:"bytes,4,opt,name=exceptSession,proto3" json:"exceptSession,omitempty"`
+	Message       *ServerMessage `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	// 用户名 -> 在线状态，full 为 true 时是全量，替换之前收到的；否则是增量，Offline 表示不再在线
+	Sessions map[string]ServerMessage_Presence_Status `protobuf:"bytes,6,rep,name=sessions,proto3" json:"sessions,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3,enum=api.ServerMessage_Presence_Status"`
+	Full     bool                                     `protobuf:"varint,7,opt,name=full,proto3" json:"full,omitempty"`
+	// CETRevoke 时注销的 token 会话 ID
+	TokenId string `protobuf:"bytes,8,opt,name=tokenId,proto3" json:"tokenId,omitempty"`
+}
+
+func (x *ClusterEnvelope) Reset() {
+	*x = ClusterEnvelope{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClusterEnvelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterEnvelope) ProtoMessage() {}
+
+func (x *ClusterEnvelope) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterEnvelope.ProtoReflect.Descriptor instead.
+func (*ClusterEnvelope) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ClusterEnvelope) GetType() ClusterEnvelope_Type {
+	if x != nil {
+		return x.Type
+	}
+	return ClusterEnvelope_CETDeliver
+}
+
+func (x *ClusterEnvelope) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
+func (x *ClusterEnvelope) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ClusterEnvelope) GetExceptSession() string {
+	if x != nil {
+		return x.ExceptSession
+	}
+	return ""
+}
+
+func (x *ClusterEnvelope) GetMessage() *ServerMessage {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *ClusterEnvelope) GetSessions() map[string]ServerMessage_Presence_Status {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+func (x *ClusterEnvelope) GetFull() bool {
+	if x != nil {
+		return x.Full
+	}
+	return false
+}
+
+func (x *ClusterEnvelope) GetTokenId() string {
+	if x != nil {
+		return x.TokenId
+	}
+	return ""
+}
+
+type ClusterPublishRes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// CETDeliver 时投递成功的会话数
+	Delivered int32 `protobuf:"varint,1,opt,name=delivered,proto3" json:"delivered,omitempty"`
+}
+
+func (x *ClusterPublishRes) Reset() {
+	*x = ClusterPublishRes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClusterPublishRes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterPublishRes) ProtoMessage() {}
+
+func (x *ClusterPublishRes) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterPublishRes.ProtoReflect.Descriptor instead.
+func (*ClusterPublishRes) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ClusterPublishRes) GetDelivered() int32 {
+	if x != nil {
+		return x.Delivered
+	}
+	return 0
+}
+
+type ClientMessage_Err struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code    string `protobuf:"bytes,1,opt,name=Code,proto3" json:"Code,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=Message,proto3" json:"Message,omitempty"`
+}
+
+func (x *ClientMessage_Err) Reset() {
+	*x = ClientMessage_Err{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Err) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Err) ProtoMessage() {}
+
+func (x *ClientMessage_Err) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Err.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Err) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 0}
+}
+
+func (x *ClientMessage_Err) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ClientMessage_Err) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ClientMessage_Auth struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Username string `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	// 客户端已收到的最大序号，登录时只补发之后的消息
+	LastSeq int64 `protobuf:"varint,2,opt,name=lastSeq,proto3" json:"lastSeq,omitempty"`
+	// 每个房间已收到的最大序号
+	RoomLastSeq map[string]int64 `protobuf:"bytes,3,rep,name=roomLastSeq,proto3" json:"roomLastSeq,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	Password    string           `protobuf:"bytes,4,opt,name=password,proto3" json:"password,omitempty"`
+	// 注册新用户，注册成功后直接登录
+	Register bool `protobuf:"varint,5,opt,name=register,proto3" json:"register,omitempty"`
+}
+
+func (x *ClientMessage_Auth) Reset() {
+	*x = ClientMessage_Auth{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Auth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Auth) ProtoMessage() {}
+
+func (x *ClientMessage_Auth) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Auth.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Auth) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 1}
+}
+
+func (x *ClientMessage_Auth) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ClientMessage_Auth) GetLastSeq() int64 {
+	if x != nil {
+		return x.LastSeq
+	}
+	return 0
+}
+
+func (x *ClientMessage_Auth) GetRoomLastSeq() map[string]int64 {
+	if x != nil {
+		return x.RoomLastSeq
+	}
+	return nil
+}
+
+func (x *ClientMessage_Auth) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *ClientMessage_Auth) GetRegister() bool {
+	if x != nil {
+		return x.Register
+	}
+	return false
+}
+
+type ClientMessage_Chat struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	To      string `protobuf:"bytes,1,opt,name=to,proto3" json:"to,omitempty"`
+	Content string `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	// 不为空时发送到房间，忽略 to
+	Room string `protobuf:"bytes,3,opt,name=room,proto3" json:"room,omitempty"`
+	// 客户端生成的消息 ID，服务端回复 Ack。重试时使用相同的 ID，不会重复保存
+	MsgId string `protobuf:"bytes,4,opt,name=msgId,proto3" json:"msgId,omitempty"`
+	// 回复的消息，私聊时序号属于自己的信箱，房间时属于房间。只能回复同一个会话中的消息
+	ReplyTo int64 `protobuf:"varint,5,opt,name=replyTo,proto3" json:"replyTo,omitempty"`
+	// 阅后即焚，消息保存的秒数，到期后服务端删除。0 表示不过期
+	Ttl int64 `protobuf:"varint,6,opt,name=ttl,proto3" json:"ttl,omitempty"`
+}
+
+func (x *ClientMessage_Chat) Reset() {
+	*x = ClientMessage_Chat{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Chat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Chat) ProtoMessage() {}
+
+func (x *ClientMessage_Chat) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Chat.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Chat) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 2}
+}
+
+func (x *ClientMessage_Chat) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *ClientMessage_Chat) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *ClientMessage_Chat) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ClientMessage_Chat) GetMsgId() string {
+	if x != nil {
+		return x.MsgId
+	}
+	return ""
+}
+
+func (x *ClientMessage_Chat) GetReplyTo() int64 {
+	if x != nil {
+		return x.ReplyTo
+	}
+	return 0
+}
+
+func (x *ClientMessage_Chat) GetTtl() int64 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+type ClientMessage_Room struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Op   ClientMessage_Room_Op `protobuf:"varint,1,opt,name=op,proto3,enum=api.ClientMessage_Room_Op" json:"op,omitempty"`
+	Name string                `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *ClientMessage_Room) Reset() {
+	*x = ClientMessage_Room{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Room) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Room) ProtoMessage() {}
+
+func (x *ClientMessage_Room) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Room.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Room) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 3}
+}
+
+func (x *ClientMessage_Room) GetOp() ClientMessage_Room_Op {
+	if x != nil {
+		return x.Op
+	}
+	return ClientMessage_Room_Create
+}
+
+func (x *ClientMessage_Room) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// 客户端主动设置状态，只能设置 Online 和 Away
+type ClientMessage_Presence struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status ServerMessage_Presence_Status `protobuf:"varint,1,opt,name=status,proto3,enum=api.ServerMessage_Presence_Status" json:"status,omitempty"`
+}
+
+func (x *ClientMessage_Presence) Reset() {
+	*x = ClientMessage_Presence{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Presence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Presence) ProtoMessage() {}
+
+func (x *ClientMessage_Presence) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Presence.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Presence) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 4}
+}
+
+func (x *ClientMessage_Presence) GetStatus() ServerMessage_Presence_Status {
+	if x != nil {
+		return x.Status
+	}
+	return ServerMessage_Presence_Offline
+}
+
+// 已读到 seq（含）。私聊填 peer，seq 属于自己的信箱；房间填 room，seq 属于房间
+type ClientMessage_Read struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Peer string `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	Room string `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Seq  int64  `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+}
+
+func (x *ClientMessage_Read) Reset() {
+	*x = ClientMessage_Read{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Read) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Read) ProtoMessage() {}
+
+func (x *ClientMessage_Read) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Read.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Read) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 5}
+}
+
+func (x *ClientMessage_Read) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *ClientMessage_Read) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ClientMessage_Read) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+// 正在输入，不保存。客户端输入期间需要定时重发，服务端超过一段时间没收到会自动结束
+type ClientMessage_Typing struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	To string `protobuf:"bytes,1,opt,name=to,proto3" json:"to,omitempty"`
+	// 不为空时发送给房间成员，忽略 to
+	Room   string `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Typing bool   `protobuf:"varint,3,opt,name=typing,proto3" json:"typing,omitempty"`
+}
+
+func (x *ClientMessage_Typing) Reset() {
+	*x = ClientMessage_Typing{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Typing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Typing) ProtoMessage() {}
+
+func (x *ClientMessage_Typing) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Typing.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Typing) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 6}
+}
+
+func (x *ClientMessage_Typing) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *ClientMessage_Typing) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ClientMessage_Typing) GetTyping() bool {
+	if x != nil {
+		return x.Typing
+	}
+	return false
+}
+
+// 修改自己发送的消息。私聊时 seq 属于自己的信箱；房间时填 room，seq 属于房间
+type ClientMessage_Edit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Room    string `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Seq     int64  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Content string `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+}
+
+func (x *ClientMessage_Edit) Reset() {
+	*x = ClientMessage_Edit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Edit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Edit) ProtoMessage() {}
+
+func (x *ClientMessage_Edit) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Edit.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Edit) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 7}
+}
+
+func (x *ClientMessage_Edit) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ClientMessage_Edit) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ClientMessage_Edit) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+// 删除消息，seq 和 Edit 一样。forEveryone 时只能删除自己发送的消息，所有人看到的都变成已删除；
+// 否则只对自己隐藏，之后补发历史消息时也不再返回
+type ClientMessage_Delete struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Room        string `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Seq         int64  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	ForEveryone bool   `protobuf:"varint,3,opt,name=forEveryone,proto3" json:"forEveryone,omitempty"`
+}
+
+func (x *ClientMessage_Delete) Reset() {
+	*x = ClientMessage_Delete{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Delete) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Delete) ProtoMessage() {}
+
+func (x *ClientMessage_Delete) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Delete.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Delete) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 8}
+}
+
+func (x *ClientMessage_Delete) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ClientMessage_Delete) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ClientMessage_Delete) GetForEveryone() bool {
+	if x != nil {
+		return x.ForEveryone
+	}
+	return false
+}
+
+// 给消息加上或者去掉一个表情回应，seq 和 Edit 一样
+type ClientMessage_React struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Room   string `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Seq    int64  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Emoji  string `protobuf:"bytes,3,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	Remove bool   `protobuf:"varint,4,opt,name=remove,proto3" json:"remove,omitempty"`
+}
+
+func (x *ClientMessage_React) Reset() {
+	*x = ClientMessage_React{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_React) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_React) ProtoMessage() {}
+
+func (x *ClientMessage_React) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_React.ProtoReflect.Descriptor instead.
+func (*ClientMessage_React) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 9}
+}
+
+func (x *ClientMessage_React) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ClientMessage_React) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ClientMessage_React) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+func (x *ClientMessage_React) GetRemove() bool {
+	if x != nil {
+		return x.Remove
+	}
+	return false
+}
+
+// 获取一条消息和直接回复它的消息，seq 和 Edit 一样
+type ClientMessage_Thread struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Room string `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Seq  int64  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+}
+
+func (x *ClientMessage_Thread) Reset() {
+	*x = ClientMessage_Thread{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMessage_Thread) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage_Thread) ProtoMessage() {}
+
+func (x *ClientMessage_Thread) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage_Thread.ProtoReflect.Descriptor instead.
+func (*ClientMessage_Thread) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{8, 10}
+}
+
+func (x *ClientMessage_Thread) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ClientMessage_Thread) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+type ServerMessage_Err struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code    ServerMessage_Err_Code `protobuf:"varint,1,opt,name=code,proto3,enum=api.ServerMessage_Err_Code" json:"code,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=Message,proto3" json:"Message,omitempty"`
+}
+
+func (x *ServerMessage_Err) Reset() {
+	*x = ServerMessage_Err{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Err) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Err) ProtoMessage() {}
+
+func (x *ServerMessage_Err) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Err.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Err) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 0}
+}
+
+func (x *ServerMessage_Err) GetCode() ServerMessage_Err_Code {
+	if x != nil {
+		return x.Code
+	}
+	return ServerMessage_Err_ProtocolMismatch
+}
+
+func (x *ServerMessage_Err) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ServerMessage_Auth struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 同一个用户可以同时登录多个会话
+	SessionId string `protobuf:"bytes,1,opt,name=sessionId,proto3" json:"sessionId,omitempty"`
+	// 密码登录时签发，token 登录时为空
+	Token *Token `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+}
+
+func (x *ServerMessage_Auth) Reset() {
+	*x = ServerMessage_Auth{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Auth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Auth) ProtoMessage() {}
+
+func (x *ServerMessage_Auth) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Auth.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Auth) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 1}
+}
+
+func (x *ServerMessage_Auth) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ServerMessage_Auth) GetToken() *Token {
+	if x != nil {
+		return x.Token
+	}
+	return nil
+}
+
+type ServerMessage_Chat struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	From    string `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	Content string `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Seq     int64  `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	// unix 毫秒时间戳
+	Timestamp int64 `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// 房间消息的房间名，序号属于该房间
+	Room string `protobuf:"bytes,5,opt,name=room,proto3" json:"room,omitempty"`
+	To   string `protobuf:"bytes,6,opt,name=to,proto3" json:"to,omitempty"`
+	// 发送方生成的消息 ID，发送方的其他设备可以用来和本地消息对应
+	MsgId string `protobuf:"bytes,7,opt,name=msgId,proto3" json:"msgId,omitempty"`
+	// 最后一次修改的 unix 毫秒时间戳，没有修改过为 0
+	EditedAt int64 `protobuf:"varint,8,opt,name=editedAt,proto3" json:"editedAt,omitempty"`
+	// 已经为所有人删除，content 为空
+	Deleted   bool                      `protobuf:"varint,9,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	Reactions []*ServerMessage_Reaction `protobuf:"bytes,10,rep,name=reactions,proto3" json:"reactions,omitempty"`
+	// 回复的消息的序号，属于接收方的信箱或者房间
+	ReplyTo int64 `protobuf:"varint,11,opt,name=replyTo,proto3" json:"replyTo,omitempty"`
+	// 回复的消息的当前内容，用于引用显示，不包含它自己的 parent
+	Parent *ServerMessage_Chat `protobuf:"bytes,12,opt,name=parent,proto3" json:"parent,omitempty"`
+	// 消息在会话中的序号，会话双方相同，GetHistory 翻页时使用。房间消息和 seq 相同
+	ConversationSeq int64 `protobuf:"varint,13,opt,name=conversationSeq,proto3" json:"conversationSeq,omitempty"`
+	// 发送方设置了 ttl 时的过期时间，unix 毫秒时间戳，客户端到期后也应该不再显示
+	ExpiresAt int64 `protobuf:"varint,14,opt,name=expiresAt,proto3" json:"expiresAt,omitempty"`
+}
+
+func (x *ServerMessage_Chat) Reset() {
+	*x = ServerMessage_Chat{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Chat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Chat) ProtoMessage() {}
+
+func (x *ServerMessage_Chat) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Chat.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Chat) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 2}
+}
+
+func (x *ServerMessage_Chat) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *ServerMessage_Chat) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *ServerMessage_Chat) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ServerMessage_Chat) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *ServerMessage_Chat) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ServerMessage_Chat) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *ServerMessage_Chat) GetMsgId() string {
+	if x != nil {
+		return x.MsgId
+	}
+	return ""
+}
+
+func (x *ServerMessage_Chat) GetEditedAt() int64 {
+	if x != nil {
+		return x.EditedAt
+	}
+	return 0
+}
+
+func (x *ServerMessage_Chat) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+func (x *ServerMessage_Chat) GetReactions() []*ServerMessage_Reaction {
+	if x != nil {
+		return x.Reactions
+	}
+	return nil
+}
+
+func (x *ServerMessage_Chat) GetReplyTo() int64 {
+	if x != nil {
+		return x.ReplyTo
+	}
+	return 0
+}
+
+func (x *ServerMessage_Chat) GetParent() *ServerMessage_Chat {
+	if x != nil {
+		return x.Parent
+	}
+	return nil
+}
+
+func (x *ServerMessage_Chat) GetConversationSeq() int64 {
+	if x != nil {
+		return x.ConversationSeq
+	}
+	return 0
+}
+
+func (x *ServerMessage_Chat) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+// 同一个表情的回应，按第一次回应的时间排序
+type ServerMessage_Reaction struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Emoji string   `protobuf:"bytes,1,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	Users []string `protobuf:"bytes,2,rep,name=users,proto3" json:"users,omitempty"`
+}
+
+func (x *ServerMessage_Reaction) Reset() {
+	*x = ServerMessage_Reaction{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Reaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Reaction) ProtoMessage() {}
+
+func (x *ServerMessage_Reaction) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Reaction.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Reaction) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 3}
+}
+
+func (x *ServerMessage_Reaction) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+func (x *ServerMessage_Reaction) GetUsers() []string {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+// 表情回应变化，发给会话双方或者房间成员。序号属于接收方的信箱或者房间，reactions 是变化之后的全部回应
+type ServerMessage_React struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Room      string                    `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Seq       int64                     `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	From      string                    `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`
+	Emoji     string                    `protobuf:"bytes,4,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	Remove    bool                      `protobuf:"varint,5,opt,name=remove,proto3" json:"remove,omitempty"`
+	Reactions []*ServerMessage_Reaction `protobuf:"bytes,6,rep,name=reactions,proto3" json:"reactions,omitempty"`
+}
+
+func (x *ServerMessage_React) Reset() {
+	*x = ServerMessage_React{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_React) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_React) ProtoMessage() {}
+
+func (x *ServerMessage_React) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_React.ProtoReflect.Descriptor instead.
+func (*ServerMessage_React) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 4}
+}
+
+func (x *ServerMessage_React) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ServerMessage_React) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ServerMessage_React) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *ServerMessage_React) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+func (x *ServerMessage_React) GetRemove() bool {
+	if x != nil {
+		return x.Remove
+	}
+	return false
+}
+
+func (x *ServerMessage_React) GetReactions() []*ServerMessage_Reaction {
+	if x != nil {
+		return x.Reactions
+	}
+	return nil
+}
+
+type ServerMessage_Room struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Op   ClientMessage_Room_Op `protobuf:"varint,1,opt,name=op,proto3,enum=api.ClientMessage_Room_Op" json:"op,omitempty"`
+	Name string                `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Create/Join/Leave 返回房间成员
+	Members []string `protobuf:"bytes,3,rep,name=members,proto3" json:"members,omitempty"`
+	// List 返回用户加入的房间
+	Rooms []string `protobuf:"bytes,4,rep,name=rooms,proto3" json:"rooms,omitempty"`
+}
+
+func (x *ServerMessage_Room) Reset() {
+	*x = ServerMessage_Room{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Room) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Room) ProtoMessage() {}
+
+func (x *ServerMessage_Room) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Room.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Room) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 5}
+}
+
+func (x *ServerMessage_Room) GetOp() ClientMessage_Room_Op {
+	if x != nil {
+		return x.Op
+	}
+	return ClientMessage_Room_Create
+}
+
+func (x *ServerMessage_Room) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ServerMessage_Room) GetMembers() []string {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+func (x *ServerMessage_Room) GetRooms() []string {
+	if x != nil {
+		return x.Rooms
+	}
+	return nil
+}
+
+// 联系人状态变化，联系人包括私聊过的用户和同一房间的成员
+type ServerMessage_Presence struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Username string                        `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Status   ServerMessage_Presence_Status `protobuf:"varint,2,opt,name=status,proto3,enum=api.ServerMessage_Presence_Status" json:"status,omitempty"`
+	// unix 毫秒时间戳
+	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *ServerMessage_Presence) Reset() {
+	*x = ServerMessage_Presence{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Presence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Presence) ProtoMessage() {}
+
+func (x *ServerMessage_Presence) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Presence.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Presence) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 6}
+}
+
+func (x *ServerMessage_Presence) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ServerMessage_Presence) GetStatus() ServerMessage_Presence_Status {
+	if x != nil {
+		return x.Status
+	}
+	return ServerMessage_Presence_Offline
+}
+
+func (x *ServerMessage_Presence) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+// 服务端即将关闭，之后连接会被断开，客户端稍后重连并通过 lastSeq 补发
+type ServerMessage_Shutdown struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Reason string `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (x *ServerMessage_Shutdown) Reset() {
+	*x = ServerMessage_Shutdown{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[31]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Shutdown) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Shutdown) ProtoMessage() {}
+
+func (x *ServerMessage_Shutdown) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[31]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Shutdown.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Shutdown) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 7}
+}
+
+func (x *ServerMessage_Shutdown) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// 聊天消息的处理结果
+type ServerMessage_Ack struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MsgId  string                   `protobuf:"bytes,1,opt,name=msgId,proto3" json:"msgId,omitempty"`
+	Status ServerMessage_Ack_Status `protobuf:"varint,2,opt,name=status,proto3,enum=api.ServerMessage_Ack_Status" json:"status,omitempty"`
+	// 发送方信箱或者房间中的序号，Failed 时为 0
+	Seq  int64  `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	Room string `protobuf:"bytes,4,opt,name=room,proto3" json:"room,omitempty"`
+	// Failed 的原因
+	Reason string `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (x *ServerMessage_Ack) Reset() {
+	*x = ServerMessage_Ack{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[32]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Ack) ProtoMessage() {}
+
+func (x *ServerMessage_Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[32]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Ack.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Ack) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 8}
+}
+
+func (x *ServerMessage_Ack) GetMsgId() string {
+	if x != nil {
+		return x.MsgId
+	}
+	return ""
+}
+
+func (x *ServerMessage_Ack) GetStatus() ServerMessage_Ack_Status {
+	if x != nil {
+		return x.Status
+	}
+	return ServerMessage_Ack_Stored
+}
+
+func (x *ServerMessage_Ack) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ServerMessage_Ack) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ServerMessage_Ack) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// 已读回执，发给消息的发送方和读者自己的其他设备
+type ServerMessage_ReadReceipt struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Reader string `protobuf:"bytes,1,opt,name=reader,proto3" json:"reader,omitempty"`
+	// 读者视角的会话，私聊是对方的用户名
+	Peer string `protobuf:"bytes,2,opt,name=peer,proto3" json:"peer,omitempty"`
+	Room string `protobuf:"bytes,3,opt,name=room,proto3" json:"room,omitempty"`
+	// 私聊时属于收到回执的用户的信箱，读者自己的设备是读者的信箱，发送方是发送方的信箱；房间时属于房间
+	Seq int64 `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"`
+	// 已读到的那条消息的 unix 毫秒时间戳，私聊时发送方把这个时间之前发给读者的消息标记为已读
+	Timestamp int64 `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *ServerMessage_ReadReceipt) Reset() {
+	*x = ServerMessage_ReadReceipt{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[33]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_ReadReceipt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_ReadReceipt) ProtoMessage() {}
+
+func (x *ServerMessage_ReadReceipt) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[33]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_ReadReceipt.ProtoReflect.Descriptor instead.
+func (*ServerMessage_ReadReceipt) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 9}
+}
+
+func (x *ServerMessage_ReadReceipt) GetReader() string {
+	if x != nil {
+		return x.Reader
+	}
+	return ""
+}
+
+func (x *ServerMessage_ReadReceipt) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *ServerMessage_ReadReceipt) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ServerMessage_ReadReceipt) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ServerMessage_ReadReceipt) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+// 正在输入状态变化，typing 为 false 表示停止输入，包括超时和断开连接
+type ServerMessage_Typing struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	From   string `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	Room   string `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Typing bool   `protobuf:"varint,3,opt,name=typing,proto3" json:"typing,omitempty"`
+}
+
+func (x *ServerMessage_Typing) Reset() {
+	*x = ServerMessage_Typing{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[34]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Typing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Typing) ProtoMessage() {}
+
+func (x *ServerMessage_Typing) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[34]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Typing.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Typing) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 10}
+}
+
+func (x *ServerMessage_Typing) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *ServerMessage_Typing) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ServerMessage_Typing) GetTyping() bool {
+	if x != nil {
+		return x.Typing
+	}
+	return false
+}
+
+// 消息被删除，序号属于接收方的信箱或者房间。只为自己删除时只发给自己的会话
+type ServerMessage_Delete struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Room        string `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Seq         int64  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	ForEveryone bool   `protobuf:"varint,3,opt,name=forEveryone,proto3" json:"forEveryone,omitempty"`
+}
+
+func (x *ServerMessage_Delete) Reset() {
+	*x = ServerMessage_Delete{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[35]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Delete) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Delete) ProtoMessage() {}
+
+func (x *ServerMessage_Delete) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[35]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Delete.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Delete) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 11}
+}
+
+func (x *ServerMessage_Delete) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ServerMessage_Delete) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ServerMessage_Delete) GetForEveryone() bool {
+	if x != nil {
+		return x.ForEveryone
+	}
+	return false
+}
+
+// Thread 的结果，回复按序号排序，不包括自己删除的消息
+type ServerMessage_Thread struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Parent  *ServerMessage_Chat   `protobuf:"bytes,1,opt,name=parent,proto3" json:"parent,omitempty"`
+	Replies []*ServerMessage_Chat `protobuf:"bytes,2,rep,name=replies,proto3" json:"replies,omitempty"`
+}
+
+func (x *ServerMessage_Thread) Reset() {
+	*x = ServerMessage_Thread{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_chat_server_proto_msgTypes[7]
+		mi := &file_api_chat_server_proto_msgTypes[36]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ServerMessage_Chat) String() string {
+func (x *ServerMessage_Thread) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerMessage_Chat) ProtoMessage() {}
+func (*ServerMessage_Thread) ProtoMessage() {}
 
-func (x *ServerMessage_Chat) ProtoReflect() protoreflect.Message {
-	mi := &file_api_chat_server_proto_msgTypes[7]
+func (x *ServerMessage_Thread) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[36]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -587,87 +3030,683 @@ func (x *ServerMessage_Chat) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerMessage_Chat.ProtoReflect.Descriptor instead.
-func (*ServerMessage_Chat) Descriptor() ([]byte, []int) {
-	return file_api_chat_server_proto_rawDescGZIP(), []int{1, 2}
+// Deprecated: Use ServerMessage_Thread.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Thread) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 12}
 }
 
-func (x *ServerMessage_Chat) GetFrom() string {
+func (x *ServerMessage_Thread) GetParent() *ServerMessage_Chat {
 	if x != nil {
-		return x.From
+		return x.Parent
+	}
+	return nil
+}
+
+func (x *ServerMessage_Thread) GetReplies() []*ServerMessage_Chat {
+	if x != nil {
+		return x.Replies
+	}
+	return nil
+}
+
+// 投递回执，消息已经发到了接收方的客户端
+type ServerMessage_Delivery struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 接收方
+	To    string                         `protobuf:"bytes,1,opt,name=to,proto3" json:"to,omitempty"`
+	Items []*ServerMessage_Delivery_Item `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
+	// 投递时间，unix 毫秒时间戳
+	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *ServerMessage_Delivery) Reset() {
+	*x = ServerMessage_Delivery{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[37]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Delivery) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Delivery) ProtoMessage() {}
+
+func (x *ServerMessage_Delivery) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[37]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Delivery.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Delivery) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 13}
+}
+
+func (x *ServerMessage_Delivery) GetTo() string {
+	if x != nil {
+		return x.To
 	}
 	return ""
 }
 
-func (x *ServerMessage_Chat) GetContent() string {
+func (x *ServerMessage_Delivery) GetItems() []*ServerMessage_Delivery_Item {
 	if x != nil {
-		return x.Content
+		return x.Items
+	}
+	return nil
+}
+
+func (x *ServerMessage_Delivery) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+// 登录时发送每个会话的未读消息数
+type ServerMessage_Unread struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Counts []*ServerMessage_Unread_Count `protobuf:"bytes,1,rep,name=counts,proto3" json:"counts,omitempty"`
+}
+
+func (x *ServerMessage_Unread) Reset() {
+	*x = ServerMessage_Unread{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Unread) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Unread) ProtoMessage() {}
+
+func (x *ServerMessage_Unread) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Unread.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Unread) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 14}
+}
+
+func (x *ServerMessage_Unread) GetCounts() []*ServerMessage_Unread_Count {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+type ServerMessage_Delivery_Item struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MsgId string `protobuf:"bytes,1,opt,name=msgId,proto3" json:"msgId,omitempty"`
+	// 发送方信箱中的序号
+	Seq int64 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+}
+
+func (x *ServerMessage_Delivery_Item) Reset() {
+	*x = ServerMessage_Delivery_Item{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Delivery_Item) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Delivery_Item) ProtoMessage() {}
+
+func (x *ServerMessage_Delivery_Item) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Delivery_Item.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Delivery_Item) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 13, 0}
+}
+
+func (x *ServerMessage_Delivery_Item) GetMsgId() string {
+	if x != nil {
+		return x.MsgId
+	}
+	return ""
+}
+
+func (x *ServerMessage_Delivery_Item) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+type ServerMessage_Unread_Count struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Peer  string `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	Room  string `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Count int64  `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (x *ServerMessage_Unread_Count) Reset() {
+	*x = ServerMessage_Unread_Count{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_chat_server_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServerMessage_Unread_Count) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage_Unread_Count) ProtoMessage() {}
+
+func (x *ServerMessage_Unread_Count) ProtoReflect() protoreflect.Message {
+	mi := &file_api_chat_server_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage_Unread_Count.ProtoReflect.Descriptor instead.
+func (*ServerMessage_Unread_Count) Descriptor() ([]byte, []int) {
+	return file_api_chat_server_proto_rawDescGZIP(), []int{9, 14, 0}
+}
+
+func (x *ServerMessage_Unread_Count) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *ServerMessage_Unread_Count) GetRoom() string {
+	if x != nil {
+		return x.Room
 	}
 	return ""
 }
 
+func (x *ServerMessage_Unread_Count) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
 var File_api_chat_server_proto protoreflect.FileDescriptor
 
 var file_api_chat_server_proto_rawDesc = []byte{
 	0x0a, 0x15, 0x61, 0x70, 0x69, 0x2f, 0x63, 0x68, 0x61, 0x74, 0x2d, 0x73, 0x65, 0x72, 0x76, 0x65,
-	0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x03, 0x61, 0x70, 0x69, 0x22, 0xf9, 0x02, 0x0a,
-	0x0d, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2b,
-	0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x61,
+	0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x03, 0x61, 0x70, 0x69, 0x22, 0xb7, 0x01, 0x0a,
+	0x05, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x20, 0x0a, 0x0b, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73,
+	0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x61, 0x63, 0x63,
+	0x65, 0x73, 0x73, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x32, 0x0a, 0x14, 0x61, 0x63, 0x63, 0x65,
+	0x73, 0x73, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x14, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73, 0x54, 0x6f,
+	0x6b, 0x65, 0x6e, 0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x12, 0x22, 0x0a, 0x0c,
+	0x72, 0x65, 0x66, 0x72, 0x65, 0x73, 0x68, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0c, 0x72, 0x65, 0x66, 0x72, 0x65, 0x73, 0x68, 0x54, 0x6f, 0x6b, 0x65, 0x6e,
+	0x12, 0x34, 0x0a, 0x15, 0x72, 0x65, 0x66, 0x72, 0x65, 0x73, 0x68, 0x54, 0x6f, 0x6b, 0x65, 0x6e,
+	0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x15, 0x72, 0x65, 0x66, 0x72, 0x65, 0x73, 0x68, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x45, 0x78, 0x70,
+	0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x22, 0x35, 0x0a, 0x0f, 0x52, 0x65, 0x66, 0x72, 0x65, 0x73,
+	0x68, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x65, 0x71, 0x12, 0x22, 0x0a, 0x0c, 0x72, 0x65, 0x66,
+	0x72, 0x65, 0x73, 0x68, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0c, 0x72, 0x65, 0x66, 0x72, 0x65, 0x73, 0x68, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x26, 0x0a,
+	0x0e, 0x52, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x65, 0x71, 0x12,
+	0x14, 0x0a, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x10, 0x0a, 0x0e, 0x52, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x54,
+	0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x65, 0x73, 0x22, 0x7b, 0x0a, 0x0d, 0x47, 0x65, 0x74, 0x48, 0x69,
+	0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04,
+	0x72, 0x6f, 0x6f, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d,
+	0x12, 0x16, 0x0a, 0x06, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x06, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x66, 0x74, 0x65,
+	0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x61, 0x66, 0x74, 0x65, 0x72, 0x12, 0x14,
+	0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c,
+	0x69, 0x6d, 0x69, 0x74, 0x22, 0x5e, 0x0a, 0x0d, 0x47, 0x65, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f,
+	0x72, 0x79, 0x52, 0x65, 0x73, 0x12, 0x33, 0x0a, 0x08, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65,
+	0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x43, 0x68, 0x61, 0x74,
+	0x52, 0x08, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x68, 0x61,
+	0x73, 0x4d, 0x6f, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x68, 0x61, 0x73,
+	0x4d, 0x6f, 0x72, 0x65, 0x22, 0x83, 0x01, 0x0a, 0x11, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x52, 0x65, 0x71, 0x12, 0x18, 0x0a, 0x07, 0x6b, 0x65,
+	0x79, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6b, 0x65, 0x79,
+	0x77, 0x6f, 0x72, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x69, 0x6e, 0x63,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x12, 0x14,
+	0x0a, 0x05, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x75,
+	0x6e, 0x74, 0x69, 0x6c, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0x48, 0x0a, 0x11, 0x53, 0x65,
+	0x61, 0x72, 0x63, 0x68, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x52, 0x65, 0x73, 0x12,
+	0x33, 0x0a, 0x08, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x52, 0x08, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x73, 0x22, 0x9c, 0x0e, 0x0a, 0x0d, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2b, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74,
+	0x79, 0x70, 0x65, 0x12, 0x28, 0x0a, 0x03, 0x65, 0x72, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x16, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x2e, 0x45, 0x72, 0x72, 0x52, 0x03, 0x65, 0x72, 0x72, 0x12, 0x2b, 0x0a,
+	0x04, 0x61, 0x75, 0x74, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70,
+	0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x41, 0x75, 0x74, 0x68, 0x52, 0x04, 0x61, 0x75, 0x74, 0x68, 0x12, 0x2b, 0x0a, 0x04, 0x63, 0x68,
+	0x61, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x43, 0x68, 0x61,
+	0x74, 0x52, 0x04, 0x63, 0x68, 0x61, 0x74, 0x12, 0x2b, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65,
+	0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x52, 0x6f, 0x6f, 0x6d, 0x52, 0x04,
+	0x72, 0x6f, 0x6f, 0x6d, 0x12, 0x37, 0x0a, 0x08, 0x70, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x72, 0x65, 0x73, 0x65,
+	0x6e, 0x63, 0x65, 0x52, 0x08, 0x70, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x2b, 0x0a,
+	0x04, 0x72, 0x65, 0x61, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70,
+	0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x52, 0x65, 0x61, 0x64, 0x52, 0x04, 0x72, 0x65, 0x61, 0x64, 0x12, 0x31, 0x0a, 0x06, 0x74, 0x79,
+	0x70, 0x69, 0x6e, 0x67, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x54,
+	0x79, 0x70, 0x69, 0x6e, 0x67, 0x52, 0x06, 0x74, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x12, 0x2b, 0x0a,
+	0x04, 0x65, 0x64, 0x69, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70,
+	0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x45, 0x64, 0x69, 0x74, 0x52, 0x04, 0x65, 0x64, 0x69, 0x74, 0x12, 0x31, 0x0a, 0x06, 0x64, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x06, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x2e, 0x0a,
+	0x05, 0x72, 0x65, 0x61, 0x63, 0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x61,
 	0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
-	0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x28, 0x0a, 0x03, 0x65,
-	0x72, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43,
-	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x45, 0x72, 0x72,
-	0x52, 0x03, 0x65, 0x72, 0x72, 0x12, 0x2b, 0x0a, 0x04, 0x61, 0x75, 0x74, 0x68, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74,
-	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x52, 0x04, 0x61, 0x75,
-	0x74, 0x68, 0x12, 0x2b, 0x0a, 0x04, 0x63, 0x68, 0x61, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x52, 0x04, 0x63, 0x68, 0x61, 0x74, 0x1a,
-	0x33, 0x0a, 0x03, 0x45, 0x72, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x43, 0x6f, 0x64, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x4d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x4d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x1a, 0x22, 0x0a, 0x04, 0x41, 0x75, 0x74, 0x68, 0x12, 0x1a, 0x0a, 0x08,
-	0x75, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
-	0x75, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x1a, 0x30, 0x0a, 0x04, 0x43, 0x68, 0x61, 0x74,
-	0x12, 0x0e, 0x0a, 0x02, 0x74, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x74, 0x6f,
-	0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22, 0x2c, 0x0a, 0x04, 0x54, 0x79,
-	0x70, 0x65, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x4d, 0x54, 0x45, 0x72, 0x72, 0x10, 0x00, 0x12, 0x0b,
-	0x0a, 0x07, 0x43, 0x4d, 0x54, 0x41, 0x75, 0x74, 0x68, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x43,
-	0x4d, 0x54, 0x43, 0x68, 0x61, 0x74, 0x10, 0x02, 0x22, 0x94, 0x03, 0x0a, 0x0d, 0x53, 0x65, 0x72,
-	0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2b, 0x0a, 0x04, 0x74, 0x79,
-	0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53,
-	0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x54, 0x79, 0x70,
-	0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x28, 0x0a, 0x03, 0x65, 0x72, 0x72, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65,
-	0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x45, 0x72, 0x72, 0x52, 0x03, 0x65, 0x72,
-	0x72, 0x12, 0x2b, 0x0a, 0x04, 0x63, 0x68, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x52, 0x04, 0x63, 0x68, 0x61, 0x74, 0x1a, 0x92,
-	0x01, 0x0a, 0x03, 0x45, 0x72, 0x72, 0x12, 0x2f, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65,
-	0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x45, 0x72, 0x72, 0x2e, 0x43, 0x6f, 0x64,
-	0x65, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x4d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
-	0x65, 0x22, 0x40, 0x0a, 0x04, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a, 0x10, 0x50, 0x72, 0x6f,
-	0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x4d, 0x69, 0x73, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x10, 0x00, 0x12,
-	0x0e, 0x0a, 0x0a, 0x41, 0x75, 0x74, 0x68, 0x46, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x10, 0x01, 0x12,
-	0x12, 0x0a, 0x0e, 0x50, 0x65, 0x72, 0x73, 0x6f, 0x6e, 0x4e, 0x6f, 0x74, 0x46, 0x6f, 0x75, 0x6e,
-	0x64, 0x10, 0x02, 0x1a, 0x06, 0x0a, 0x04, 0x41, 0x75, 0x74, 0x68, 0x1a, 0x34, 0x0a, 0x04, 0x43,
-	0x68, 0x61, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65,
-	0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
-	0x74, 0x22, 0x2c, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x4d, 0x54,
-	0x45, 0x72, 0x72, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x4d, 0x54, 0x41, 0x75, 0x74, 0x68,
-	0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x4d, 0x54, 0x43, 0x68, 0x61, 0x74, 0x10, 0x02, 0x32,
-	0x43, 0x0a, 0x0b, 0x43, 0x68, 0x61, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x34,
-	0x0a, 0x04, 0x43, 0x68, 0x61, 0x74, 0x12, 0x12, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69,
-	0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x12, 0x2e, 0x61, 0x70, 0x69,
-	0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x00,
-	0x28, 0x01, 0x30, 0x01, 0x42, 0x32, 0x5a, 0x30, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x68, 0x61, 0x74, 0x6c, 0x6f, 0x6e, 0x65, 0x6c, 0x79, 0x2f, 0x63, 0x68, 0x61,
-	0x72, 0x74, 0x2d, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x67, 0x65,
-	0x6e, 0x2f, 0x67, 0x6f, 0x2f, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x2e, 0x52, 0x65, 0x61, 0x63, 0x74, 0x52, 0x05, 0x72, 0x65, 0x61, 0x63, 0x74, 0x12, 0x31, 0x0a,
+	0x06, 0x74, 0x68, 0x72, 0x65, 0x61, 0x64, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e,
+	0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x2e, 0x54, 0x68, 0x72, 0x65, 0x61, 0x64, 0x52, 0x06, 0x74, 0x68, 0x72, 0x65, 0x61, 0x64,
+	0x1a, 0x33, 0x0a, 0x03, 0x45, 0x72, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x43, 0x6f, 0x64, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x80, 0x02, 0x0a, 0x04, 0x41, 0x75, 0x74, 0x68, 0x12, 0x1a,
+	0x0a, 0x08, 0x75, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x08, 0x75, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6c, 0x61,
+	0x73, 0x74, 0x53, 0x65, 0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x6c, 0x61, 0x73,
+	0x74, 0x53, 0x65, 0x71, 0x12, 0x4a, 0x0a, 0x0b, 0x72, 0x6f, 0x6f, 0x6d, 0x4c, 0x61, 0x73, 0x74,
+	0x53, 0x65, 0x71, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x61, 0x70, 0x69, 0x2e,
+	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x41, 0x75,
+	0x74, 0x68, 0x2e, 0x52, 0x6f, 0x6f, 0x6d, 0x4c, 0x61, 0x73, 0x74, 0x53, 0x65, 0x71, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x0b, 0x72, 0x6f, 0x6f, 0x6d, 0x4c, 0x61, 0x73, 0x74, 0x53, 0x65, 0x71,
+	0x12, 0x1a, 0x0a, 0x08, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x12, 0x1a, 0x0a, 0x08,
+	0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08,
+	0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x1a, 0x3e, 0x0a, 0x10, 0x52, 0x6f, 0x6f, 0x6d,
+	0x4c, 0x61, 0x73, 0x74, 0x53, 0x65, 0x71, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x86, 0x01, 0x0a, 0x04, 0x43, 0x68, 0x61,
+	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x74, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x74,
+	0x6f, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x72,
+	0x6f, 0x6f, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x12,
+	0x14, 0x0a, 0x05, 0x6d, 0x73, 0x67, 0x49, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x6d, 0x73, 0x67, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x54, 0x6f,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x54, 0x6f, 0x12,
+	0x10, 0x0a, 0x03, 0x74, 0x74, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x74, 0x74,
+	0x6c, 0x1a, 0x77, 0x0a, 0x04, 0x52, 0x6f, 0x6f, 0x6d, 0x12, 0x2a, 0x0a, 0x02, 0x6f, 0x70, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65,
+	0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x52, 0x6f, 0x6f, 0x6d, 0x2e, 0x4f,
+	0x70, 0x52, 0x02, 0x6f, 0x70, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x2f, 0x0a, 0x02, 0x4f, 0x70, 0x12,
+	0x0a, 0x0a, 0x06, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x4a,
+	0x6f, 0x69, 0x6e, 0x10, 0x01, 0x12, 0x09, 0x0a, 0x05, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x10, 0x02,
+	0x12, 0x08, 0x0a, 0x04, 0x4c, 0x69, 0x73, 0x74, 0x10, 0x03, 0x1a, 0x46, 0x0a, 0x08, 0x50, 0x72,
+	0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x3a, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x22, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x72, 0x65, 0x73, 0x65,
+	0x6e, 0x63, 0x65, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x1a, 0x40, 0x0a, 0x04, 0x52, 0x65, 0x61, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x65,
+	0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x12,
+	0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f,
+	0x6f, 0x6d, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x03, 0x73, 0x65, 0x71, 0x1a, 0x44, 0x0a, 0x06, 0x54, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x12, 0x0e,
+	0x0a, 0x02, 0x74, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x74, 0x6f, 0x12, 0x12,
+	0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f,
+	0x6f, 0x6d, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x06, 0x74, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x1a, 0x46, 0x0a, 0x04, 0x45, 0x64,
+	0x69, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74,
+	0x65, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x1a, 0x50, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x12, 0x0a, 0x04,
+	0x72, 0x6f, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d,
+	0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x73,
+	0x65, 0x71, 0x12, 0x20, 0x0a, 0x0b, 0x66, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x72, 0x79, 0x6f, 0x6e,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x66, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x72,
+	0x79, 0x6f, 0x6e, 0x65, 0x1a, 0x5b, 0x0a, 0x05, 0x52, 0x65, 0x61, 0x63, 0x74, 0x12, 0x12, 0x0a,
+	0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f,
+	0x6d, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03,
+	0x73, 0x65, 0x71, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x6d, 0x6f, 0x6a, 0x69, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x65, 0x6d, 0x6f, 0x6a, 0x69, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x6d,
+	0x6f, 0x76, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x76,
+	0x65, 0x1a, 0x2e, 0x0a, 0x06, 0x54, 0x68, 0x72, 0x65, 0x61, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x72,
+	0x6f, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x12,
+	0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65,
+	0x71, 0x22, 0x9f, 0x01, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x4d,
+	0x54, 0x45, 0x72, 0x72, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x4d, 0x54, 0x41, 0x75, 0x74,
+	0x68, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x4d, 0x54, 0x43, 0x68, 0x61, 0x74, 0x10, 0x02,
+	0x12, 0x0b, 0x0a, 0x07, 0x43, 0x4d, 0x54, 0x52, 0x6f, 0x6f, 0x6d, 0x10, 0x03, 0x12, 0x0f, 0x0a,
+	0x0b, 0x43, 0x4d, 0x54, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x10, 0x04, 0x12, 0x0b,
+	0x0a, 0x07, 0x43, 0x4d, 0x54, 0x52, 0x65, 0x61, 0x64, 0x10, 0x05, 0x12, 0x0d, 0x0a, 0x09, 0x43,
+	0x4d, 0x54, 0x54, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x10, 0x06, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x4d,
+	0x54, 0x45, 0x64, 0x69, 0x74, 0x10, 0x07, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x4d, 0x54, 0x44, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x10, 0x08, 0x12, 0x0c, 0x0a, 0x08, 0x43, 0x4d, 0x54, 0x52, 0x65, 0x61,
+	0x63, 0x74, 0x10, 0x09, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x4d, 0x54, 0x54, 0x68, 0x72, 0x65, 0x61,
+	0x64, 0x10, 0x0a, 0x22, 0x8c, 0x19, 0x0a, 0x0d, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2b, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x12, 0x28, 0x0a, 0x03, 0x65, 0x72, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x2e, 0x45, 0x72, 0x72, 0x52, 0x03, 0x65, 0x72, 0x72, 0x12, 0x2b, 0x0a, 0x04,
+	0x63, 0x68, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x43,
+	0x68, 0x61, 0x74, 0x52, 0x04, 0x63, 0x68, 0x61, 0x74, 0x12, 0x2b, 0x0a, 0x04, 0x72, 0x6f, 0x6f,
+	0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65,
+	0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x52, 0x6f, 0x6f, 0x6d,
+	0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x12, 0x2b, 0x0a, 0x04, 0x61, 0x75, 0x74, 0x68, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x52, 0x04, 0x61,
+	0x75, 0x74, 0x68, 0x12, 0x37, 0x0a, 0x08, 0x70, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76,
+	0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e,
+	0x63, 0x65, 0x52, 0x08, 0x70, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x37, 0x0a, 0x08,
+	0x73, 0x68, 0x75, 0x74, 0x64, 0x6f, 0x77, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b,
+	0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x2e, 0x53, 0x68, 0x75, 0x74, 0x64, 0x6f, 0x77, 0x6e, 0x52, 0x08, 0x73, 0x68, 0x75,
+	0x74, 0x64, 0x6f, 0x77, 0x6e, 0x12, 0x28, 0x0a, 0x03, 0x61, 0x63, 0x6b, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x16, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x41, 0x63, 0x6b, 0x52, 0x03, 0x61, 0x63, 0x6b, 0x12,
+	0x40, 0x0a, 0x0b, 0x72, 0x65, 0x61, 0x64, 0x52, 0x65, 0x63, 0x65, 0x69, 0x70, 0x74, 0x18, 0x09,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x52, 0x65, 0x61, 0x64, 0x52, 0x65, 0x63,
+	0x65, 0x69, 0x70, 0x74, 0x52, 0x0b, 0x72, 0x65, 0x61, 0x64, 0x52, 0x65, 0x63, 0x65, 0x69, 0x70,
+	0x74, 0x12, 0x31, 0x0a, 0x06, 0x75, 0x6e, 0x72, 0x65, 0x61, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x19, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x55, 0x6e, 0x72, 0x65, 0x61, 0x64, 0x52, 0x06, 0x75, 0x6e,
+	0x72, 0x65, 0x61, 0x64, 0x12, 0x31, 0x0a, 0x06, 0x74, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x18, 0x0b,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x54, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x52,
+	0x06, 0x74, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x12, 0x31, 0x0a, 0x06, 0x64, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65,
+	0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x52, 0x06, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x2e, 0x0a, 0x05, 0x72, 0x65,
+	0x61, 0x63, 0x74, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x61, 0x70, 0x69, 0x2e,
+	0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x52, 0x65,
+	0x61, 0x63, 0x74, 0x52, 0x05, 0x72, 0x65, 0x61, 0x63, 0x74, 0x12, 0x31, 0x0a, 0x06, 0x74, 0x68,
+	0x72, 0x65, 0x61, 0x64, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x54,
+	0x68, 0x72, 0x65, 0x61, 0x64, 0x52, 0x06, 0x74, 0x68, 0x72, 0x65, 0x61, 0x64, 0x12, 0x37, 0x0a,
+	0x08, 0x64, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x79, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1b, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x2e, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x79, 0x52, 0x08, 0x64, 0x65,
+	0x6c, 0x69, 0x76, 0x65, 0x72, 0x79, 0x1a, 0x91, 0x02, 0x0a, 0x03, 0x45, 0x72, 0x72, 0x12, 0x2f,
+	0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x61,
+	0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x2e, 0x45, 0x72, 0x72, 0x2e, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12,
+	0x18, 0x0a, 0x07, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0xbe, 0x01, 0x0a, 0x04, 0x43, 0x6f,
+	0x64, 0x65, 0x12, 0x14, 0x0a, 0x10, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x4d, 0x69,
+	0x73, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x10, 0x00, 0x12, 0x0e, 0x0a, 0x0a, 0x41, 0x75, 0x74, 0x68,
+	0x46, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x10, 0x01, 0x12, 0x12, 0x0a, 0x0e, 0x50, 0x65, 0x72, 0x73,
+	0x6f, 0x6e, 0x4e, 0x6f, 0x74, 0x46, 0x6f, 0x75, 0x6e, 0x64, 0x10, 0x02, 0x12, 0x10, 0x0a, 0x0c,
+	0x52, 0x6f, 0x6f, 0x6d, 0x4e, 0x6f, 0x74, 0x46, 0x6f, 0x75, 0x6e, 0x64, 0x10, 0x03, 0x12, 0x0e,
+	0x0a, 0x0a, 0x52, 0x6f, 0x6f, 0x6d, 0x45, 0x78, 0x69, 0x73, 0x74, 0x73, 0x10, 0x04, 0x12, 0x11,
+	0x0a, 0x0d, 0x4e, 0x6f, 0x74, 0x52, 0x6f, 0x6f, 0x6d, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x10,
+	0x05, 0x12, 0x0e, 0x0a, 0x0a, 0x55, 0x73, 0x65, 0x72, 0x45, 0x78, 0x69, 0x73, 0x74, 0x73, 0x10,
+	0x06, 0x12, 0x0c, 0x0a, 0x08, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x10, 0x07, 0x12,
+	0x13, 0x0a, 0x0f, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x4e, 0x6f, 0x74, 0x46, 0x6f, 0x75,
+	0x6e, 0x64, 0x10, 0x08, 0x12, 0x14, 0x0a, 0x10, 0x4e, 0x6f, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x53, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x10, 0x09, 0x1a, 0x46, 0x0a, 0x04, 0x41, 0x75,
+	0x74, 0x68, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64,
+	0x12, 0x20, 0x0a, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0a, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x05, 0x74, 0x6f, 0x6b,
+	0x65, 0x6e, 0x1a, 0xa2, 0x03, 0x0a, 0x04, 0x43, 0x68, 0x61, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x66,
+	0x72, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12,
+	0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x1c, 0x0a, 0x09, 0x74,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f,
+	0x6d, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x12, 0x0e, 0x0a,
+	0x02, 0x74, 0x6f, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x74, 0x6f, 0x12, 0x14, 0x0a,
+	0x05, 0x6d, 0x73, 0x67, 0x49, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6d, 0x73,
+	0x67, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x64, 0x69, 0x74, 0x65, 0x64, 0x41, 0x74, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x65, 0x64, 0x69, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12,
+	0x18, 0x0a, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x12, 0x39, 0x0a, 0x09, 0x72, 0x65, 0x61,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x61,
+	0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x2e, 0x52, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x72, 0x65, 0x61, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x54, 0x6f, 0x18,
+	0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x54, 0x6f, 0x12, 0x2f,
+	0x0a, 0x06, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x52, 0x06, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x12,
+	0x28, 0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53,
+	0x65, 0x71, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72,
+	0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x71, 0x12, 0x1c, 0x0a, 0x09, 0x65, 0x78, 0x70,
+	0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x65, 0x78,
+	0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x1a, 0x36, 0x0a, 0x08, 0x52, 0x65, 0x61, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x6d, 0x6f, 0x6a, 0x69, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x65, 0x6d, 0x6f, 0x6a, 0x69, 0x12, 0x14, 0x0a, 0x05, 0x75, 0x73, 0x65,
+	0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x1a,
+	0xaa, 0x01, 0x0a, 0x05, 0x52, 0x65, 0x61, 0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f,
+	0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x12, 0x10, 0x0a,
+	0x03, 0x73, 0x65, 0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12,
+	0x12, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x66,
+	0x72, 0x6f, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x6d, 0x6f, 0x6a, 0x69, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x65, 0x6d, 0x6f, 0x6a, 0x69, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x6d,
+	0x6f, 0x76, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x76,
+	0x65, 0x12, 0x39, 0x0a, 0x09, 0x72, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x06,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x52, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x09, 0x72, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x1a, 0x76, 0x0a, 0x04,
+	0x52, 0x6f, 0x6f, 0x6d, 0x12, 0x2a, 0x0a, 0x02, 0x6f, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x1a, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x2e, 0x52, 0x6f, 0x6f, 0x6d, 0x2e, 0x4f, 0x70, 0x52, 0x02, 0x6f, 0x70,
+	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x14,
+	0x0a, 0x05, 0x72, 0x6f, 0x6f, 0x6d, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x72,
+	0x6f, 0x6f, 0x6d, 0x73, 0x1a, 0xad, 0x01, 0x0a, 0x08, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63,
+	0x65, 0x12, 0x1a, 0x0a, 0x08, 0x75, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x75, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x3a, 0x0a,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x22, 0x2e,
+	0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x2e, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0x2b, 0x0a, 0x06, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x12, 0x0b, 0x0a, 0x07, 0x4f, 0x66, 0x66, 0x6c, 0x69, 0x6e, 0x65, 0x10, 0x00, 0x12, 0x0a,
+	0x0a, 0x06, 0x4f, 0x6e, 0x6c, 0x69, 0x6e, 0x65, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x41, 0x77,
+	0x61, 0x79, 0x10, 0x02, 0x1a, 0x22, 0x0a, 0x08, 0x53, 0x68, 0x75, 0x74, 0x64, 0x6f, 0x77, 0x6e,
+	0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x1a, 0xc1, 0x01, 0x0a, 0x03, 0x41, 0x63, 0x6b,
+	0x12, 0x14, 0x0a, 0x05, 0x6d, 0x73, 0x67, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x6d, 0x73, 0x67, 0x49, 0x64, 0x12, 0x35, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1d, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x41, 0x63, 0x6b, 0x2e, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x10, 0x0a,
+	0x03, 0x73, 0x65, 0x71, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12,
+	0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72,
+	0x6f, 0x6f, 0x6d, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x22, 0x2f, 0x0a, 0x06, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x64, 0x10,
+	0x00, 0x12, 0x0d, 0x0a, 0x09, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64, 0x10, 0x01,
+	0x12, 0x0a, 0x0a, 0x06, 0x46, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x10, 0x02, 0x1a, 0x7d, 0x0a, 0x0b,
+	0x52, 0x65, 0x61, 0x64, 0x52, 0x65, 0x63, 0x65, 0x69, 0x70, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x72,
+	0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61,
+	0x64, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x12, 0x10, 0x0a, 0x03, 0x73,
+	0x65, 0x71, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x1c, 0x0a,
+	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x1a, 0x48, 0x0a, 0x06, 0x54,
+	0x79, 0x70, 0x69, 0x6e, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f,
+	0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x12, 0x16, 0x0a,
+	0x06, 0x74, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x74,
+	0x79, 0x70, 0x69, 0x6e, 0x67, 0x1a, 0x50, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72,
+	0x6f, 0x6f, 0x6d, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x20, 0x0a, 0x0b, 0x66, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x72,
+	0x79, 0x6f, 0x6e, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x66, 0x6f, 0x72, 0x45,
+	0x76, 0x65, 0x72, 0x79, 0x6f, 0x6e, 0x65, 0x1a, 0x6c, 0x0a, 0x06, 0x54, 0x68, 0x72, 0x65, 0x61,
+	0x64, 0x12, 0x2f, 0x0a, 0x06, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x52, 0x06, 0x70, 0x61, 0x72, 0x65,
+	0x6e, 0x74, 0x12, 0x31, 0x0a, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x43, 0x68, 0x61, 0x74, 0x52, 0x07, 0x72, 0x65,
+	0x70, 0x6c, 0x69, 0x65, 0x73, 0x1a, 0xa0, 0x01, 0x0a, 0x08, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65,
+	0x72, 0x79, 0x12, 0x0e, 0x0a, 0x02, 0x74, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02,
+	0x74, 0x6f, 0x12, 0x36, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x20, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x49,
+	0x74, 0x65, 0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x1a, 0x2e, 0x0a, 0x04, 0x49, 0x74, 0x65, 0x6d,
+	0x12, 0x14, 0x0a, 0x05, 0x6d, 0x73, 0x67, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x6d, 0x73, 0x67, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65, 0x71, 0x1a, 0x88, 0x01, 0x0a, 0x06, 0x55, 0x6e, 0x72,
+	0x65, 0x61, 0x64, 0x12, 0x37, 0x0a, 0x06, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x55, 0x6e, 0x72, 0x65, 0x61, 0x64, 0x2e, 0x43,
+	0x6f, 0x75, 0x6e, 0x74, 0x52, 0x06, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x1a, 0x45, 0x0a, 0x05,
+	0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f,
+	0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x6d, 0x12, 0x14, 0x0a,
+	0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63, 0x6f,
+	0x75, 0x6e, 0x74, 0x22, 0xdc, 0x01, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0a, 0x0a, 0x06,
+	0x53, 0x4d, 0x54, 0x45, 0x72, 0x72, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x4d, 0x54, 0x41,
+	0x75, 0x74, 0x68, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x4d, 0x54, 0x43, 0x68, 0x61, 0x74,
+	0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x4d, 0x54, 0x52, 0x6f, 0x6f, 0x6d, 0x10, 0x03, 0x12,
+	0x0f, 0x0a, 0x0b, 0x53, 0x4d, 0x54, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x10, 0x04,
+	0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x4d, 0x54, 0x53, 0x68, 0x75, 0x74, 0x64, 0x6f, 0x77, 0x6e, 0x10,
+	0x05, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x4d, 0x54, 0x41, 0x63, 0x6b, 0x10, 0x06, 0x12, 0x0b, 0x0a,
+	0x07, 0x53, 0x4d, 0x54, 0x52, 0x65, 0x61, 0x64, 0x10, 0x07, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x4d,
+	0x54, 0x55, 0x6e, 0x72, 0x65, 0x61, 0x64, 0x10, 0x08, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x4d, 0x54,
+	0x54, 0x79, 0x70, 0x69, 0x6e, 0x67, 0x10, 0x09, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x4d, 0x54, 0x45,
+	0x64, 0x69, 0x74, 0x10, 0x0a, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x4d, 0x54, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x10, 0x0b, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x4d, 0x54, 0x52, 0x65, 0x61, 0x63, 0x74,
+	0x10, 0x0c, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x4d, 0x54, 0x54, 0x68, 0x72, 0x65, 0x61, 0x64, 0x10,
+	0x0d, 0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x4d, 0x54, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x79,
+	0x10, 0x0e, 0x22, 0xcb, 0x03, 0x0a, 0x0f, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x45, 0x6e,
+	0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x12, 0x2d, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x75, 0x73, 0x74,
+	0x65, 0x72, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52,
+	0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x75, 0x73, 0x65,
+	0x72, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x75, 0x73, 0x65,
+	0x72, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x24, 0x0a, 0x0d, 0x65, 0x78, 0x63, 0x65, 0x70, 0x74, 0x53,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x65, 0x78,
+	0x63, 0x65, 0x70, 0x74, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x2c, 0x0a, 0x07, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x61,
+	0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x3e, 0x0a, 0x08, 0x73, 0x65, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x61, 0x70,
+	0x69, 0x2e, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70,
+	0x65, 0x2e, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
+	0x08, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x75, 0x6c,
+	0x6c, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x66, 0x75, 0x6c, 0x6c, 0x12, 0x18, 0x0a,
+	0x07, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x49, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x49, 0x64, 0x1a, 0x5f, 0x0a, 0x0d, 0x53, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x38, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x22, 0x2e, 0x61, 0x70, 0x69, 0x2e,
+	0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x72,
+	0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x36, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65,
+	0x12, 0x0e, 0x0a, 0x0a, 0x43, 0x45, 0x54, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x10, 0x00,
+	0x12, 0x0f, 0x0a, 0x0b, 0x43, 0x45, 0x54, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x10,
+	0x01, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x45, 0x54, 0x52, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x10, 0x02,
+	0x22, 0x31, 0x0a, 0x11, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x50, 0x75, 0x62, 0x6c, 0x69,
+	0x73, 0x68, 0x52, 0x65, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x64, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72,
+	0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x64, 0x65, 0x6c, 0x69, 0x76, 0x65,
+	0x72, 0x65, 0x64, 0x32, 0xae, 0x02, 0x0a, 0x0b, 0x43, 0x68, 0x61, 0x74, 0x53, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x12, 0x34, 0x0a, 0x04, 0x43, 0x68, 0x61, 0x74, 0x12, 0x12, 0x2e, 0x61, 0x70,
+	0x69, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a,
+	0x12, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x12, 0x32, 0x0a, 0x0c, 0x52, 0x65, 0x66,
+	0x72, 0x65, 0x73, 0x68, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x14, 0x2e, 0x61, 0x70, 0x69, 0x2e,
+	0x52, 0x65, 0x66, 0x72, 0x65, 0x73, 0x68, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x65, 0x71, 0x1a,
+	0x0a, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x00, 0x12, 0x39, 0x0a,
+	0x0b, 0x52, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x13, 0x2e, 0x61,
+	0x70, 0x69, 0x2e, 0x52, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x65,
+	0x71, 0x1a, 0x13, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x52, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x54, 0x6f,
+	0x6b, 0x65, 0x6e, 0x52, 0x65, 0x73, 0x22, 0x00, 0x12, 0x36, 0x0a, 0x0a, 0x47, 0x65, 0x74, 0x48,
+	0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x12, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x47, 0x65, 0x74,
+	0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x1a, 0x12, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x47, 0x65, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x73, 0x22, 0x00,
+	0x12, 0x42, 0x0a, 0x0e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x73, 0x12, 0x16, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x52, 0x65, 0x71, 0x1a, 0x16, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x52,
+	0x65, 0x73, 0x22, 0x00, 0x32, 0x4b, 0x0a, 0x0e, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x39, 0x0a, 0x07, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73,
+	0x68, 0x12, 0x14, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x45,
+	0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x1a, 0x16, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6c,
+	0x75, 0x73, 0x74, 0x65, 0x72, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x52, 0x65, 0x73, 0x22,
+	0x00, 0x42, 0x32, 0x5a, 0x30, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x68, 0x61, 0x74, 0x6c, 0x6f, 0x6e, 0x65, 0x6c, 0x79, 0x2f, 0x63, 0x68, 0x61, 0x72, 0x74, 0x2d,
+	0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x67, 0x65, 0x6e, 0x2f, 0x67,
+	0x6f, 0x2f, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -682,37 +3721,125 @@ func file_api_chat_server_proto_rawDescGZIP() []byte {
 	return file_api_chat_server_proto_rawDescData
 }
 
-var file_api_chat_server_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_api_chat_server_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_api_chat_server_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
+var file_api_chat_server_proto_msgTypes = make([]protoimpl.MessageInfo, 42)
 var file_api_chat_server_proto_goTypes = []interface{}{
-	(ClientMessage_Type)(0),     // 0: api.ClientMessage.Type
-	(ServerMessage_Type)(0),     // 1: api.ServerMessage.Type
-	(ServerMessage_Err_Code)(0), // 2: api.ServerMessage.Err.Code
-	(*ClientMessage)(nil),       // 3: api.ClientMessage
-	(*ServerMessage)(nil),       // 4: api.ServerMessage
-	(*ClientMessage_Err)(nil),   // 5: api.ClientMessage.Err
-	(*ClientMessage_Auth)(nil),  // 6: api.ClientMessage.Auth
-	(*ClientMessage_Chat)(nil),  // 7: api.ClientMessage.Chat
-	(*ServerMessage_Err)(nil),   // 8: api.ServerMessage.Err
-	(*ServerMessage_Auth)(nil),  // 9: api.ServerMessage.Auth
-	(*ServerMessage_Chat)(nil),  // 10: api.ServerMessage.Chat
+	(ClientMessage_Type)(0),             // 0: api.ClientMessage.Type
+	(ClientMessage_Room_Op)(0),          // 1: api.ClientMessage.Room.Op
+	(ServerMessage_Type)(0),             // 2: api.ServerMessage.Type
+	(ServerMessage_Err_Code)(0),         // 3: api.ServerMessage.Err.Code
+	(ServerMessage_Presence_Status)(0),  // 4: api.ServerMessage.Presence.Status
+	(ServerMessage_Ack_Status)(0),       // 5: api.ServerMessage.Ack.Status
+	(ClusterEnvelope_Type)(0),           // 6: api.ClusterEnvelope.Type
+	(*Token)(nil),                       // 7: api.Token
+	(*RefreshTokenReq)(nil),             // 8: api.RefreshTokenReq
+	(*RevokeTokenReq)(nil),              // 9: api.RevokeTokenReq
+	(*RevokeTokenRes)(nil),              // 10: api.RevokeTokenRes
+	(*GetHistoryReq)(nil),               // 11: api.GetHistoryReq
+	(*GetHistoryRes)(nil),               // 12: api.GetHistoryRes
+	(*SearchMessagesReq)(nil),           // 13: api.SearchMessagesReq
+	(*SearchMessagesRes)(nil),           // 14: api.SearchMessagesRes
+	(*ClientMessage)(nil),               // 15: api.ClientMessage
+	(*ServerMessage)(nil),               // 16: api.ServerMessage
+	(*ClusterEnvelope)(nil),             // 17: api.ClusterEnvelope
+	(*ClusterPublishRes)(nil),           // 18: api.ClusterPublishRes
+	(*ClientMessage_Err)(nil),           // 19: api.ClientMessage.Err
+	(*ClientMessage_Auth)(nil),          // 20: api.ClientMessage.Auth
+	(*ClientMessage_Chat)(nil),          // 21: api.ClientMessage.Chat
+	(*ClientMessage_Room)(nil),          // 22: api.ClientMessage.Room
+	(*ClientMessage_Presence)(nil),      // 23: api.ClientMessage.Presence
+	(*ClientMessage_Read)(nil),          // 24: api.ClientMessage.Read
+	(*ClientMessage_Typing)(nil),        // 25: api.ClientMessage.Typing
+	(*ClientMessage_Edit)(nil),          // 26: api.ClientMessage.Edit
+	(*ClientMessage_Delete)(nil),        // 27: api.ClientMessage.Delete
+	(*ClientMessage_React)(nil),         // 28: api.ClientMessage.React
+	(*ClientMessage_Thread)(nil),        // 29: api.ClientMessage.Thread
+	nil,                                 // 30: api.ClientMessage.Auth.RoomLastSeqEntry
+	(*ServerMessage_Err)(nil),           // 31: api.ServerMessage.Err
+	(*ServerMessage_Auth)(nil),          // 32: api.ServerMessage.Auth
+	(*ServerMessage_Chat)(nil),          // 33: api.ServerMessage.Chat
+	(*ServerMessage_Reaction)(nil),      // 34: api.ServerMessage.Reaction
+	(*ServerMessage_React)(nil),         // 35: api.ServerMessage.React
+	(*ServerMessage_Room)(nil),          // 36: api.ServerMessage.Room
+	(*ServerMessage_Presence)(nil),      // 37: api.ServerMessage.Presence
+	(*ServerMessage_Shutdown)(nil),      // 38: api.ServerMessage.Shutdown
+	(*ServerMessage_Ack)(nil),           // 39: api.ServerMessage.Ack
+	(*ServerMessage_ReadReceipt)(nil),   // 40: api.ServerMessage.ReadReceipt
+	(*ServerMessage_Typing)(nil),        // 41: api.ServerMessage.Typing
+	(*ServerMessage_Delete)(nil),        // 42: api.ServerMessage.Delete
+	(*ServerMessage_Thread)(nil),        // 43: api.ServerMessage.Thread
+	(*ServerMessage_Delivery)(nil),      // 44: api.ServerMessage.Delivery
+	(*ServerMessage_Unread)(nil),        // 45: api.ServerMessage.Unread
+	(*ServerMessage_Delivery_Item)(nil), // 46: api.ServerMessage.Delivery.Item
+	(*ServerMessage_Unread_Count)(nil),  // 47: api.ServerMessage.Unread.Count
+	nil,                                 // 48: api.ClusterEnvelope.SessionsEntry
 }
 var file_api_chat_server_proto_depIdxs = []int32{
-	0,  // 0: api.ClientMessage.type:type_name -> api.ClientMessage.Type
-	5,  // 1: api.ClientMessage.err:type_name -> api.ClientMessage.Err
-	6,  // 2: api.ClientMessage.auth:type_name -> api.ClientMessage.Auth
-	7,  // 3: api.ClientMessage.chat:type_name -> api.ClientMessage.Chat
-	1,  // 4: api.ServerMessage.type:type_name -> api.ServerMessage.Type
-	8,  // 5: api.ServerMessage.err:type_name -> api.ServerMessage.Err
-	10, // 6: api.ServerMessage.chat:type_name -> api.ServerMessage.Chat
-	2,  // 7: api.ServerMessage.Err.code:type_name -> api.ServerMessage.Err.Code
-	3,  // 8: api.ChatService.Chat:input_type -> api.ClientMessage
-	4,  // 9: api.ChatService.Chat:output_type -> api.ServerMessage
-	9,  // [9:10] is the sub-list for method output_type
-	8,  // [8:9] is the sub-list for method input_type
-	8,  // [8:8] is the sub-list for extension type_name
-	8,  // [8:8] is the sub-list for extension extendee
-	0,  // [0:8] is the sub-list for field type_name
+	33, // 0: api.GetHistoryRes.messages:type_name -> api.ServerMessage.Chat
+	33, // 1: api.SearchMessagesRes.messages:type_name -> api.ServerMessage.Chat
+	0,  // 2: api.ClientMessage.type:type_name -> api.ClientMessage.Type
+	19, // 3: api.ClientMessage.err:type_name -> api.ClientMessage.Err
+	20, // 4: api.ClientMessage.auth:type_name -> api.ClientMessage.Auth
+	21, // 5: api.ClientMessage.chat:type_name -> api.ClientMessage.Chat
+	22, // 6: api.ClientMessage.room:type_name -> api.ClientMessage.Room
+	23, // 7: api.ClientMessage.presence:type_name -> api.ClientMessage.Presence
+	24, // 8: api.ClientMessage.read:type_name -> api.ClientMessage.Read
+	25, // 9: api.ClientMessage.typing:type_name -> api.ClientMessage.Typing
+	26, // 10: api.ClientMessage.edit:type_name -> api.ClientMessage.Edit
+	27, // 11: api.ClientMessage.delete:type_name -> api.ClientMessage.Delete
+	28, // 12: api.ClientMessage.react:type_name -> api.ClientMessage.React
+	29, // 13: api.ClientMessage.thread:type_name -> api.ClientMessage.Thread
+	2,  // 14: api.ServerMessage.type:type_name -> api.ServerMessage.Type
+	31, // 15: api.ServerMessage.err:type_name -> api.ServerMessage.Err
+	33, // 16: api.ServerMessage.chat:type_name -> api.ServerMessage.Chat
+	36, // 17: api.ServerMessage.room:type_name -> api.ServerMessage.Room
+	32, // 18: api.ServerMessage.auth:type_name -> api.ServerMessage.Auth
+	37, // 19: api.ServerMessage.presence:type_name -> api.ServerMessage.Presence
+	38, // 20: api.ServerMessage.shutdown:type_name -> api.ServerMessage.Shutdown
+	39, // 21: api.ServerMessage.ack:type_name -> api.ServerMessage.Ack
+	40, // 22: api.ServerMessage.readReceipt:type_name -> api.ServerMessage.ReadReceipt
+	45, // 23: api.ServerMessage.unread:type_name -> api.ServerMessage.Unread
+	41, // 24: api.ServerMessage.typing:type_name -> api.ServerMessage.Typing
+	42, // 25: api.ServerMessage.delete:type_name -> api.ServerMessage.Delete
+	35, // 26: api.ServerMessage.react:type_name -> api.ServerMessage.React
+	43, // 27: api.ServerMessage.thread:type_name -> api.ServerMessage.Thread
+	44, // 28: api.ServerMessage.delivery:type_name -> api.ServerMessage.Delivery
+	6,  // 29: api.ClusterEnvelope.type:type_name -> api.ClusterEnvelope.Type
+	16, // 30: api.ClusterEnvelope.message:type_name -> api.ServerMessage
+	48, // 31: api.ClusterEnvelope.sessions:type_name -> api.ClusterEnvelope.SessionsEntry
+	30, // 32: api.ClientMessage.Auth.roomLastSeq:type_name -> api.ClientMessage.Auth.RoomLastSeqEntry
+	1,  // 33: api.ClientMessage.Room.op:type_name -> api.ClientMessage.Room.Op
+	4,  // 34: api.ClientMessage.Presence.status:type_name -> api.ServerMessage.Presence.Status
+	3,  // 35: api.ServerMessage.Err.code:type_name -> api.ServerMessage.Err.Code
+	7,  // 36: api.ServerMessage.Auth.token:type_name -> api.Token
+	34, // 37: api.ServerMessage.Chat.reactions:type_name -> api.ServerMessage.Reaction
+	33, // 38: api.ServerMessage.Chat.parent:type_name -> api.ServerMessage.Chat
+	34, // 39: api.ServerMessage.React.reactions:type_name -> api.ServerMessage.Reaction
+	1,  // 40: api.ServerMessage.Room.op:type_name -> api.ClientMessage.Room.Op
+	4,  // 41: api.ServerMessage.Presence.status:type_name -> api.ServerMessage.Presence.Status
+	5,  // 42: api.ServerMessage.Ack.status:type_name -> api.ServerMessage.Ack.Status
+	33, // 43: api.ServerMessage.Thread.parent:type_name -> api.ServerMessage.Chat
+	33, // 44: api.ServerMessage.Thread.replies:type_name -> api.ServerMessage.Chat
+	46, // 45: api.ServerMessage.Delivery.items:type_name -> api.ServerMessage.Delivery.Item
+	47, // 46: api.ServerMessage.Unread.counts:type_name -> api.ServerMessage.Unread.Count
+	4,  // 47: api.ClusterEnvelope.SessionsEntry.value:type_name -> api.ServerMessage.Presence.Status
+	15, // 48: api.ChatService.Chat:input_type -> api.ClientMessage
+	8,  // 49: api.ChatService.RefreshToken:input_type -> api.RefreshTokenReq
+	9,  // 50: api.ChatService.RevokeToken:input_type -> api.RevokeTokenReq
+	11, // 51: api.ChatService.GetHistory:input_type -> api.GetHistoryReq
+	13, // 52: api.ChatService.SearchMessages:input_type -> api.SearchMessagesReq
+	17, // 53: api.ClusterService.Publish:input_type -> api.ClusterEnvelope
+	16, // 54: api.ChatService.Chat:output_type -> api.ServerMessage
+	7,  // 55: api.ChatService.RefreshToken:output_type -> api.Token
+	10, // 56: api.ChatService.RevokeToken:output_type -> api.RevokeTokenRes
+	12, // 57: api.ChatService.GetHistory:output_type -> api.GetHistoryRes
+	14, // 58: api.ChatService.SearchMessages:output_type -> api.SearchMessagesRes
+	18, // 59: api.ClusterService.Publish:output_type -> api.ClusterPublishRes
+	54, // [54:60] is the sub-list for method output_type
+	48, // [48:54] is the sub-list for method input_type
+	48, // [48:48] is the sub-list for extension type_name
+	48, // [48:48] is the sub-list for extension extendee
+	0,  // [0:48] is the sub-list for field type_name
 }
 
 func init() { file_api_chat_server_proto_init() }
@@ -722,6 +3849,102 @@ func file_api_chat_server_proto_init() {
 	}
 	if !protoimpl.UnsafeEnabled {
 		file_api_chat_server_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Token); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RefreshTokenReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RevokeTokenReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RevokeTokenRes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetHistoryReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetHistoryRes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SearchMessagesReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SearchMessagesRes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ClientMessage); i {
 			case 0:
 				return &v.state
@@ -733,7 +3956,7 @@ func file_api_chat_server_proto_init() {
 				return nil
 			}
 		}
-		file_api_chat_server_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+		file_api_chat_server_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ServerMessage); i {
 			case 0:
 				return &v.state
@@ -745,7 +3968,31 @@ func file_api_chat_server_proto_init() {
 				return nil
 			}
 		}
-		file_api_chat_server_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+		file_api_chat_server_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClusterEnvelope); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClusterPublishRes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ClientMessage_Err); i {
 			case 0:
 				return &v.state
@@ -757,7 +4004,7 @@ func file_api_chat_server_proto_init() {
 				return nil
 			}
 		}
-		file_api_chat_server_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+		file_api_chat_server_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ClientMessage_Auth); i {
 			case 0:
 				return &v.state
@@ -769,7 +4016,7 @@ func file_api_chat_server_proto_init() {
 				return nil
 			}
 		}
-		file_api_chat_server_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+		file_api_chat_server_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ClientMessage_Chat); i {
 			case 0:
 				return &v.state
@@ -781,7 +4028,103 @@ func file_api_chat_server_proto_init() {
 				return nil
 			}
 		}
-		file_api_chat_server_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+		file_api_chat_server_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientMessage_Room); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientMessage_Presence); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientMessage_Read); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientMessage_Typing); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientMessage_Edit); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientMessage_Delete); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientMessage_React); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientMessage_Thread); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ServerMessage_Err); i {
 			case 0:
 				return &v.state
@@ -793,7 +4136,7 @@ func file_api_chat_server_proto_init() {
 				return nil
 			}
 		}
-		file_api_chat_server_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+		file_api_chat_server_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ServerMessage_Auth); i {
 			case 0:
 				return &v.state
@@ -805,7 +4148,7 @@ func file_api_chat_server_proto_init() {
 				return nil
 			}
 		}
-		file_api_chat_server_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+		file_api_chat_server_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ServerMessage_Chat); i {
 			case 0:
 				return &v.state
@@ -817,16 +4160,184 @@ func file_api_chat_server_proto_init() {
 				return nil
 			}
 		}
+		file_api_chat_server_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Reaction); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_React); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Room); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[30].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Presence); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[31].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Shutdown); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[32].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Ack); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[33].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_ReadReceipt); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[34].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Typing); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[35].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Delete); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[36].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Thread); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[37].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Delivery); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[38].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Unread); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[39].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Delivery_Item); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_chat_server_proto_msgTypes[40].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServerMessage_Unread_Count); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_api_chat_server_proto_rawDesc,
-			NumEnums:      3,
-			NumMessages:   8,
+			NumEnums:      7,
+			NumMessages:   42,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_api_chat_server_proto_goTypes,
 		DependencyIndexes: file_api_chat_server_proto_depIdxs,
@@ -3,7 +3,17 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/hatlonely/chat-server/api/gen/go/api"
@@ -13,7 +23,9 @@ import (
 	"github.com/hatlonely/go-kit/flag"
 	"github.com/hatlonely/go-kit/refx"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 var Version string
@@ -23,7 +35,22 @@ type Options struct {
 
 	Endpoint string `flag:"-e; default: 127.0.0.1:6080"`
 	Username string `flag:"-u"`
-	To       string `flag:"-t"`
+	Password string `flag:"-p"`
+	Register bool   `flag:"usage: register a new user"`
+	// 保存登录后签发的 token，下次不用输入密码
+	TokenFile string `flag:"usage: file to save token; default: .chat-token"`
+	Logout    bool   `flag:"usage: revoke the saved token"`
+	To        string `flag:"-t"`
+	Room      string `flag:"-r"`
+
+	// 设置了 CAFile 或者 Enable 时使用 TLS，设置了 CertFile 时使用双向认证
+	TLS struct {
+		Enable     bool
+		CAFile     string
+		CertFile   string
+		KeyFile    string
+		ServerName string
+	}
 
 	Window struct {
 		Width      int `flag:"default: 50"`
@@ -45,12 +72,47 @@ func main() {
 		return
 	}
 
-	conn, err := grpc.Dial(options.Endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	creds, err := newClientCredentials(&options)
+	refx.Must(err)
+	conn, err := grpc.Dial(options.Endpoint, grpc.WithTransportCredentials(creds))
 	refx.Must(err)
 	defer conn.Close()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	client := api.NewChatServiceClient(conn)
+
+	// 没有输入密码时使用保存的 token 登录，过期了先刷新
+	var saved *savedToken
+	if options.Password == "" && options.TokenFile != "" {
+		saved, err = loadToken(options.TokenFile)
+		if err != nil {
+			fmt.Printf("读取 token 失败: %s\n", err.Error())
+			return
+		}
+	}
+	if options.Logout {
+		if saved != nil {
+			if _, err := client.RevokeToken(ctx, &api.RevokeTokenReq{Token: saved.Token.RefreshToken}); err != nil {
+				fmt.Printf("注销失败: %s\n", err.Error())
+			}
+			_ = os.Remove(options.TokenFile)
+		}
+		return
+	}
+	if saved != nil {
+		if saved.Token.AccessTokenExpiresAt <= time.Now().UnixNano()/int64(time.Millisecond) {
+			token, err := client.RefreshToken(ctx, &api.RefreshTokenReq{RefreshToken: saved.Token.RefreshToken})
+			if err != nil {
+				fmt.Printf("token 已失效，请重新输入密码登录: %s\n", err.Error())
+				return
+			}
+			saved.Token = token
+			refx.Must(saveToken(options.TokenFile, saved))
+		}
+		options.Username = saved.Username
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+saved.Token.AccessToken)
+	}
+
 	stream, err := client.Chat(ctx)
 	refx.Must(err)
 	defer stream.CloseSend()
@@ -60,6 +122,8 @@ func main() {
 		Type: api.ClientMessage_CMTAuth,
 		Auth: &api.ClientMessage_Auth{
 			Username: options.Username,
+			Password: options.Password,
+			Register: options.Register,
 		},
 	}); err != nil {
 		fmt.Printf("服务器通信失败: %s\n", err.Error())
@@ -77,6 +141,14 @@ func main() {
 		fmt.Println("通信协议出错")
 		return
 	}
+	if message.Auth.Token != nil && options.TokenFile != "" {
+		refx.Must(saveToken(options.TokenFile, &savedToken{Username: options.Username, Token: message.Auth.Token}))
+	}
+	// GetHistory 是单独的请求，密码登录时使用刚签发的 token
+	historyCtx := ctx
+	if message.Auth.Token != nil {
+		historyCtx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+message.Auth.Token.AccessToken)
+	}
 
 	// termui
 	refx.Must(termui.Init())
@@ -96,6 +168,34 @@ func main() {
 		termui.Render(chatArea)
 	}
 
+	// 正在输入提示，在聊天框下面
+	typers := map[string]struct{}{}
+	typingArea := widgets.NewParagraph()
+	typingArea.Border = false
+	typingArea.SetRect(0, options.Window.ChatHeight, options.Window.Width, options.Window.ChatHeight+1)
+
+	updateTypingArea := func(typing *api.ServerMessage_Typing) {
+		name := typing.From
+		if typing.Room != "" {
+			name = fmt.Sprintf("%s #%s", typing.From, typing.Room)
+		}
+		if typing.Typing {
+			typers[name] = struct{}{}
+		} else {
+			delete(typers, name)
+		}
+		names := make([]string, 0, len(typers))
+		for name := range typers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		typingArea.Text = ""
+		if len(names) != 0 {
+			typingArea.Text = fmt.Sprintf("%s is typing…", strings.Join(names, ", "))
+		}
+		termui.Render(typingArea)
+	}
+
 	// 输入框
 	var textAreaBuffer bytes.Buffer
 	textArea := widgets.NewParagraph()
@@ -114,7 +214,7 @@ func main() {
 	}
 
 	// send to server
-	messages := make(chan string, 1)
+	messages := make(chan *api.ClientMessage, 1)
 	go func() {
 	sendLoop:
 		for {
@@ -122,13 +222,7 @@ func main() {
 			case <-ctx.Done():
 				break sendLoop
 			case message := <-messages:
-				if err := stream.Send(&api.ClientMessage{
-					Type: api.ClientMessage_CMTChat,
-					Chat: &api.ClientMessage_Chat{
-						To:      options.To,
-						Content: message,
-					},
-				}); err != nil {
+				if err := stream.Send(message); err != nil {
 					fmt.Printf("system: %s\n", err.Error())
 					continue
 				}
@@ -136,6 +230,17 @@ func main() {
 		}
 	}()
 
+	// 进入房间
+	if options.Room != "" {
+		messages <- &api.ClientMessage{
+			Type: api.ClientMessage_CMTRoom,
+			Room: &api.ClientMessage_Room{Op: api.ClientMessage_Room_Join, Name: options.Room},
+		}
+	}
+
+	// 最后一条发送成功的消息，/edit /delete /unsend 不指定序号时使用
+	var lastAck atomic.Value
+
 	// recv from server
 	go func() {
 		for {
@@ -147,13 +252,149 @@ func main() {
 			}
 
 			if message.Type == api.ServerMessage_SMTChat {
-				appendMessageToChatArea(fmt.Sprintf("[%s] %s", message.Chat.From, message.Chat.Content))
+				// 显示出来就算已读
+				read := &api.ClientMessage_Read{Peer: message.Chat.From, Room: message.Chat.Room, Seq: message.Chat.Seq}
+				if message.Chat.Room != "" {
+					read.Peer = ""
+				} else if message.Chat.From == options.Username {
+					read.Peer = message.Chat.To
+				}
+				if message.Chat.Parent != nil {
+					appendMessageToChatArea(formatQuote(message.Chat.Parent))
+				}
+				appendMessageToChatArea(formatChat(message.Chat, options.Username))
+				messages <- &api.ClientMessage{Type: api.ClientMessage_CMTRead, Read: read}
+			} else if message.Type == api.ServerMessage_SMTEdit {
+				if message.Chat.Parent != nil {
+					appendMessageToChatArea(formatQuote(message.Chat.Parent))
+				}
+				appendMessageToChatArea(formatChat(message.Chat, options.Username))
+			} else if message.Type == api.ServerMessage_SMTThread {
+				appendMessageToChatArea("system: thread " + formatChat(message.Thread.Parent, options.Username))
+				for _, reply := range message.Thread.Replies {
+					appendMessageToChatArea("  " + formatChat(reply, options.Username))
+				}
+			} else if message.Type == api.ServerMessage_SMTReact {
+				action := "回应"
+				if message.React.Remove {
+					action = "取消回应"
+				}
+				prefix := ""
+				if message.React.Room != "" {
+					prefix = "#" + message.React.Room + " "
+				}
+				appendMessageToChatArea(fmt.Sprintf("system: %s%s %s (%d) %s %s",
+					prefix, message.React.From, action, message.React.Seq, message.React.Emoji, formatReactions(message.React.Reactions)))
+			} else if message.Type == api.ServerMessage_SMTDelete {
+				if message.Delete.Room != "" {
+					appendMessageToChatArea(fmt.Sprintf("system: #%s (%d) 已删除", message.Delete.Room, message.Delete.Seq))
+				} else {
+					appendMessageToChatArea(fmt.Sprintf("system: (%d) 已删除", message.Delete.Seq))
+				}
+			} else if message.Type == api.ServerMessage_SMTRead {
+				if message.ReadReceipt.Reader != options.Username && message.ReadReceipt.Room == "" {
+					appendMessageToChatArea(fmt.Sprintf("system: %s 已读", message.ReadReceipt.Reader))
+				}
+			} else if message.Type == api.ServerMessage_SMTUnread {
+				for _, count := range message.Unread.Counts {
+					if count.Room != "" {
+						appendMessageToChatArea(fmt.Sprintf("system: #%s %d 条未读", count.Room, count.Count))
+					} else {
+						appendMessageToChatArea(fmt.Sprintf("system: %s %d 条未读", count.Peer, count.Count))
+					}
+				}
+			} else if message.Type == api.ServerMessage_SMTRoom {
+				if message.Room.Op == api.ClientMessage_Room_List {
+					appendMessageToChatArea(fmt.Sprintf("system: rooms %s", strings.Join(message.Room.Rooms, ", ")))
+				} else {
+					appendMessageToChatArea(fmt.Sprintf("system: %s #%s members %s", message.Room.Op, message.Room.Name, strings.Join(message.Room.Members, ", ")))
+				}
+			} else if message.Type == api.ServerMessage_SMTPresence {
+				appendMessageToChatArea(fmt.Sprintf("system: %s is %s", message.Presence.Username, message.Presence.Status))
+			} else if message.Type == api.ServerMessage_SMTAck {
+				if message.Ack.Status == api.ServerMessage_Ack_Failed {
+					appendMessageToChatArea(fmt.Sprintf("system: 发送失败 %s", message.Ack.Reason))
+				} else {
+					if message.Ack.Status == api.ServerMessage_Ack_Stored && message.Ack.Room == "" {
+						appendMessageToChatArea(fmt.Sprintf("system: (%d) 对方不在线，上线后送达", message.Ack.Seq))
+					}
+					lastAck.Store(message.Ack)
+				}
+			} else if message.Type == api.ServerMessage_SMTDelivery {
+				if message.Delivery.To != options.Username {
+					appendMessageToChatArea(fmt.Sprintf("system: %s 已收到 %d 条消息", message.Delivery.To, len(message.Delivery.Items)))
+				}
+			} else if message.Type == api.ServerMessage_SMTTyping {
+				updateTypingArea(message.Typing)
+			} else if message.Type == api.ServerMessage_SMTShutdown {
+				appendMessageToChatArea(fmt.Sprintf("system: %s", message.Shutdown.Reason))
 			} else if message.Type == api.ServerMessage_SMTErr {
 				appendMessageToChatArea(fmt.Sprintf("[%s] %s", message.Err.Code, message.Err.Message))
 			}
 		}
 	}()
 
+	// 会话 -> 已经加载到的最早的会话序号，/history 每次往前翻一页
+	historyBefore := map[string]int64{}
+	loadHistory := func() {
+		key, req := options.To, &api.GetHistoryReq{Peer: options.To}
+		if options.Room != "" {
+			key, req = "#"+options.Room, &api.GetHistoryReq{Room: options.Room}
+		}
+		if key == "" {
+			return
+		}
+		req.Before = historyBefore[key]
+		reqCtx, reqCancel := context.WithTimeout(historyCtx, 5*time.Second)
+		defer reqCancel()
+		res, err := client.GetHistory(reqCtx, req)
+		if err != nil {
+			appendMessageToChatArea(fmt.Sprintf("system: 加载历史消息失败 %s", err.Error()))
+			return
+		}
+		appendMessageToChatArea(fmt.Sprintf("system: %s 的历史消息", key))
+		for _, chat := range res.Messages {
+			appendMessageToChatArea(formatChat(chat, options.Username))
+		}
+		if len(res.Messages) != 0 {
+			historyBefore[key] = res.Messages[0].ConversationSeq
+		}
+		if !res.HasMore {
+			appendMessageToChatArea("system: 没有更早的消息了")
+		}
+	}
+
+	searchMessages := func(keyword string) {
+		reqCtx, reqCancel := context.WithTimeout(historyCtx, 5*time.Second)
+		defer reqCancel()
+		res, err := client.SearchMessages(reqCtx, &api.SearchMessagesReq{Keyword: keyword})
+		if err != nil {
+			appendMessageToChatArea(fmt.Sprintf("system: 搜索失败 %s", err.Error()))
+			return
+		}
+		appendMessageToChatArea(fmt.Sprintf("system: 搜索 %s，找到 %d 条", keyword, len(res.Messages)))
+		for _, chat := range res.Messages {
+			appendMessageToChatArea("  " + formatChat(chat, options.Username))
+		}
+	}
+
+	// 输入期间每隔几秒告诉服务端还在输入，服务端超时之前刷新
+	var lastTyping time.Time
+	notifyTyping := func() {
+		if strings.HasPrefix(textAreaBuffer.String(), "/") || time.Since(lastTyping) < 3*time.Second {
+			return
+		}
+		lastTyping = time.Now()
+		messages <- &api.ClientMessage{
+			Type: api.ClientMessage_CMTTyping,
+			Typing: &api.ClientMessage_Typing{
+				To:     options.To,
+				Room:   options.Room,
+				Typing: true,
+			},
+		}
+	}
+
 	for e := range termui.PollEvents() {
 		if e.Type == termui.KeyboardEvent {
 			switch e.ID {
@@ -162,13 +403,265 @@ func main() {
 				return
 			case "<Space>":
 				appendCharacterToTextArea(" ")
+				notifyTyping()
 			case "<Enter>":
-				appendMessageToChatArea(fmt.Sprintf("[%s] %s", options.Username, textAreaBuffer.String()))
-				messages <- textAreaBuffer.String()
+				// 发送消息后服务端会结束输入状态
+				lastTyping = time.Time{}
+				text := textAreaBuffer.String()
+				if strings.TrimSpace(text) == "/history" {
+					loadHistory()
+				} else if strings.HasPrefix(text, "/search ") {
+					searchMessages(strings.TrimSpace(strings.TrimPrefix(text, "/search ")))
+				} else if strings.HasPrefix(text, "/") {
+					ack, _ := lastAck.Load().(*api.ServerMessage_Ack)
+					if message := parseCommand(text, &options, ack); message != nil {
+						if message.Type == api.ClientMessage_CMTChat {
+							appendMessageToChatArea(fmt.Sprintf("[%s] ↪(%d) %s", options.Username, message.Chat.ReplyTo, message.Chat.Content))
+						}
+						messages <- message
+					}
+				} else {
+					appendMessageToChatArea(fmt.Sprintf("[%s] %s", options.Username, text))
+					messages <- &api.ClientMessage{
+						Type: api.ClientMessage_CMTChat,
+						Chat: &api.ClientMessage_Chat{
+							To:      options.To,
+							Room:    options.Room,
+							Content: text,
+							MsgId:   newMsgID(),
+						},
+					}
+				}
 				clearTextArea()
 			default:
 				appendCharacterToTextArea(e.ID)
+				notifyTyping()
 			}
 		}
 	}
 }
+
+// formatChat 显示消息，括号中是序号，修改和删除消息时使用
+func formatChat(chat *api.ServerMessage_Chat, username string) string {
+	content := chat.Content
+	if chat.Deleted {
+		content = "[已删除]"
+	} else if chat.EditedAt != 0 {
+		content += " (已编辑)"
+	}
+	if len(chat.Reactions) != 0 {
+		content += " " + formatReactions(chat.Reactions)
+	}
+	if chat.Room != "" {
+		return fmt.Sprintf("(%d) #%s [%s] %s", chat.Seq, chat.Room, chat.From, content)
+	}
+	// 自己在其他设备上发出的消息
+	if chat.From == username && chat.To != username {
+		return fmt.Sprintf("(%d) [%s -> %s] %s", chat.Seq, chat.From, chat.To, content)
+	}
+	return fmt.Sprintf("(%d) [%s] %s", chat.Seq, chat.From, content)
+}
+
+// formatQuote 显示回复的消息，内容太长时截断
+func formatQuote(parent *api.ServerMessage_Chat) string {
+	content := []rune(parent.Content)
+	if parent.Deleted {
+		content = []rune("[已删除]")
+	} else if len(content) > 30 {
+		content = append(content[:30], []rune("…")...)
+	}
+	return fmt.Sprintf("  ┌ (%d) [%s] %s", parent.Seq, parent.From, string(content))
+}
+
+// formatReactions 显示每个表情的回应人数
+func formatReactions(reactions []*api.ServerMessage_Reaction) string {
+	var buf []string
+	for _, reaction := range reactions {
+		buf = append(buf, fmt.Sprintf("%s%d", reaction.Emoji, len(reaction.Users)))
+	}
+	return "[" + strings.Join(buf, " ") + "]"
+}
+
+// parseCommand 解析房间命令：/create /join /leave <room>，/rooms，/to <user> 切换聊天对象，/away /online 设置状态
+// /edit [seq] <content>，/delete [seq] 只为自己删除，/unsend [seq] 为所有人删除，/react /unreact [seq] <emoji> 表情回应
+// /reply [seq] <content> 回复，/thread [seq] 查看回复，不指定序号时是自己最后发送的消息。/history /search 在 main 中处理
+func parseCommand(text string, options *Options, lastAck *api.ServerMessage_Ack) *api.ClientMessage {
+	fields := strings.Fields(text)
+	room := func(op api.ClientMessage_Room_Op, name string) *api.ClientMessage {
+		return &api.ClientMessage{
+			Type: api.ClientMessage_CMTRoom,
+			Room: &api.ClientMessage_Room{Op: op, Name: name},
+		}
+	}
+
+	presence := func(status api.ServerMessage_Presence_Status) *api.ClientMessage {
+		return &api.ClientMessage{
+			Type:     api.ClientMessage_CMTPresence,
+			Presence: &api.ClientMessage_Presence{Status: status},
+		}
+	}
+
+	// target 解析消息序号，返回序号所属的房间和剩下的内容
+	target := func() (string, int64, string, bool) {
+		rest := strings.TrimSpace(strings.TrimPrefix(text, fields[0]))
+		if len(fields) > 1 {
+			if seq, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return options.Room, seq, strings.TrimSpace(strings.TrimPrefix(rest, fields[1])), true
+			}
+		}
+		if lastAck == nil {
+			return "", 0, "", false
+		}
+		return lastAck.Room, lastAck.Seq, rest, true
+	}
+
+	switch {
+	case fields[0] == "/edit":
+		room, seq, content, ok := target()
+		if !ok || content == "" {
+			return nil
+		}
+		return &api.ClientMessage{
+			Type: api.ClientMessage_CMTEdit,
+			Edit: &api.ClientMessage_Edit{Room: room, Seq: seq, Content: content},
+		}
+	case fields[0] == "/reply":
+		room, seq, content, ok := target()
+		if !ok || content == "" {
+			return nil
+		}
+		return &api.ClientMessage{
+			Type: api.ClientMessage_CMTChat,
+			Chat: &api.ClientMessage_Chat{
+				To:      options.To,
+				Room:    room,
+				Content: content,
+				MsgId:   newMsgID(),
+				ReplyTo: seq,
+			},
+		}
+	case fields[0] == "/thread":
+		room, seq, _, ok := target()
+		if !ok {
+			return nil
+		}
+		return &api.ClientMessage{
+			Type:   api.ClientMessage_CMTThread,
+			Thread: &api.ClientMessage_Thread{Room: room, Seq: seq},
+		}
+	case fields[0] == "/react" || fields[0] == "/unreact":
+		room, seq, emoji, ok := target()
+		if !ok || emoji == "" {
+			return nil
+		}
+		return &api.ClientMessage{
+			Type:  api.ClientMessage_CMTReact,
+			React: &api.ClientMessage_React{Room: room, Seq: seq, Emoji: emoji, Remove: fields[0] == "/unreact"},
+		}
+	case fields[0] == "/delete" || fields[0] == "/unsend":
+		room, seq, _, ok := target()
+		if !ok {
+			return nil
+		}
+		return &api.ClientMessage{
+			Type:   api.ClientMessage_CMTDelete,
+			Delete: &api.ClientMessage_Delete{Room: room, Seq: seq, ForEveryone: fields[0] == "/unsend"},
+		}
+	case fields[0] == "/rooms":
+		return room(api.ClientMessage_Room_List, "")
+	case fields[0] == "/away":
+		return presence(api.ServerMessage_Presence_Away)
+	case fields[0] == "/online":
+		return presence(api.ServerMessage_Presence_Online)
+	case len(fields) < 2:
+		return nil
+	case fields[0] == "/create":
+		options.Room = fields[1]
+		return room(api.ClientMessage_Room_Create, fields[1])
+	case fields[0] == "/join":
+		options.Room = fields[1]
+		return room(api.ClientMessage_Room_Join, fields[1])
+	case fields[0] == "/leave":
+		if options.Room == fields[1] {
+			options.Room = ""
+		}
+		return room(api.ClientMessage_Room_Leave, fields[1])
+	case fields[0] == "/to":
+		options.To = fields[1]
+		options.Room = ""
+	}
+	return nil
+}
+
+func newMsgID() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+type savedToken struct {
+	Username string
+	Token    *api.Token
+}
+
+func loadToken(filename string) (*savedToken, error) {
+	buf, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var token savedToken
+	if err := json.Unmarshal(buf, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func saveToken(filename string, token *savedToken) error {
+	buf, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf, 0600)
+}
+
+// newClientCredentials 使用客户端证书时，没有指定用户名就用证书的 CommonName
+func newClientCredentials(options *Options) (credentials.TransportCredentials, error) {
+	if !options.TLS.Enable && options.TLS.CAFile == "" && options.TLS.CertFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	config := &tls.Config{
+		ServerName: options.TLS.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if options.TLS.CAFile != "" {
+		buf, err := os.ReadFile(options.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, fmt.Errorf("no certificate found in [%s]", options.TLS.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if options.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.TLS.CertFile, options.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+		if options.Username == "" {
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				return nil, err
+			}
+			options.Username = leaf.Subject.CommonName
+		}
+	}
+
+	return credentials.NewTLS(config), nil
+}
@@ -1,24 +1,208 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"expvar"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/hatlonely/chat-server/api/gen/go/api"
 	"github.com/hatlonely/chat-server/internal/service"
 
+	"github.com/hatlonely/go-kit/bind"
+	"github.com/hatlonely/go-kit/config"
+	"github.com/hatlonely/go-kit/flag"
+	"github.com/hatlonely/go-kit/logger"
 	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+var Version string
+
+// Options 优先级：命令行 > 环境变量（CHAT_ 前缀）> 配置文件 > 默认值
+type Options struct {
+	flag.Options
+
+	Address string `flag:"usage: listen address" dft:":6080"`
+	// 监控指标的监听地址，/debug/vars 返回 expvar 中的指标，为空时不开启
+	// expvar 也会返回启动参数，不要暴露到公网
+	MetricsAddress string `flag:"usage: metrics listen address"`
+	// 收到退出信号后等待连接退出的最长时间，超时强制关闭
+	ShutdownTimeout time.Duration `dft:"30s"`
+
+	Grpc struct {
+		MaxRecvMsgSize int `dft:"4194304"`
+		// 0 表示不限制
+		MaxConcurrentStreams uint32
+	}
+
+	TLS struct {
+		CertFile string
+		KeyFile  string
+		// 设置后开启双向认证，客户端必须提供该 CA 签发的证书
+		ClientCAFile string
+	}
+
+	Service service.Options
+
+	Logger struct {
+		Info logger.Options
+		Rpc  logger.Options
+	}
+}
+
 func main() {
-	svc, err := service.NewChatServiceWithOptions(&service.Options{})
+	var options Options
+	refx.Must(flag.Struct(&options, refx.WithCamelName()))
+	refx.Must(flag.Parse(flag.WithJsonVal()))
+	if options.Help {
+		fmt.Println(flag.Usage())
+		return
+	}
+	if options.Version {
+		fmt.Println(Version)
+		return
+	}
+
+	getters := []bind.Getter{flag.Instance(), bind.NewEnvGetter(bind.WithEnvPrefix("CHAT"))}
+	if options.ConfigPath != "" {
+		cfg, err := config.NewConfigWithSimpleFile(options.ConfigPath)
+		refx.Must(err)
+		getters = append(getters, cfg)
+	}
+	refx.Must(bind.Bind(&options, getters, refx.WithCamelName(), refx.WithDefaultValidator()))
+	// 双向认证依赖服务端证书，配置不全时不能静默降级成明文或者不校验客户端证书
+	if options.Service.Auth.ClientCertAuth && options.TLS.ClientCAFile == "" {
+		refx.Must(errors.New("service.auth.clientCertAuth requires tls.clientCAFile"))
+	}
+	if options.TLS.ClientCAFile != "" && options.TLS.CertFile == "" {
+		refx.Must(errors.New("tls.clientCAFile requires tls.certFile"))
+	}
+
+	infoLog, err := logger.NewLoggerWithOptions(&options.Logger.Info, refx.WithCamelName())
+	refx.Must(err)
+	rpcLog, err := logger.NewLoggerWithOptions(&options.Logger.Rpc, refx.WithCamelName())
 	refx.Must(err)
 
-	listener, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", 6080))
+	svc, err := service.NewChatServiceWithOptions(&options.Service, rpcLog)
 	refx.Must(err)
 
-	grpcServer := grpc.NewServer()
+	listener, err := net.Listen("tcp", options.Address)
+	refx.Must(err)
+
+	serverOptions := []grpc.ServerOption{
+		grpc.StreamInterceptor(svc.StreamServerInterceptor()),
+		grpc.UnaryInterceptor(svc.UnaryServerInterceptor()),
+		grpc.MaxRecvMsgSize(options.Grpc.MaxRecvMsgSize),
+	}
+	if options.Grpc.MaxConcurrentStreams > 0 {
+		serverOptions = append(serverOptions, grpc.MaxConcurrentStreams(options.Grpc.MaxConcurrentStreams))
+	}
+	if options.TLS.CertFile != "" {
+		creds, err := newServerCredentials(options.TLS.CertFile, options.TLS.KeyFile, options.TLS.ClientCAFile)
+		refx.Must(err)
+		serverOptions = append(serverOptions, grpc.Creds(creds))
+	}
+
+	grpcServer := grpc.NewServer(serverOptions...)
 	api.RegisterChatServiceServer(grpcServer, svc)
-	refx.Must(grpcServer.Serve(listener))
+	infoLog.Info(map[string]interface{}{
+		"message": "chat-server started",
+		"address": options.Address,
+		"version": Version,
+	})
+	go func() {
+		refx.Must(grpcServer.Serve(listener))
+	}()
+
+	var metricsServer *http.Server
+	if options.MetricsAddress != "" {
+		metricsListener, err := net.Listen("tcp", options.MetricsAddress)
+		refx.Must(err)
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		metricsServer = &http.Server{Handler: mux}
+		infoLog.Info(map[string]interface{}{
+			"message": "metrics server started",
+			"address": options.MetricsAddress,
+		})
+		go func() {
+			if err := metricsServer.Serve(metricsListener); err != http.ErrServerClosed {
+				refx.Must(err)
+			}
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+	infoLog.Info(map[string]interface{}{
+		"message": "chat-server shutting down",
+	})
+
+	// GracefulStop 立即停止接收新连接，然后等已有的连接退出
+	ctx, cancel := context.WithTimeout(context.Background(), options.ShutdownTimeout)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	if err := svc.Shutdown(ctx); err != nil {
+		infoLog.Warn(err)
+	}
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		infoLog.Warn(map[string]interface{}{
+			"message": "shutdown timeout, force stop",
+		})
+		grpcServer.Stop()
+	}
+
+	if err := svc.Close(); err != nil {
+		infoLog.Error(err)
+	}
+	if metricsServer != nil {
+		_ = metricsServer.Close()
+	}
+	infoLog.Info(map[string]interface{}{
+		"message": "chat-server stopped",
+	})
+}
+
+// newServerCredentials clientCAFile 不为空时要求并校验客户端证书
+func newServerCredentials(certFile string, keyFile string, clientCAFile string) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "tls.LoadX509KeyPair failed")
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		buf, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "os.ReadFile failed")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, errors.Errorf("no certificate found in [%s]", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
 }
@@ -3,9 +3,12 @@ module github.com/hatlonely/chat-server
 go 1.16
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/gizak/termui/v3 v3.1.0
+	github.com/go-sql-driver/mysql v1.6.0
 	github.com/hatlonely/go-kit v1.1.5-0.20220826080951-170486e59b0b
 	github.com/pkg/errors v0.9.1
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
 	google.golang.org/grpc v1.49.0
 	google.golang.org/protobuf v1.28.1
 )
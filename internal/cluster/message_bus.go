@@ -0,0 +1,63 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+)
+
+// MessageBus 集群节点之间的消息总线，每个节点只处理自己的在线会话，其他节点上的会话通过总线转发
+type MessageBus interface {
+	// Node 本节点的名字
+	Node() string
+	// Publish 把消息发给 node 节点，返回对方处理函数的结果。node 为空时发给所有其他节点，返回结果之和
+	// 发给所有节点时部分失败也会返回成功的结果之和，以及第一个错误
+	Publish(ctx context.Context, node string, envelope *api.ClusterEnvelope) (int, error)
+	// Subscribe 设置处理其他节点发来的消息的函数，返回值是对方 Publish 的结果。没有设置时返回 0
+	Subscribe(handler func(envelope *api.ClusterEnvelope) int)
+	Close() error
+}
+
+var ErrNodeNotFound = errors.New("node not found")
+
+type Options struct {
+	// 节点名，集群中唯一，为空时随机生成
+	Node  string
+	Type  string `dft:"Local"`
+	Local LocalMessageBusOptions
+	Grpc  GrpcMessageBusOptions
+	// 发给每个节点的队列长度，满了之后丢弃，消息已经保存，对方重连后补发
+	QueueSize int `dft:"1024"`
+	Registry  SessionRegistryOptions
+}
+
+func NewMessageBusWithOptions(options *Options) (MessageBus, error) {
+	node := options.Node
+	if node == "" {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, errors.Wrap(err, "rand.Read failed")
+		}
+		node = hex.EncodeToString(buf)
+	}
+
+	switch options.Type {
+	case "", "Local":
+		b, err := NewLocalMessageBusWithOptions(node, &options.Local)
+		if err != nil {
+			return nil, errors.WithMessage(err, "NewLocalMessageBusWithOptions failed")
+		}
+		return b, nil
+	case "Grpc":
+		b, err := NewGrpcMessageBusWithOptions(node, &options.Grpc)
+		if err != nil {
+			return nil, errors.WithMessage(err, "NewGrpcMessageBusWithOptions failed")
+		}
+		return b, nil
+	}
+	return nil, errors.Errorf("unsupported message bus type [%s]", options.Type)
+}
@@ -0,0 +1,268 @@
+package cluster
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const grpcSecretMetadataKey = "x-cluster-secret"
+
+type GrpcMessageBusOptions struct {
+	// 节点之间通信的监听地址，只应该在内部网络中可以访问
+	Address string `dft:":6081"`
+	// 节点名 -> 地址，所有节点可以使用同一份配置，自己会被跳过
+	Peers map[string]string
+	// 节点之间的共享密钥，必须设置
+	Secret  string
+	Timeout time.Duration `dft:"3s"`
+	TLS     GrpcMessageBusTLSOptions
+	// 没有配置 TLS 时必须打开，共享密钥会明文传输，只能在可信的内部网络中使用
+	Insecure bool
+}
+
+// GrpcMessageBusTLSOptions 本节点的证书同时用于监听和连接其他节点
+type GrpcMessageBusTLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// 校验对方节点证书的 CA，为空时使用系统的 CA。设置后开启双向认证，连进来的节点也必须提供该 CA 签发的证书
+	CAFile string
+	// 校验对方证书时使用的名字，为空时使用 Peers 中地址的主机名
+	ServerName string
+}
+
+func NewGrpcMessageBusWithOptions(node string, options *GrpcMessageBusOptions) (*GrpcMessageBus, error) {
+	if options.Secret == "" {
+		return nil, errors.New("cluster secret is not set")
+	}
+	if options.Address == "" {
+		options.Address = ":6081"
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 3 * time.Second
+	}
+	serverCreds, clientCreds, err := newGrpcBusCredentials(options)
+	if err != nil {
+		return nil, errors.WithMessage(err, "newGrpcBusCredentials failed")
+	}
+
+	listener, err := net.Listen("tcp", options.Address)
+	if err != nil {
+		return nil, errors.Wrap(err, "net.Listen failed")
+	}
+	b, err := newGrpcMessageBus(node, options, listener, serverCreds, grpc.WithTransportCredentials(clientCreds))
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// newGrpcMessageBus 在 listener 上提供服务，用 dialOptions 连接其他节点，测试中可以换成进程内的连接
+func newGrpcMessageBus(node string, options *GrpcMessageBusOptions, listener net.Listener,
+	serverCreds credentials.TransportCredentials, dialOptions ...grpc.DialOption) (*GrpcMessageBus, error) {
+	b := &GrpcMessageBus{
+		node:     node,
+		options:  options,
+		peers:    map[string]api.ClusterServiceClient{},
+		listener: listener,
+	}
+	// 连接是异步建立的，对方节点还没启动也不影响
+	for name, address := range options.Peers {
+		if name == node {
+			continue
+		}
+		conn, err := grpc.Dial(address, dialOptions...)
+		if err != nil {
+			_ = b.closeConns()
+			return nil, errors.Wrapf(err, "grpc.Dial [%s] failed", address)
+		}
+		b.conns = append(b.conns, conn)
+		b.peers[name] = api.NewClusterServiceClient(conn)
+	}
+
+	b.server = grpc.NewServer(grpc.Creds(serverCreds))
+	api.RegisterClusterServiceServer(b.server, &grpcClusterServer{bus: b})
+	go func() {
+		_ = b.server.Serve(listener)
+	}()
+
+	return b, nil
+}
+
+// GrpcMessageBus 节点之间两两直连的 gRPC 网络，每条消息是一次 unary 调用
+type GrpcMessageBus struct {
+	node    string
+	options *GrpcMessageBusOptions
+	// 节点名 -> 客户端，创建之后不再修改
+	peers map[string]api.ClusterServiceClient
+	conns []*grpc.ClientConn
+
+	listener net.Listener
+	server   *grpc.Server
+
+	handler func(envelope *api.ClusterEnvelope) int
+	mutex   sync.RWMutex
+}
+
+func (b *GrpcMessageBus) Node() string {
+	return b.node
+}
+
+// Addr 实际监听的地址，配置的端口为 0 时使用
+func (b *GrpcMessageBus) Addr() net.Addr {
+	return b.listener.Addr()
+}
+
+func (b *GrpcMessageBus) Publish(ctx context.Context, node string, envelope *api.ClusterEnvelope) (int, error) {
+	envelope.Node = b.node
+	if node != "" {
+		peer, ok := b.peers[node]
+		if !ok {
+			return 0, ErrNodeNotFound
+		}
+		return b.publish(ctx, peer, envelope)
+	}
+
+	// 并发发给所有节点，按节点名的顺序取第一个错误
+	names := make([]string, 0, len(b.peers))
+	for name := range b.peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	counts := make([]int, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, peer api.ClusterServiceClient) {
+			defer wg.Done()
+			counts[i], errs[i] = b.publish(ctx, peer, envelope)
+		}(i, b.peers[name])
+	}
+	wg.Wait()
+
+	n := 0
+	var err error
+	for i := range names {
+		n += counts[i]
+		if errs[i] != nil && err == nil {
+			err = errors.WithMessagef(errs[i], "publish to node [%s] failed", names[i])
+		}
+	}
+	return n, err
+}
+
+func (b *GrpcMessageBus) publish(ctx context.Context, peer api.ClusterServiceClient, envelope *api.ClusterEnvelope) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.options.Timeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, grpcSecretMetadataKey, b.options.Secret)
+
+	res, err := peer.Publish(ctx, envelope)
+	if err != nil {
+		return 0, errors.Wrap(err, "client.Publish failed")
+	}
+	return int(res.Delivered), nil
+}
+
+func (b *GrpcMessageBus) Subscribe(handler func(envelope *api.ClusterEnvelope) int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handler = handler
+}
+
+// grpcClusterServer 接收其他节点发来的消息
+type grpcClusterServer struct {
+	api.UnimplementedClusterServiceServer
+
+	bus *GrpcMessageBus
+}
+
+// Publish 校验共享密钥之后交给处理函数
+func (s *grpcClusterServer) Publish(ctx context.Context, envelope *api.ClusterEnvelope) (*api.ClusterPublishRes, error) {
+	b := s.bus
+	md, _ := metadata.FromIncomingContext(ctx)
+	secrets := md.Get(grpcSecretMetadataKey)
+	if len(secrets) == 0 || subtle.ConstantTimeCompare([]byte(secrets[0]), []byte(b.options.Secret)) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "invalid cluster secret")
+	}
+
+	b.mutex.RLock()
+	handler := b.handler
+	b.mutex.RUnlock()
+	if handler == nil {
+		return &api.ClusterPublishRes{}, nil
+	}
+	return &api.ClusterPublishRes{Delivered: int32(handler(envelope))}, nil
+}
+
+// newGrpcBusCredentials 返回监听和连接其他节点使用的证书，没有配置 TLS 时需要 Insecure
+func newGrpcBusCredentials(options *GrpcMessageBusOptions) (credentials.TransportCredentials, credentials.TransportCredentials, error) {
+	if options.TLS.CertFile == "" {
+		if !options.Insecure {
+			return nil, nil, errors.New("cluster tls is not set, set insecure explicitly to send the secret in plaintext")
+		}
+		return insecure.NewCredentials(), insecure.NewCredentials(), nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(options.TLS.CertFile, options.TLS.KeyFile)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "tls.LoadX509KeyPair failed")
+	}
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	clientConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ServerName:   options.TLS.ServerName,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if options.TLS.CAFile != "" {
+		buf, err := os.ReadFile(options.TLS.CAFile)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "os.ReadFile failed")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, nil, errors.Errorf("no certificate found in [%s]", options.TLS.CAFile)
+		}
+		serverConfig.ClientCAs = pool
+		serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		clientConfig.RootCAs = pool
+	}
+
+	return credentials.NewTLS(serverConfig), credentials.NewTLS(clientConfig), nil
+}
+
+func (b *GrpcMessageBus) Close() error {
+	b.server.Stop()
+	return b.closeConns()
+}
+
+func (b *GrpcMessageBus) closeConns() error {
+	var err error
+	for _, conn := range b.conns {
+		if e := conn.Close(); e != nil && err == nil {
+			err = errors.Wrap(e, "conn.Close failed")
+		}
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package cluster
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testGrpcCluster 进程内的 gRPC 集群，节点之间通过 bufconn 连接，地址就是节点名
+type testGrpcCluster struct {
+	listeners map[string]*bufconn.Listener
+}
+
+func newTestGrpcCluster(nodes ...string) *testGrpcCluster {
+	c := &testGrpcCluster{listeners: map[string]*bufconn.Listener{}}
+	for _, node := range nodes {
+		c.listeners[node] = bufconn.Listen(1024 * 1024)
+	}
+	return c
+}
+
+func (c *testGrpcCluster) dial(ctx context.Context, address string) (net.Conn, error) {
+	listener, ok := c.listeners[address]
+	if !ok {
+		return nil, errors.Errorf("unknown address [%s]", address)
+	}
+	return listener.DialContext(ctx)
+}
+
+// newBus 创建 node 节点，peers 中的节点都可以连接
+func (c *testGrpcCluster) newBus(t *testing.T, node string, secret string, peers ...string) *GrpcMessageBus {
+	t.Helper()
+	options := &GrpcMessageBusOptions{Peers: map[string]string{}, Secret: secret, Timeout: 3 * time.Second, Insecure: true}
+	for _, peer := range peers {
+		options.Peers[peer] = peer
+	}
+	b, err := newGrpcMessageBus(node, options, c.listeners[node], insecure.NewCredentials(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithContextDialer(c.dial))
+	if err != nil {
+		t.Fatalf("newGrpcMessageBus failed: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+// testHandler 记录收到的消息，每条消息返回 delivered
+type testHandler struct {
+	delivered int
+	envelopes []*api.ClusterEnvelope
+	mutex     sync.Mutex
+}
+
+func (h *testHandler) handle(envelope *api.ClusterEnvelope) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.envelopes = append(h.envelopes, envelope)
+	return h.delivered
+}
+
+func (h *testHandler) received() []*api.ClusterEnvelope {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]*api.ClusterEnvelope(nil), h.envelopes...)
+}
+
+func TestGrpcMessageBusPublish(t *testing.T) {
+	c := newTestGrpcCluster("a", "b", "c")
+	a := c.newBus(t, "a", "secret", "a", "b", "c")
+	b := c.newBus(t, "b", "secret", "a", "b", "c")
+	bc := c.newBus(t, "c", "secret", "a", "b", "c")
+	hb, hc := &testHandler{delivered: 1}, &testHandler{delivered: 2}
+	b.Subscribe(hb.handle)
+	bc.Subscribe(hc.handle)
+
+	ctx := context.Background()
+	n, err := a.Publish(ctx, "b", &api.ClusterEnvelope{Type: api.ClusterEnvelope_CETDeliver, Username: "bob"})
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Publish = %d, want 1", n)
+	}
+	if got := hb.received(); len(got) != 1 || got[0].Node != "a" || got[0].Username != "bob" {
+		t.Fatalf("b received %v, want one envelope for bob from a", got)
+	}
+	if got := hc.received(); len(got) != 0 {
+		t.Fatalf("c received %v, want nothing", got)
+	}
+
+	// 广播发给自己以外的所有节点，返回结果之和
+	n, err = a.Publish(ctx, "", &api.ClusterEnvelope{Type: api.ClusterEnvelope_CETSessions, Full: true})
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("broadcast Publish = %d, want 3", n)
+	}
+	if len(hb.received()) != 2 || len(hc.received()) != 1 {
+		t.Fatalf("b received %d, c received %d, want 2 and 1", len(hb.received()), len(hc.received()))
+	}
+
+	if _, err := a.Publish(ctx, "d", &api.ClusterEnvelope{}); err != ErrNodeNotFound {
+		t.Fatalf("Publish to unknown node error = %v, want ErrNodeNotFound", err)
+	}
+}
+
+func TestGrpcMessageBusSecret(t *testing.T) {
+	c := newTestGrpcCluster("a", "b")
+	a := c.newBus(t, "a", "wrong", "b")
+	b := c.newBus(t, "b", "secret", "a")
+	h := &testHandler{delivered: 1}
+	b.Subscribe(h.handle)
+
+	_, err := a.Publish(context.Background(), "b", &api.ClusterEnvelope{Type: api.ClusterEnvelope_CETRevoke, Username: "bob"})
+	if status.Code(errors.Cause(err)) != codes.Unauthenticated {
+		t.Fatalf("Publish with wrong secret error = %v, want Unauthenticated", err)
+	}
+	if got := h.received(); len(got) != 0 {
+		t.Fatalf("b handled %v from a node with wrong secret", got)
+	}
+}
+
+func TestNewGrpcMessageBusWithOptions(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		options *GrpcMessageBusOptions
+	}{
+		{"secret is not set", &GrpcMessageBusOptions{Address: "127.0.0.1:0", Insecure: true}},
+		// 没有 TLS 时共享密钥明文传输，必须显式打开 Insecure
+		{"tls is not set", &GrpcMessageBusOptions{Address: "127.0.0.1:0", Secret: "secret"}},
+		{"cert file not found", &GrpcMessageBusOptions{Address: "127.0.0.1:0", Secret: "secret", TLS: GrpcMessageBusTLSOptions{
+			CertFile: "not-exist.crt", KeyFile: "not-exist.key",
+		}}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			b, err := NewGrpcMessageBusWithOptions("a", c.options)
+			if err == nil {
+				_ = b.Close()
+				t.Fatalf("NewGrpcMessageBusWithOptions succeeded, want error")
+			}
+		})
+	}
+}
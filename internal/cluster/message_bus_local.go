@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+type LocalMessageBusOptions struct {
+	// 同一个进程中 Hub 相同的节点互相连通，为空时只有自己，相当于单节点部署
+	Hub string
+}
+
+// localHubs hub 名 -> 节点名 -> 节点
+var localHubs = struct {
+	hubs  map[string]map[string]*LocalMessageBus
+	mutex sync.RWMutex
+}{hubs: map[string]map[string]*LocalMessageBus{}}
+
+func NewLocalMessageBusWithOptions(node string, options *LocalMessageBusOptions) (*LocalMessageBus, error) {
+	b := &LocalMessageBus{node: node, hub: options.Hub}
+	if b.hub == "" {
+		return b, nil
+	}
+
+	localHubs.mutex.Lock()
+	defer localHubs.mutex.Unlock()
+	if _, ok := localHubs.hubs[b.hub]; !ok {
+		localHubs.hubs[b.hub] = map[string]*LocalMessageBus{}
+	}
+	if _, ok := localHubs.hubs[b.hub][node]; ok {
+		return nil, errors.Errorf("node [%s] exists in hub [%s]", node, b.hub)
+	}
+	localHubs.hubs[b.hub][node] = b
+	return b, nil
+}
+
+// LocalMessageBus 进程内的消息总线，直接调用其他节点的处理函数
+type LocalMessageBus struct {
+	node    string
+	hub     string
+	handler func(envelope *api.ClusterEnvelope) int
+	mutex   sync.RWMutex
+}
+
+func (b *LocalMessageBus) Node() string {
+	return b.node
+}
+
+func (b *LocalMessageBus) Publish(ctx context.Context, node string, envelope *api.ClusterEnvelope) (int, error) {
+	envelope.Node = b.node
+
+	localHubs.mutex.RLock()
+	var peers []*LocalMessageBus
+	for name, peer := range localHubs.hubs[b.hub] {
+		if name != b.node && (node == "" || name == node) {
+			peers = append(peers, peer)
+		}
+	}
+	localHubs.mutex.RUnlock()
+	if node != "" && len(peers) == 0 {
+		return 0, ErrNodeNotFound
+	}
+
+	n := 0
+	for _, peer := range peers {
+		// 复制一份，和通过网络发送一样，双方不共享消息
+		n += peer.handle(proto.Clone(envelope).(*api.ClusterEnvelope))
+	}
+	return n, nil
+}
+
+func (b *LocalMessageBus) Subscribe(handler func(envelope *api.ClusterEnvelope) int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handler = handler
+}
+
+func (b *LocalMessageBus) handle(envelope *api.ClusterEnvelope) int {
+	b.mutex.RLock()
+	handler := b.handler
+	b.mutex.RUnlock()
+	if handler == nil {
+		return 0
+	}
+	return handler(envelope)
+}
+
+func (b *LocalMessageBus) Close() error {
+	if b.hub == "" {
+		return nil
+	}
+	localHubs.mutex.Lock()
+	defer localHubs.mutex.Unlock()
+	delete(localHubs.hubs[b.hub], b.node)
+	if len(localHubs.hubs[b.hub]) == 0 {
+		delete(localHubs.hubs, b.hub)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+)
+
+func newTestLocalMessageBus(t *testing.T, node string, hub string) *LocalMessageBus {
+	t.Helper()
+	b, err := NewLocalMessageBusWithOptions(node, &LocalMessageBusOptions{Hub: hub})
+	if err != nil {
+		t.Fatalf("NewLocalMessageBusWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+func TestLocalMessageBusPublish(t *testing.T) {
+	hub := t.Name()
+	a := newTestLocalMessageBus(t, "a", hub)
+	b := newTestLocalMessageBus(t, "b", hub)
+	c := newTestLocalMessageBus(t, "c", hub)
+	hb, hc := &testHandler{delivered: 1}, &testHandler{delivered: 2}
+	b.Subscribe(hb.handle)
+	c.Subscribe(hc.handle)
+
+	ctx := context.Background()
+	envelope := &api.ClusterEnvelope{Type: api.ClusterEnvelope_CETDeliver, Username: "bob"}
+	if n, err := a.Publish(ctx, "b", envelope); err != nil || n != 1 {
+		t.Fatalf("Publish = %d, %v, want 1", n, err)
+	}
+	got := hb.received()
+	if len(got) != 1 || got[0].Node != "a" || got[0].Username != "bob" {
+		t.Fatalf("b received %v, want one envelope for bob from a", got)
+	}
+	// 收到的是复制的消息，修改不影响发送方
+	got[0].Username = "changed"
+	if envelope.Username != "bob" {
+		t.Fatalf("envelope shared between nodes")
+	}
+
+	if n, err := a.Publish(ctx, "", &api.ClusterEnvelope{Type: api.ClusterEnvelope_CETSessions}); err != nil || n != 3 {
+		t.Fatalf("broadcast Publish = %d, %v, want 3", n, err)
+	}
+	if _, err := a.Publish(ctx, "d", &api.ClusterEnvelope{}); err != ErrNodeNotFound {
+		t.Fatalf("Publish to unknown node error = %v, want ErrNodeNotFound", err)
+	}
+
+	// 关闭之后从 hub 中移除
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := a.Publish(ctx, "c", &api.ClusterEnvelope{}); err != ErrNodeNotFound {
+		t.Fatalf("Publish to closed node error = %v, want ErrNodeNotFound", err)
+	}
+}
+
+func TestLocalMessageBusHub(t *testing.T) {
+	hub := t.Name()
+	newTestLocalMessageBus(t, "a", hub)
+	if _, err := NewLocalMessageBusWithOptions("a", &LocalMessageBusOptions{Hub: hub}); err == nil {
+		t.Fatalf("NewLocalMessageBusWithOptions with duplicate node succeeded, want error")
+	}
+
+	// 不同的 hub 之间不连通，没有 hub 时只有自己
+	other := newTestLocalMessageBus(t, "b", hub+"-other")
+	single := newTestLocalMessageBus(t, "c", "")
+	for _, b := range []*LocalMessageBus{other, single} {
+		if n, err := b.Publish(context.Background(), "", &api.ClusterEnvelope{}); err != nil || n != 0 {
+			t.Fatalf("Publish from node [%s] = %d, %v, want 0", b.Node(), n, err)
+		}
+		if _, err := b.Publish(context.Background(), "a", &api.ClusterEnvelope{}); err != ErrNodeNotFound {
+			t.Fatalf("Publish from node [%s] error = %v, want ErrNodeNotFound", b.Node(), err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+)
+
+func NewPublishQueue(bus MessageBus, size int, onError func(node string, err error)) *PublishQueue {
+	if size <= 0 {
+		size = 1024
+	}
+	return &PublishQueue{
+		bus:     bus,
+		size:    size,
+		onError: onError,
+		queues:  map[string]chan *api.ClusterEnvelope{},
+		done:    make(chan struct{}),
+	}
+}
+
+// PublishQueue 每个节点一个发送队列和一个 goroutine，慢节点或者已经下线的节点只会填满自己的队列，不会阻塞发送方
+// 同一个节点的消息按放进队列的顺序发送
+type PublishQueue struct {
+	bus     MessageBus
+	size    int
+	onError func(node string, err error)
+
+	// 节点名 -> 队列，第一次发给这个节点时创建
+	queues map[string]chan *api.ClusterEnvelope
+	closed bool
+	mutex  sync.Mutex
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Publish 放进 node 的队列，队列满或者已经关闭时返回 false
+func (q *PublishQueue) Publish(node string, envelope *api.ClusterEnvelope) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return false
+	}
+	queue, ok := q.queues[node]
+	if !ok {
+		queue = make(chan *api.ClusterEnvelope, q.size)
+		q.queues[node] = queue
+		q.wg.Add(1)
+		go q.publishLoop(node, queue)
+	}
+
+	select {
+	case queue <- envelope:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *PublishQueue) publishLoop(node string, queue chan *api.ClusterEnvelope) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case envelope := <-queue:
+			if _, err := q.bus.Publish(context.Background(), node, envelope); err != nil && q.onError != nil {
+				q.onError(node, err)
+			}
+		}
+	}
+}
+
+// Close 停止发送，队列中剩下的消息直接丢弃，已经保存的消息对方重连后会补发
+func (q *PublishQueue) Close() {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return
+	}
+	q.closed = true
+	q.mutex.Unlock()
+
+	close(q.done)
+	q.wg.Wait()
+}
@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+)
+
+// blockingMessageBus Publish 阻塞到 release 关闭，用来填满发送队列
+type blockingMessageBus struct {
+	started chan string
+	release chan struct{}
+	err     error
+
+	published map[string]int
+	mutex     sync.Mutex
+}
+
+func newBlockingMessageBus() *blockingMessageBus {
+	return &blockingMessageBus{
+		started:   make(chan string, 16),
+		release:   make(chan struct{}),
+		published: map[string]int{},
+	}
+}
+
+func (b *blockingMessageBus) Node() string {
+	return "a"
+}
+
+func (b *blockingMessageBus) Publish(ctx context.Context, node string, envelope *api.ClusterEnvelope) (int, error) {
+	b.started <- node
+	<-b.release
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.published[node]++
+	return 1, b.err
+}
+
+func (b *blockingMessageBus) Subscribe(handler func(envelope *api.ClusterEnvelope) int) {}
+
+func (b *blockingMessageBus) Close() error {
+	return nil
+}
+
+func TestPublishQueueDrop(t *testing.T) {
+	bus := newBlockingMessageBus()
+	q := NewPublishQueue(bus, 1, nil)
+
+	// 第一条被发送 goroutine 取走并阻塞，第二条留在队列中，第三条放不下
+	if !q.Publish("b", &api.ClusterEnvelope{}) {
+		t.Fatalf("Publish 1 = false, want true")
+	}
+	<-bus.started
+	if !q.Publish("b", &api.ClusterEnvelope{}) {
+		t.Fatalf("Publish 2 = false, want true")
+	}
+	if q.Publish("b", &api.ClusterEnvelope{}) {
+		t.Fatalf("Publish to full queue = true, want false")
+	}
+	// 慢节点不影响发给其他节点
+	if !q.Publish("c", &api.ClusterEnvelope{}) {
+		t.Fatalf("Publish to other node = false, want true")
+	}
+
+	close(bus.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		bus.mutex.Lock()
+		b, c := bus.published["b"], bus.published["c"]
+		bus.mutex.Unlock()
+		if b == 2 && c == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("published b = %d, c = %d, want 2 and 1", b, c)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	q.Close()
+	if q.Publish("b", &api.ClusterEnvelope{}) {
+		t.Fatalf("Publish after Close = true, want false")
+	}
+}
+
+func TestPublishQueueError(t *testing.T) {
+	bus := newBlockingMessageBus()
+	bus.err = errors.New("unavailable")
+	close(bus.release)
+
+	errs := make(chan string, 1)
+	q := NewPublishQueue(bus, 1, func(node string, err error) {
+		errs <- node
+	})
+	defer q.Close()
+
+	if !q.Publish("b", &api.ClusterEnvelope{}) {
+		t.Fatalf("Publish = false, want true")
+	}
+	select {
+	case node := <-errs:
+		if node != "b" {
+			t.Fatalf("onError node = %s, want b", node)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("onError not called")
+	}
+}
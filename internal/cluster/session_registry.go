@@ -0,0 +1,206 @@
+package cluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+)
+
+type SessionRegistryOptions struct {
+	// 全量同步的间隔，节点之间丢失的增量在下一次全量同步时修正
+	SyncInterval time.Duration `dft:"10s"`
+	// 超过这个时间没有收到一个节点的消息，认为节点已经下线
+	Expiration time.Duration `dft:"30s"`
+}
+
+func NewSessionRegistryWithOptions(bus MessageBus, options *SessionRegistryOptions) *SessionRegistry {
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = 10 * time.Second
+	}
+	if options.Expiration < options.SyncInterval {
+		options.Expiration = 3 * options.SyncInterval
+	}
+
+	r := &SessionRegistry{
+		bus:     bus,
+		options: options,
+		local:   map[string]api.ServerMessage_Presence_Status{},
+		dirty:   map[string]struct{}{},
+		remote:  map[string]*remoteSessions{},
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.syncLoop()
+	return r
+}
+
+// SessionRegistry 用户名到所在节点的映射。每个节点只维护自己的在线用户，变化时通过总线广播给其他节点
+// 定时广播全量，节点重启或者丢失增量之后都能恢复，长时间没有消息的节点被认为已经下线
+type SessionRegistry struct {
+	bus     MessageBus
+	options *SessionRegistryOptions
+
+	// 本节点在线的用户 -> 状态
+	local map[string]api.ServerMessage_Presence_Status
+	// 状态变化了还没有广播的用户
+	dirty map[string]struct{}
+	// 其他节点 -> 在线的用户
+	remote map[string]*remoteSessions
+	mutex  sync.RWMutex
+
+	notify chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+type remoteSessions struct {
+	sessions  map[string]api.ServerMessage_Presence_Status
+	updatedAt time.Time
+}
+
+// Set 更新本节点上用户的状态，Offline 表示用户在本节点上已经没有会话
+func (r *SessionRegistry) Set(username string, status api.ServerMessage_Presence_Status) {
+	r.mutex.Lock()
+	if status == api.ServerMessage_Presence_Offline {
+		delete(r.local, username)
+	} else {
+		r.local[username] = status
+	}
+	r.dirty[username] = struct{}{}
+	r.mutex.Unlock()
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Nodes 用户有在线会话的其他节点，按节点名排序
+func (r *SessionRegistry) Nodes(username string) []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var nodes []string
+	deadline := time.Now().Add(-r.options.Expiration)
+	for node, remote := range r.remote {
+		if _, ok := remote.sessions[username]; ok && remote.updatedAt.After(deadline) {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Status 用户在其他节点上的状态，有一个节点在线就是在线，不在其他节点上时返回 false
+func (r *SessionRegistry) Status(username string) (api.ServerMessage_Presence_Status, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	status, found := api.ServerMessage_Presence_Offline, false
+	deadline := time.Now().Add(-r.options.Expiration)
+	for _, remote := range r.remote {
+		s, ok := remote.sessions[username]
+		if !ok || !remote.updatedAt.After(deadline) {
+			continue
+		}
+		if !found || s == api.ServerMessage_Presence_Online {
+			status, found = s, true
+		}
+	}
+	return status, found
+}
+
+// Handle 处理其他节点广播的在线用户
+func (r *SessionRegistry) Handle(envelope *api.ClusterEnvelope) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	remote, ok := r.remote[envelope.Node]
+	if !ok || envelope.Full {
+		remote = &remoteSessions{sessions: map[string]api.ServerMessage_Presence_Status{}}
+		r.remote[envelope.Node] = remote
+	}
+	for username, status := range envelope.Sessions {
+		if status == api.ServerMessage_Presence_Offline {
+			delete(remote.sessions, username)
+		} else {
+			remote.sessions[username] = status
+		}
+	}
+	remote.updatedAt = time.Now()
+}
+
+// Close 通知其他节点本节点已经没有在线用户
+func (r *SessionRegistry) Close() {
+	close(r.done)
+	r.wg.Wait()
+
+	r.mutex.Lock()
+	r.local = map[string]api.ServerMessage_Presence_Status{}
+	r.mutex.Unlock()
+	r.broadcast(true)
+}
+
+func (r *SessionRegistry) syncLoop() {
+	defer r.wg.Done()
+
+	// 启动时先广播一次全量，替换其他节点上本节点之前的记录
+	r.broadcast(true)
+	ticker := time.NewTicker(r.options.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-r.notify:
+			r.broadcast(false)
+		case <-ticker.C:
+			r.broadcast(true)
+			r.expire()
+		}
+	}
+}
+
+// broadcast 只在 syncLoop 中调用，保证发给每个节点的增量和全量是按顺序的。发送失败时等下一次全量同步
+func (r *SessionRegistry) broadcast(full bool) {
+	r.mutex.Lock()
+	envelope := &api.ClusterEnvelope{
+		Type:     api.ClusterEnvelope_CETSessions,
+		Sessions: map[string]api.ServerMessage_Presence_Status{},
+		Full:     full,
+	}
+	if full {
+		for username, status := range r.local {
+			envelope.Sessions[username] = status
+		}
+	} else {
+		for username := range r.dirty {
+			envelope.Sessions[username] = r.local[username]
+		}
+	}
+	r.dirty = map[string]struct{}{}
+	r.mutex.Unlock()
+
+	if !full && len(envelope.Sessions) == 0 {
+		return
+	}
+	_, _ = r.bus.Publish(context.Background(), "", envelope)
+}
+
+// expire 删除长时间没有消息的节点
+func (r *SessionRegistry) expire() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	deadline := time.Now().Add(-r.options.Expiration)
+	for node, remote := range r.remote {
+		if !remote.updatedAt.After(deadline) {
+			delete(r.remote, node)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+)
+
+const (
+	online = api.ServerMessage_Presence_Online
+	away   = api.ServerMessage_Presence_Away
+)
+
+// newTestSessionRegistry 注册到 bus 上，只处理在线用户的同步。测试结束时由调用方 Close
+func newTestSessionRegistry(bus MessageBus, syncInterval time.Duration) *SessionRegistry {
+	r := NewSessionRegistryWithOptions(bus, &SessionRegistryOptions{SyncInterval: syncInterval, Expiration: 3 * syncInterval})
+	bus.Subscribe(func(envelope *api.ClusterEnvelope) int {
+		if envelope.Type == api.ClusterEnvelope_CETSessions {
+			r.Handle(envelope)
+		}
+		return 0
+	})
+	return r
+}
+
+func waitFor(t *testing.T, name string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", name)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSessionRegistrySync(t *testing.T) {
+	hub := t.Name()
+	a := newTestSessionRegistry(newTestLocalMessageBus(t, "a", hub), time.Hour)
+	b := newTestSessionRegistry(newTestLocalMessageBus(t, "b", hub), time.Hour)
+	defer b.Close()
+
+	// 增量同步
+	a.Set("alice", online)
+	waitFor(t, "alice online on a", func() bool {
+		return reflect.DeepEqual(b.Nodes("alice"), []string{"a"})
+	})
+	a.Set("alice", away)
+	waitFor(t, "alice away on a", func() bool {
+		status, ok := b.Status("alice")
+		return ok && status == away
+	})
+	a.Set("alice", api.ServerMessage_Presence_Offline)
+	waitFor(t, "alice offline on a", func() bool {
+		return len(b.Nodes("alice")) == 0
+	})
+
+	// 关闭时广播空的全量，其他节点上的记录被清空
+	a.Set("bob", online)
+	waitFor(t, "bob online on a", func() bool {
+		return len(b.Nodes("bob")) == 1
+	})
+	a.Close()
+	if nodes := b.Nodes("bob"); len(nodes) != 0 {
+		t.Fatalf("nodes of bob after a closed = %v, want none", nodes)
+	}
+}
+
+func TestSessionRegistryHandle(t *testing.T) {
+	r := newTestSessionRegistry(newTestLocalMessageBus(t, "a", ""), time.Hour)
+	defer r.Close()
+
+	for _, c := range []struct {
+		name     string
+		envelope *api.ClusterEnvelope
+		want     map[string][]string
+	}{
+		{
+			name:     "full",
+			envelope: &api.ClusterEnvelope{Node: "b", Full: true, Sessions: map[string]api.ServerMessage_Presence_Status{"alice": online, "bob": online}},
+			want:     map[string][]string{"alice": {"b"}, "bob": {"b"}},
+		},
+		{
+			name:     "incremental",
+			envelope: &api.ClusterEnvelope{Node: "b", Sessions: map[string]api.ServerMessage_Presence_Status{"alice": api.ServerMessage_Presence_Offline, "carol": online}},
+			want:     map[string][]string{"alice": nil, "bob": {"b"}, "carol": {"b"}},
+		},
+		{
+			name:     "other node",
+			envelope: &api.ClusterEnvelope{Node: "c", Sessions: map[string]api.ServerMessage_Presence_Status{"bob": away}},
+			want:     map[string][]string{"bob": {"b", "c"}},
+		},
+		{
+			// 全量替换这个节点之前的记录，比如节点重启后丢失了增量
+			name:     "full replaces",
+			envelope: &api.ClusterEnvelope{Node: "b", Full: true, Sessions: map[string]api.ServerMessage_Presence_Status{"dave": online}},
+			want:     map[string][]string{"bob": {"c"}, "carol": nil, "dave": {"b"}},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.envelope.Type = api.ClusterEnvelope_CETSessions
+			r.Handle(c.envelope)
+			for username, want := range c.want {
+				if got := r.Nodes(username); !reflect.DeepEqual(got, want) {
+					t.Fatalf("Nodes(%s) = %v, want %v", username, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionRegistryExpire(t *testing.T) {
+	r := newTestSessionRegistry(newTestLocalMessageBus(t, "a", ""), time.Hour)
+	defer r.Close()
+	for _, node := range []string{"alive", "dead"} {
+		r.Handle(&api.ClusterEnvelope{Type: api.ClusterEnvelope_CETSessions, Node: node, Full: true,
+			Sessions: map[string]api.ServerMessage_Presence_Status{"alice": online}})
+	}
+
+	// dead 节点超过 Expiration 没有消息
+	r.mutex.Lock()
+	r.remote["dead"].updatedAt = time.Now().Add(-r.options.Expiration - time.Second)
+	r.mutex.Unlock()
+
+	if got := r.Nodes("alice"); !reflect.DeepEqual(got, []string{"alive"}) {
+		t.Fatalf("Nodes = %v, want [alive]", got)
+	}
+	r.expire()
+	r.mutex.RLock()
+	_, ok := r.remote["dead"]
+	r.mutex.RUnlock()
+	if ok {
+		t.Fatalf("dead node is not expired")
+	}
+
+	// 下线的节点重新发来消息后恢复
+	r.Handle(&api.ClusterEnvelope{Type: api.ClusterEnvelope_CETSessions, Node: "dead", Full: true,
+		Sessions: map[string]api.ServerMessage_Presence_Status{"alice": online}})
+	if got := r.Nodes("alice"); !reflect.DeepEqual(got, []string{"alive", "dead"}) {
+		t.Fatalf("Nodes = %v, want [alive dead]", got)
+	}
+}
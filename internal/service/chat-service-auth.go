@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type AuthOptions struct {
+	BcryptCost int `dft:"10"`
+	// token 签名密钥，为空时随机生成，重启之后已经签发的 token 全部失效
+	TokenSecret            string
+	AccessTokenExpiration  time.Duration `dft:"1h"`
+	RefreshTokenExpiration time.Duration `dft:"720h"`
+	// 使用双向 TLS 时，把客户端证书的 CommonName 作为用户名，不再需要密码
+	ClientCertAuth bool
+}
+
+// identity 拦截器认证的身份，来自 access token 或者客户端证书
+type identity struct {
+	username string
+	// token 的会话 ID，证书认证时为空
+	tokenID string
+}
+
+type identityKey struct{}
+
+func identityFromContext(ctx context.Context) (*identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*identity)
+	return id, ok
+}
+
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *authServerStream) Context() context.Context {
+	return ss.ctx
+}
+
+// StreamServerInterceptor 优先校验 metadata 中的 access token，其次是客户端证书，通过后 Chat 不再需要密码
+// 都没有时放行，由 Chat 的第一条消息做密码认证
+func (s *ChatService) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id, err := s.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		if id == nil {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &authServerStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), identityKey{}, id),
+		})
+	}
+}
+
+// UnaryServerInterceptor 和 StreamServerInterceptor 一样认证，没有凭证时放行，由需要身份的方法自己拒绝
+func (s *ChatService) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id, err := s.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if id == nil {
+			return handler(ctx, req)
+		}
+		return handler(context.WithValue(ctx, identityKey{}, id), req)
+	}
+}
+
+func (s *ChatService) authenticate(ctx context.Context) (*identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) != 0 {
+		if !strings.HasPrefix(values[0], "Bearer ") {
+			return nil, status.Error(codes.Unauthenticated, "authorization 格式错误")
+		}
+		claims, err := s.parseToken(strings.TrimPrefix(values[0], "Bearer "), tokenTypeAccess)
+		if err == errInvalidToken {
+			return nil, status.Error(codes.Unauthenticated, "access token 无效")
+		}
+		if err != nil {
+			s.rpcLog.Error(err)
+			return nil, status.Error(codes.Internal, "内部错误")
+		}
+		return &identity{username: claims.Username, tokenID: claims.session()}, nil
+	}
+
+	if !s.options.Auth.ClientCertAuth {
+		return nil, nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	// 只信任经过 ClientCA 校验的证书
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	username := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+	if username == "" {
+		return nil, status.Error(codes.Unauthenticated, "客户端证书没有 CommonName")
+	}
+	return &identity{username: username}, nil
+}
+
+// login 注册或者校验密码。用户名密码错误不区分用户是否存在，避免被用来探测用户名
+func (s *ChatService) login(stream messageSender, auth *api.ClientMessage_Auth) error {
+	if auth.Username == "" || auth.Password == "" {
+		return s.setErr(stream, api.ServerMessage_Err_AuthFailed, "用户名和密码不能为空")
+	}
+
+	if auth.Register {
+		hash, err := bcrypt.GenerateFromPassword([]byte(auth.Password), s.options.Auth.BcryptCost)
+		if err != nil {
+			s.rpcLog.Error(errors.Wrap(err, "bcrypt.GenerateFromPassword failed"))
+			return s.setErr(stream, api.ServerMessage_Err_Internal, "内部错误")
+		}
+		err = s.userStorage.PutUser(&storage.User{
+			Username:     auth.Username,
+			PasswordHash: string(hash),
+			CreatedAt:    time.Now(),
+		})
+		if errors.Cause(err) == storage.ErrUserExists {
+			return s.setErr(stream, api.ServerMessage_Err_UserExists, "用户已存在")
+		}
+		if err != nil {
+			s.rpcLog.Error(errors.WithMessage(err, "userStorage.PutUser failed"))
+			return s.setErr(stream, api.ServerMessage_Err_Internal, "内部错误")
+		}
+		return nil
+	}
+
+	user, err := s.userStorage.GetUser(auth.Username)
+	if errors.Cause(err) == storage.ErrUserNotFound {
+		// 用户不存在时也校验一次密码，耗时和密码错误一样
+		_ = bcrypt.CompareHashAndPassword(s.dummyPasswordHash, []byte(auth.Password))
+		return s.setErr(stream, api.ServerMessage_Err_AuthFailed, "用户名或密码错误")
+	}
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "userStorage.GetUser failed"))
+		return s.setErr(stream, api.ServerMessage_Err_Internal, "内部错误")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(auth.Password)); err != nil {
+		return s.setErr(stream, api.ServerMessage_Err_AuthFailed, "用户名或密码错误")
+	}
+
+	return nil
+}
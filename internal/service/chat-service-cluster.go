@@ -0,0 +1,64 @@
+package service
+
+import (
+	"expvar"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+)
+
+// 转发给其他节点时队列满丢弃的消息数
+var clusterDropped = expvar.NewInt("chat_cluster_dropped_total")
+
+// handleEnvelope 处理其他节点通过总线发来的消息，返回投递成功的本地会话数
+func (s *ChatService) handleEnvelope(envelope *api.ClusterEnvelope) int {
+	switch envelope.Type {
+	case api.ClusterEnvelope_CETDeliver:
+		if envelope.Message == nil {
+			return 0
+		}
+		// 只投递给本节点的会话，不再转发，避免节点之间循环
+		return s.sendToLocalSessions(envelope.Username, envelope.ExceptSession, envelope.Message)
+	case api.ClusterEnvelope_CETSessions:
+		s.registry.Handle(envelope)
+	case api.ClusterEnvelope_CETRevoke:
+		s.disconnectToken(envelope.Username, envelope.TokenId)
+	}
+	return 0
+}
+
+// sendToRemoteSessions 转发给用户在其他节点上的会话，返回放进队列的节点数。和本地会话一样，放进队列就算投递，不等对方处理
+// 队列满或者发送失败只记录日志，消息已经保存，重连后会补发
+func (s *ChatService) sendToRemoteSessions(username string, exceptID string, res *api.ServerMessage) int {
+	delivered := 0
+	for _, node := range s.registry.Nodes(username) {
+		if !s.publisher.Publish(node, &api.ClusterEnvelope{
+			Type:          api.ClusterEnvelope_CETDeliver,
+			Username:      username,
+			ExceptSession: exceptID,
+			Message:       res,
+		}) {
+			clusterDropped.Add(1)
+			s.rpcLog.Warn(map[string]interface{}{
+				"message":  "cluster publish queue overflow, drop",
+				"node":     node,
+				"username": username,
+			})
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+func (s *ChatService) publishFailed(node string, err error) {
+	s.rpcLog.Warn(errors.WithMessagef(err, "publish to node [%s] failed", node))
+}
+
+// closeCluster 通知其他节点本节点的会话已经下线，然后断开总线
+func (s *ChatService) closeCluster() error {
+	s.publisher.Close()
+	s.registry.Close()
+	return errors.Wrap(s.bus.Close(), "bus.Close failed")
+}
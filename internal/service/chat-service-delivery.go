@@ -0,0 +1,65 @@
+package service
+
+import (
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+)
+
+// undelivered 客户端没有本地记录时，登录只投递离线队列中的消息，更早的消息通过 GetHistory 分页读
+// 有 lastSeq 时按 lastSeq 补发，多个设备各自同步
+func (s *ChatService) undelivered(auth *api.ClientMessage_Auth) ([]*storage.ChatMessage, error) {
+	if auth.LastSeq > 0 {
+		messages, err := s.storage.GetMessageByUser(auth.Username, auth.LastSeq+1)
+		if err != nil {
+			return nil, errors.WithMessage(err, "storage.GetMessageByUser failed")
+		}
+		return messages, nil
+	}
+	messages, err := s.storage.GetUndelivered(auth.Username)
+	if err != nil {
+		return nil, errors.WithMessage(err, "storage.GetUndelivered failed")
+	}
+	return messages, nil
+}
+
+// markDelivered 记录已经写到 stream 的私聊消息的投递位置，给新投递的消息的发送方发送投递回执
+// 队列满时丢弃的消息没有写到 stream，投递位置停在它之前，下次登录时从离线队列重新投递
+func (s *ChatService) markDelivered(sess *session) {
+	seq := sess.deliveredLimit()
+	if seq <= sess.deliveredSeq {
+		return
+	}
+	messages, err := s.storage.MarkDelivered(sess.username, seq)
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "storage.MarkDelivered failed"))
+		return
+	}
+	sess.deliveredSeq = seq
+
+	// 按发送方合并，发给发送方的所有会话
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	deliveries := map[string]*api.ServerMessage_Delivery{}
+	var senders []string
+	for _, message := range messages {
+		delivery, ok := deliveries[message.From]
+		if !ok {
+			delivery = &api.ServerMessage_Delivery{To: sess.username, Timestamp: now}
+			deliveries[message.From] = delivery
+			senders = append(senders, message.From)
+		}
+		delivery.Items = append(delivery.Items, &api.ServerMessage_Delivery_Item{
+			MsgId: message.MsgID,
+			Seq:   message.Seq,
+		})
+	}
+	for _, sender := range senders {
+		s.sendToSessions(sender, "", &api.ServerMessage{
+			Type:     api.ServerMessage_SMTDelivery,
+			Delivery: deliveries[sender],
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+)
+
+// handleEdit 修改后的消息发给会话双方或者房间成员的所有在线会话，包括自己，客户端据此更新显示
+func (s *ChatService) handleEdit(sess *session, msg *api.ClientMessage_Edit) error {
+	if msg.Room != "" {
+		message, err := s.storage.EditRoomMessage(msg.Room, sess.username, msg.Seq, msg.Content)
+		if err != nil {
+			return s.messageErr(sess, err)
+		}
+		s.sendToRoom(msg.Room, &api.ServerMessage{
+			Type: api.ServerMessage_SMTEdit,
+			Chat: chatMessageToApi(message),
+		})
+		return nil
+	}
+
+	message, peerMessage, err := s.storage.EditMessage(sess.username, msg.Seq, msg.Content)
+	if err != nil {
+		return s.messageErr(sess, err)
+	}
+	s.sendToSessions(sess.username, "", &api.ServerMessage{
+		Type: api.ServerMessage_SMTEdit,
+		Chat: chatMessageToApi(message),
+	})
+	// 只有发送方能修改，对方的那一份在接收方的信箱里
+	if peerMessage != nil {
+		s.sendToSessions(message.To, "", &api.ServerMessage{
+			Type: api.ServerMessage_SMTEdit,
+			Chat: chatMessageToApi(peerMessage),
+		})
+	}
+	return nil
+}
+
+// handleDelete 为所有人删除时通知所有人，只为自己删除时只通知自己的会话
+func (s *ChatService) handleDelete(sess *session, msg *api.ClientMessage_Delete) error {
+	res := func(seq int64) *api.ServerMessage {
+		return &api.ServerMessage{
+			Type: api.ServerMessage_SMTDelete,
+			Delete: &api.ServerMessage_Delete{
+				Room:        msg.Room,
+				Seq:         seq,
+				ForEveryone: msg.ForEveryone,
+			},
+		}
+	}
+
+	if msg.Room != "" {
+		if _, err := s.storage.DeleteRoomMessage(msg.Room, sess.username, msg.Seq, msg.ForEveryone); err != nil {
+			return s.messageErr(sess, err)
+		}
+		if msg.ForEveryone {
+			s.sendToRoom(msg.Room, res(msg.Seq))
+		} else {
+			s.sendToSessions(sess.username, "", res(msg.Seq))
+		}
+		return nil
+	}
+
+	message, peerMessage, err := s.storage.DeleteMessage(sess.username, msg.Seq, msg.ForEveryone)
+	if err != nil {
+		return s.messageErr(sess, err)
+	}
+	s.sendToSessions(sess.username, "", res(message.Seq))
+	if peerMessage != nil {
+		s.sendToSessions(message.To, "", res(peerMessage.Seq))
+	}
+	return nil
+}
+
+// sendToRoom 发给房间所有成员的在线会话
+func (s *ChatService) sendToRoom(room string, res *api.ServerMessage) {
+	members, err := s.storage.GetRoomMembers(room)
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "storage.GetRoomMembers failed"))
+		return
+	}
+	for _, member := range members {
+		s.sendToSessions(member, "", res)
+	}
+}
+
+// messageErr 编辑和删除消息的业务错误只通知客户端
+func (s *ChatService) messageErr(stream messageSender, err error) error {
+	switch errors.Cause(err) {
+	case storage.ErrMessageNotFound:
+		return s.notifyErr(stream, api.ServerMessage_Err_MessageNotFound, "消息不存在")
+	case storage.ErrNotMessageSender:
+		return s.notifyErr(stream, api.ServerMessage_Err_NotMessageSender, "只能修改自己发送的消息")
+	}
+	return s.roomErr(stream, err)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type HistoryOptions struct {
+	// 请求没有指定 limit 时每页的消息数
+	DefaultLimit int `dft:"50"`
+	MaxLimit     int `dft:"200"`
+}
+
+// GetHistory 客户端滚动时按需加载历史消息，不再依赖登录时补发整个信箱
+func (s *ChatService) GetHistory(ctx context.Context, req *api.GetHistoryReq) (*api.GetHistoryRes, error) {
+	id, ok := identityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "需要 access token 或者客户端证书")
+	}
+	if (req.Peer == "") == (req.Room == "") {
+		return nil, status.Error(codes.InvalidArgument, "peer 和 room 必须指定一个")
+	}
+	if req.Before < 0 || req.After < 0 || req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "参数不能为负数")
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = s.options.History.DefaultLimit
+	}
+	if limit > s.options.History.MaxLimit {
+		limit = s.options.History.MaxLimit
+	}
+
+	// 多取一条判断是否还有更多
+	messages, err := s.storage.GetHistory(id.username, req.Peer, req.Room, req.Before, req.After, limit+1)
+	switch errors.Cause(err) {
+	case nil:
+	case storage.ErrRoomNotFound:
+		return nil, status.Error(codes.NotFound, "房间不存在")
+	case storage.ErrNotRoomMember:
+		return nil, status.Error(codes.PermissionDenied, "不是房间成员")
+	default:
+		s.rpcLog.Error(errors.WithMessage(err, "storage.GetHistory failed"))
+		return nil, status.Error(codes.Internal, "内部错误")
+	}
+
+	res := &api.GetHistoryRes{}
+	if len(messages) > limit {
+		res.HasMore = true
+		// 往前翻时多出来的是最早的一条
+		if req.Before > 0 || req.After == 0 {
+			messages = messages[1:]
+		} else {
+			messages = messages[:limit]
+		}
+	}
+	for _, message := range messages {
+		res.Messages = append(res.Messages, chatMessageToApi(message))
+	}
+	return res, nil
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"sync"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// OverflowPolicyDropOldest 丢弃队列中最旧的消息
+	OverflowPolicyDropOldest = "DropOldest"
+	// OverflowPolicyDisconnect 断开慢连接，客户端重连后通过 lastSeq 补发
+	OverflowPolicyDisconnect = "Disconnect"
+	// OverflowPolicySpill 聊天消息已经写入存储，丢弃后等队列空了再从存储补发
+	// 输入状态和在线状态直接丢弃；回执丢弃后按存储中的已读位置补发未读数，和重连时一样
+	// 其他消息比如编辑和删除不能补发，队列满时和 Disconnect 一样断开
+	OverflowPolicySpill = "Spill"
+)
+
+type OutboundOptions struct {
+	QueueSize      int    `dft:"256"`
+	OverflowPolicy string `dft:"Spill"`
+}
+
+// 发送队列的监控指标，通过 expvar 暴露，chat-server 配置 MetricsAddress 后可以在 /debug/vars 读取
+var (
+	outboundQueueDepth   = expvar.NewInt("chat_outbound_queue_depth")
+	outboundQueueSession = expvar.NewMap("chat_outbound_queue_session_depth")
+	outboundDropped      = expvar.NewInt("chat_outbound_dropped_total")
+	outboundSpilled      = expvar.NewInt("chat_outbound_spilled_total")
+	outboundDisconnected = expvar.NewInt("chat_outbound_disconnected_total")
+)
+
+// session 一个 Chat 连接。stream 只能由 writeLoop 写入，其他 goroutine 通过队列发送
+type session struct {
+	id       string
+	username string
+	stream   api.ChatService_ChatServer
+	// 登录使用的 token 的会话 ID，token 注销时断开会话
+	tokenID string
+	typing  typingState
+
+	queue   chan *api.ServerMessage
+	depth   *expvar.Int
+	ctx     context.Context
+	cancel  context.CancelFunc
+	closing chan struct{}
+	done    chan struct{}
+
+	// spill 状态，记录被丢弃的最小序号，房间消息按房间记录
+	spillMutex   sync.Mutex
+	spilled      bool
+	spillSeq     int64
+	spillRoomSeq map[string]int64
+	// 有回执被丢弃，队列空了之后补发未读数
+	spillReceipt bool
+	// stop 之后 deliver 不再放进队列，由 spillMutex 保护
+	stopped bool
+	// DropOldest 丢弃的最小私聊消息序号，投递位置不能超过它，这些消息留在离线队列中，由 spillMutex 保护
+	droppedSeq int64
+
+	// 已经写到 stream 的最大信箱序号和已经记录的投递位置，只在 writeLoop 中使用
+	writtenSeq   int64
+	deliveredSeq int64
+}
+
+func newSession(stream api.ChatService_ChatServer, username string, queueSize int) *session {
+	return &session{
+		id:           newSessionID(),
+		username:     username,
+		stream:       stream,
+		queue:        make(chan *api.ServerMessage, queueSize),
+		depth:        new(expvar.Int),
+		closing:      make(chan struct{}),
+		done:         make(chan struct{}),
+		spillRoomSeq: map[string]int64{},
+		typing:       typingState{entries: map[string]*typingEntry{}},
+	}
+}
+
+func (sess *session) start(ctx context.Context, cancel context.CancelFunc) {
+	sess.ctx = ctx
+	sess.cancel = cancel
+	outboundQueueSession.Set(sess.id, sess.depth)
+}
+
+// stop 停止接收新消息，等待 writeLoop 把队列中的消息发完
+func (sess *session) stop() {
+	// deliver 在 spillMutex 中检查 stopped 并放进队列，这里之后不会再有消息进入队列，队列深度不会漂移
+	sess.spillMutex.Lock()
+	sess.stopped = true
+	sess.spillMutex.Unlock()
+	close(sess.closing)
+	<-sess.done
+	outboundQueueDepth.Add(-int64(len(sess.queue)))
+	outboundQueueSession.Delete(sess.id)
+}
+
+// Send 会话自己的回复，队列满时阻塞等待，相当于对这个客户端的读做了限流
+func (sess *session) Send(res *api.ServerMessage) error {
+	select {
+	case sess.queue <- res:
+		sess.incr(1)
+		return nil
+	case <-sess.closing:
+		return errors.New("session closed")
+	case <-sess.ctx.Done():
+		return errors.Wrap(sess.ctx.Err(), "session canceled")
+	}
+}
+
+func (sess *session) incr(n int64) {
+	sess.depth.Add(n)
+	outboundQueueDepth.Add(n)
+}
+
+// deliver 其他用户发来的消息，不能阻塞发送方，队列满时按策略处理
+// 放进队列或者等待补发时返回 true
+func (s *ChatService) deliver(sess *session, res *api.ServerMessage) bool {
+	sess.spillMutex.Lock()
+	defer sess.spillMutex.Unlock()
+
+	if sess.stopped {
+		return false
+	}
+
+	// 正在补发时后面的聊天消息也要走补发，保证顺序，其他消息照常放进队列
+	chat := res.Type == api.ServerMessage_SMTChat && res.Chat != nil
+	if sess.spilled && chat {
+		return s.spill(sess, res)
+	}
+
+	for {
+		select {
+		case sess.queue <- res:
+			sess.incr(1)
+			return true
+		default:
+		}
+
+		switch s.options.Outbound.OverflowPolicy {
+		case OverflowPolicyDisconnect:
+			s.disconnectSlow(sess)
+			return false
+		case OverflowPolicySpill:
+			switch {
+			case chat:
+				sess.spilled = true
+				return s.spill(sess, res)
+			case res.Type == api.ServerMessage_SMTTyping || res.Type == api.ServerMessage_SMTPresence:
+				outboundDropped.Add(1)
+				return false
+			case res.Type == api.ServerMessage_SMTRead || res.Type == api.ServerMessage_SMTDelivery:
+				outboundDropped.Add(1)
+				sess.spillReceipt = true
+				return false
+			}
+			s.disconnectSlow(sess)
+			return false
+		default:
+			select {
+			case old := <-sess.queue:
+				sess.incr(-1)
+				outboundDropped.Add(1)
+				if old.Type == api.ServerMessage_SMTChat && old.Chat != nil && old.Chat.Room == "" &&
+					(sess.droppedSeq == 0 || old.Chat.Seq < sess.droppedSeq) {
+					sess.droppedSeq = old.Chat.Seq
+				}
+			default:
+			}
+		}
+	}
+}
+
+// disconnectSlow 断开慢连接，客户端重连后通过 lastSeq 补发
+func (s *ChatService) disconnectSlow(sess *session) {
+	outboundDisconnected.Add(1)
+	s.rpcLog.Warn(map[string]interface{}{
+		"message":  "outbound queue overflow, disconnect",
+		"session":  sess.id,
+		"username": sess.username,
+	})
+	sess.cancel()
+}
+
+// spill 记录被丢弃的聊天消息的序号，调用方持有 spillMutex
+func (s *ChatService) spill(sess *session, res *api.ServerMessage) bool {
+	outboundSpilled.Add(1)
+
+	chat := res.Chat
+	if chat.Room != "" {
+		if seq, ok := sess.spillRoomSeq[chat.Room]; !ok || chat.Seq < seq {
+			sess.spillRoomSeq[chat.Room] = chat.Seq
+		}
+		return true
+	}
+	if sess.spillSeq == 0 || chat.Seq < sess.spillSeq {
+		sess.spillSeq = chat.Seq
+	}
+	return true
+}
+
+func (s *ChatService) writeLoop(sess *session) {
+	defer close(sess.done)
+	// 无论怎么退出，已经写出去的消息都要记录投递位置
+	defer s.markDelivered(sess)
+
+	for {
+		select {
+		case <-sess.ctx.Done():
+			return
+		case <-sess.closing:
+			// 连接正常结束，把剩下的消息发完，比如最后的错误信息
+			for {
+				select {
+				case res := <-sess.queue:
+					sess.incr(-1)
+					if !s.write(sess, res) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case res := <-sess.queue:
+			sess.incr(-1)
+			if !s.write(sess, res) {
+				sess.cancel()
+				return
+			}
+			if len(sess.queue) == 0 {
+				if err := s.resync(sess); err != nil {
+					s.rpcLog.Error(errors.WithMessage(err, "resync failed"))
+					sess.cancel()
+					return
+				}
+				s.markDelivered(sess)
+			}
+		}
+	}
+}
+
+func (s *ChatService) write(sess *session, res *api.ServerMessage) bool {
+	if err := sess.stream.Send(res); err != nil {
+		s.rpcLog.Error(errors.Wrapf(err, "stream.Send to session [%s] failed", sess.id))
+		return false
+	}
+	s.rpcLog.Info(res)
+	// 私聊消息的序号属于这个用户的信箱，队列空了之后一起记录投递位置
+	if res.Type == api.ServerMessage_SMTChat && res.Chat != nil && res.Chat.Room == "" && res.Chat.Seq > sess.writtenSeq {
+		sess.writtenSeq = res.Chat.Seq
+	}
+	return true
+}
+
+// deliveredLimit 可以记录的投递位置，不超过被丢弃或者还在等待补发的私聊消息
+func (sess *session) deliveredLimit() int64 {
+	sess.spillMutex.Lock()
+	defer sess.spillMutex.Unlock()
+
+	seq := sess.writtenSeq
+	if sess.droppedSeq != 0 && sess.droppedSeq <= seq {
+		seq = sess.droppedSeq - 1
+	}
+	if sess.spillSeq != 0 && sess.spillSeq <= seq {
+		seq = sess.spillSeq - 1
+	}
+	return seq
+}
+
+// resync 从存储补发 spill 期间丢弃的消息。补发过程中又有消息被丢弃时继续补发
+// 补发的消息可能和已经发出的消息重复，客户端按序号去重
+func (s *ChatService) resync(sess *session) error {
+	sess.spillMutex.Lock()
+	receipt := sess.spillReceipt
+	sess.spillReceipt = false
+	sess.spillMutex.Unlock()
+	if receipt {
+		res, err := s.unreadMessage(sess.username)
+		if err != nil {
+			return err
+		}
+		if !s.write(sess, res) {
+			return errors.New("write failed")
+		}
+	}
+
+	for {
+		sess.spillMutex.Lock()
+		if !sess.spilled {
+			sess.spillMutex.Unlock()
+			return nil
+		}
+		seq, roomSeq := sess.spillSeq, sess.spillRoomSeq
+		if seq == 0 && len(roomSeq) == 0 {
+			sess.spilled = false
+			sess.spillMutex.Unlock()
+			return nil
+		}
+		sess.spillSeq, sess.spillRoomSeq = 0, map[string]int64{}
+		sess.spillMutex.Unlock()
+
+		if seq != 0 {
+			messages, err := s.storage.GetMessageByUser(sess.username, seq)
+			if err != nil {
+				return errors.WithMessage(err, "storage.GetMessageByUser failed")
+			}
+			for _, message := range messages {
+				if !s.write(sess, &api.ServerMessage{Type: api.ServerMessage_SMTChat, Chat: chatMessageToApi(message)}) {
+					return errors.New("write failed")
+				}
+			}
+		}
+		for room, seq := range roomSeq {
+			messages, err := s.storage.GetMessageByRoom(room, sess.username, seq)
+			if err != nil {
+				return errors.WithMessage(err, "storage.GetMessageByRoom failed")
+			}
+			for _, message := range messages {
+				if !s.write(sess, &api.ServerMessage{Type: api.ServerMessage_SMTChat, Chat: chatMessageToApi(message)}) {
+					return errors.New("write failed")
+				}
+			}
+		}
+	}
+}
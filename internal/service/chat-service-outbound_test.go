@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+)
+
+func testChatMessage(seq int64) *api.ServerMessage {
+	return &api.ServerMessage{Type: api.ServerMessage_SMTChat, Chat: &api.ServerMessage_Chat{From: "alice", To: "bob", Seq: seq}}
+}
+
+func testPresenceMessage() *api.ServerMessage {
+	return &api.ServerMessage{Type: api.ServerMessage_SMTPresence, Presence: &api.ServerMessage_Presence{Username: "alice"}}
+}
+
+func testTypingMessage() *api.ServerMessage {
+	return &api.ServerMessage{Type: api.ServerMessage_SMTTyping, Typing: &api.ServerMessage_Typing{From: "alice", Typing: true}}
+}
+
+func testReadMessage() *api.ServerMessage {
+	return &api.ServerMessage{Type: api.ServerMessage_SMTRead, ReadReceipt: &api.ServerMessage_ReadReceipt{Reader: "alice", Peer: "bob", Seq: 1}}
+}
+
+func testEditMessage() *api.ServerMessage {
+	return &api.ServerMessage{Type: api.ServerMessage_SMTEdit, Chat: &api.ServerMessage_Chat{From: "alice", To: "bob", Seq: 1}}
+}
+
+// testChatStream 记录 writeLoop 写出的消息
+type testChatStream struct {
+	api.ChatService_ChatServer
+
+	mutex sync.Mutex
+	sent  []*api.ServerMessage
+}
+
+func (s *testChatStream) Send(res *api.ServerMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sent = append(s.sent, res)
+	return nil
+}
+
+func TestDeliverOverflowPolicy(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		policy   string
+		messages []*api.ServerMessage
+		// 每次 deliver 的返回值
+		wantDelivered []bool
+		// 队列中剩下的聊天消息序号，其他消息为 0
+		wantQueue    []int64
+		wantCanceled bool
+		wantSpillSeq int64
+		wantReceipt  bool
+		// DropOldest 丢弃的最小序号，投递位置不能超过它
+		wantDroppedSeq int64
+	}{
+		{
+			name:          "drop oldest",
+			policy:        OverflowPolicyDropOldest,
+			messages:      []*api.ServerMessage{testChatMessage(1), testChatMessage(2), testChatMessage(3)},
+			wantDelivered: []bool{true, true, true},
+			wantQueue:     []int64{2, 3},
+			// 被丢弃的 1 留在离线队列中
+			wantDroppedSeq: 1,
+		},
+		{
+			name:          "drop oldest non chat",
+			policy:        OverflowPolicyDropOldest,
+			messages:      []*api.ServerMessage{testPresenceMessage(), testChatMessage(2), testChatMessage(3)},
+			wantDelivered: []bool{true, true, true},
+			wantQueue:     []int64{2, 3},
+		},
+		{
+			name:          "disconnect",
+			policy:        OverflowPolicyDisconnect,
+			messages:      []*api.ServerMessage{testChatMessage(1), testChatMessage(2), testChatMessage(3)},
+			wantDelivered: []bool{true, true, false},
+			wantQueue:     []int64{1, 2},
+			wantCanceled:  true,
+		},
+		{
+			name:          "spill",
+			policy:        OverflowPolicySpill,
+			messages:      []*api.ServerMessage{testChatMessage(1), testChatMessage(2), testChatMessage(3), testChatMessage(4)},
+			wantDelivered: []bool{true, true, true, true},
+			wantQueue:     []int64{1, 2},
+			wantSpillSeq:  3,
+		},
+		{
+			// 在线状态和输入状态过时就没有意义，直接丢弃
+			name:          "spill presence",
+			policy:        OverflowPolicySpill,
+			messages:      []*api.ServerMessage{testChatMessage(1), testChatMessage(2), testPresenceMessage()},
+			wantDelivered: []bool{true, true, false},
+			wantQueue:     []int64{1, 2},
+		},
+		{
+			name:          "spill typing",
+			policy:        OverflowPolicySpill,
+			messages:      []*api.ServerMessage{testChatMessage(1), testChatMessage(2), testTypingMessage()},
+			wantDelivered: []bool{true, true, false},
+			wantQueue:     []int64{1, 2},
+		},
+		{
+			// 回执丢弃后按已读位置补发未读数
+			name:          "spill receipt",
+			policy:        OverflowPolicySpill,
+			messages:      []*api.ServerMessage{testChatMessage(1), testChatMessage(2), testReadMessage()},
+			wantDelivered: []bool{true, true, false},
+			wantQueue:     []int64{1, 2},
+			wantReceipt:   true,
+		},
+		{
+			// 编辑不能补发，只能断开让客户端重新拉取
+			name:          "spill edit",
+			policy:        OverflowPolicySpill,
+			messages:      []*api.ServerMessage{testChatMessage(1), testChatMessage(2), testEditMessage()},
+			wantDelivered: []bool{true, true, false},
+			wantQueue:     []int64{1, 2},
+			wantCanceled:  true,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestChatService(t, c.policy, 2)
+			sess := newTestSession(t, s, "bob", "")
+
+			for i, message := range c.messages {
+				if delivered := s.deliver(sess, message); delivered != c.wantDelivered[i] {
+					t.Fatalf("deliver[%d] = %v, want %v", i, delivered, c.wantDelivered[i])
+				}
+			}
+			if canceled(sess) != c.wantCanceled {
+				t.Fatalf("canceled = %v, want %v", canceled(sess), c.wantCanceled)
+			}
+			if sess.spillSeq != c.wantSpillSeq {
+				t.Fatalf("spillSeq = %d, want %d", sess.spillSeq, c.wantSpillSeq)
+			}
+			if sess.spillReceipt != c.wantReceipt {
+				t.Fatalf("spillReceipt = %v, want %v", sess.spillReceipt, c.wantReceipt)
+			}
+			if sess.droppedSeq != c.wantDroppedSeq {
+				t.Fatalf("droppedSeq = %d, want %d", sess.droppedSeq, c.wantDroppedSeq)
+			}
+
+			queue := drainSession(sess)
+			if len(queue) != len(c.wantQueue) {
+				t.Fatalf("queue = %v, want %v", queue, c.wantQueue)
+			}
+			for i, message := range queue {
+				var seq int64
+				if message.Chat != nil {
+					seq = message.Chat.Seq
+				}
+				if seq != c.wantQueue[i] {
+					t.Fatalf("queue[%d] seq = %d, want %d", i, seq, c.wantQueue[i])
+				}
+			}
+
+			// 写出了队列中的全部消息，投递位置也不能越过被丢弃或者等待补发的消息
+			sess.writtenSeq = 4
+			want := int64(4)
+			if c.wantDroppedSeq != 0 {
+				want = c.wantDroppedSeq - 1
+			}
+			if c.wantSpillSeq != 0 {
+				want = c.wantSpillSeq - 1
+			}
+			if got := sess.deliveredLimit(); got != want {
+				t.Fatalf("deliveredLimit = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestResyncReceipt(t *testing.T) {
+	s := newTestChatService(t, OverflowPolicySpill, 2)
+	if _, _, err := s.storage.PutMessage("m1", "alice", "bob", "hello", 0, 0); err != nil {
+		t.Fatalf("PutMessage failed: %v", err)
+	}
+
+	stream := &testChatStream{}
+	sess := newSession(stream, "bob", 2)
+	sess.spillReceipt = true
+	if err := s.resync(sess); err != nil {
+		t.Fatalf("resync failed: %v", err)
+	}
+	if sess.spillReceipt {
+		t.Fatalf("spillReceipt not cleared")
+	}
+	if len(stream.sent) != 1 || stream.sent[0].Type != api.ServerMessage_SMTUnread {
+		t.Fatalf("sent = %v, want one unread", stream.sent)
+	}
+	counts := stream.sent[0].Unread.Counts
+	if len(counts) != 1 || counts[0].Peer != "alice" || counts[0].Count != 1 {
+		t.Fatalf("unread counts = %v, want alice 1", counts)
+	}
+}
+
+// TestDeliverAfterStop stop 之后 deliver 不能再放进队列，否则队列深度的指标只增不减
+func TestDeliverAfterStop(t *testing.T) {
+	s := newTestChatService(t, OverflowPolicySpill, 16)
+	base := outboundQueueDepth.Value()
+
+	for i := 0; i < 100; i++ {
+		sess := newSession(&testChatStream{}, "bob", 16)
+		ctx, cancel := context.WithCancel(context.Background())
+		sess.start(ctx, cancel)
+		go s.writeLoop(sess)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 8; j++ {
+				s.deliver(sess, testPresenceMessage())
+			}
+		}()
+		sess.stop()
+		wg.Wait()
+		cancel()
+
+		if s.deliver(sess, testPresenceMessage()) {
+			t.Fatalf("deliver after stop = true, want false")
+		}
+		if got := outboundQueueDepth.Value(); got != base {
+			t.Fatalf("outbound queue depth = %d, want %d", got, base)
+		}
+	}
+}
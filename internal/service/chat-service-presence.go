@@ -0,0 +1,93 @@
+package service
+
+import (
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+)
+
+func newPresenceMessage(username string, status api.ServerMessage_Presence_Status) *api.ServerMessage {
+	return &api.ServerMessage{
+		Type: api.ServerMessage_SMTPresence,
+		Presence: &api.ServerMessage_Presence{
+			Username:  username,
+			Status:    status,
+			Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		},
+	}
+}
+
+// broadcastPresence 通知在线的联系人状态变化
+func (s *ChatService) broadcastPresence(username string, status api.ServerMessage_Presence_Status) {
+	contacts, err := s.storage.GetContacts(username)
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "storage.GetContacts failed"))
+		return
+	}
+
+	res := newPresenceMessage(username, status)
+	for _, contact := range contacts {
+		s.sendToSessions(contact, "", res)
+	}
+}
+
+// setPresence 客户端设置的状态，只接受 Online 和 Away，状态没变化时不广播
+func (s *ChatService) setPresence(sess *session, status api.ServerMessage_Presence_Status) {
+	if status != api.ServerMessage_Presence_Online && status != api.ServerMessage_Presence_Away {
+		return
+	}
+
+	v, ok := s.conns.Load(sess.username)
+	if !ok {
+		return
+	}
+	u := v.(*userSessions)
+	u.mutex.Lock()
+	changed := u.status != status
+	u.status = status
+	u.mutex.Unlock()
+
+	if changed {
+		s.registry.Set(sess.username, status)
+		s.broadcastPresence(sess.username, status)
+	}
+}
+
+// presence 登录时发送在线联系人的当前状态
+func (s *ChatService) presence(stream messageSender, auth *api.ClientMessage_Auth) error {
+	contacts, err := s.storage.GetContacts(auth.Username)
+	if err != nil {
+		return errors.WithMessage(err, "storage.GetContacts failed")
+	}
+
+	for _, contact := range contacts {
+		status, ok := s.localStatus(contact)
+		if remote, found := s.registry.Status(contact); found && (!ok || remote == api.ServerMessage_Presence_Online) {
+			status, ok = remote, true
+		}
+		if !ok {
+			continue
+		}
+
+		if err := stream.Send(newPresenceMessage(contact, status)); err != nil {
+			s.rpcLog.Error(err)
+			return errors.Wrap(err, "stream.Send failed")
+		}
+	}
+
+	return nil
+}
+
+// localStatus 用户在本节点上的状态，不在本节点上时返回 false
+func (s *ChatService) localStatus(username string) (api.ServerMessage_Presence_Status, bool) {
+	v, ok := s.conns.Load(username)
+	if !ok {
+		return api.ServerMessage_Presence_Offline, false
+	}
+	u := v.(*userSessions)
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.status, true
+}
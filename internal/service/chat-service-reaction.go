@@ -0,0 +1,60 @@
+package service
+
+import (
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+)
+
+// 表情的最大字节数，和存储的列宽一致
+const maxEmojiLen = 64
+
+// handleReact 回应变化发给会话双方或者房间成员的所有在线会话，包括自己
+func (s *ChatService) handleReact(sess *session, msg *api.ClientMessage_React) error {
+	res := func(message *storage.ChatMessage) *api.ServerMessage {
+		return &api.ServerMessage{
+			Type: api.ServerMessage_SMTReact,
+			React: &api.ServerMessage_React{
+				Room:      msg.Room,
+				Seq:       message.Seq,
+				From:      sess.username,
+				Emoji:     msg.Emoji,
+				Remove:    msg.Remove,
+				Reactions: reactionsToApi(message.Reactions),
+			},
+		}
+	}
+
+	if msg.Room != "" {
+		message, err := s.storage.ReactRoomMessage(msg.Room, sess.username, msg.Seq, msg.Emoji, msg.Remove)
+		if err != nil {
+			return s.messageErr(sess, err)
+		}
+		s.sendToRoom(msg.Room, res(message))
+		return nil
+	}
+
+	message, peerMessage, err := s.storage.ReactMessage(sess.username, msg.Seq, msg.Emoji, msg.Remove)
+	if err != nil {
+		return s.messageErr(sess, err)
+	}
+	s.sendToSessions(sess.username, "", res(message))
+	if peerMessage != nil {
+		peer := message.To
+		if peer == sess.username {
+			peer = message.From
+		}
+		s.sendToSessions(peer, "", res(peerMessage))
+	}
+	return nil
+}
+
+func reactionsToApi(reactions []*storage.Reaction) []*api.ServerMessage_Reaction {
+	var res []*api.ServerMessage_Reaction
+	for _, reaction := range reactions {
+		res = append(res, &api.ServerMessage_Reaction{
+			Emoji: reaction.Emoji,
+			Users: reaction.Users,
+		})
+	}
+	return res
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+)
+
+// handleRead 更新已读位置，位置前进时通知自己的其他设备和消息的发送方
+// 私聊的序号属于各自的信箱，发给对方的回执换成对方信箱中的序号
+func (s *ChatService) handleRead(sess *session, msg *api.ClientMessage_Read) error {
+	cursor, prevSeq, err := s.storage.UpdateReadCursor(sess.username, msg.Peer, msg.Room, msg.Seq)
+	if err != nil {
+		return s.readErr(sess, err)
+	}
+	if cursor.Seq == prevSeq {
+		return nil
+	}
+
+	res := &api.ServerMessage{
+		Type: api.ServerMessage_SMTRead,
+		ReadReceipt: &api.ServerMessage_ReadReceipt{
+			Reader:    sess.username,
+			Peer:      cursor.Peer,
+			Room:      cursor.Room,
+			Seq:       cursor.Seq,
+			Timestamp: cursor.Timestamp.UnixNano() / int64(time.Millisecond),
+		},
+	}
+	s.sendToSessions(sess.username, sess.id, res)
+
+	if msg.Room == "" {
+		if msg.Peer != sess.username && cursor.PeerSeq != 0 {
+			s.sendToSessions(msg.Peer, "", &api.ServerMessage{
+				Type: api.ServerMessage_SMTRead,
+				ReadReceipt: &api.ServerMessage_ReadReceipt{
+					Reader:    sess.username,
+					Peer:      cursor.Peer,
+					Seq:       cursor.PeerSeq,
+					Timestamp: res.ReadReceipt.Timestamp,
+				},
+			})
+		}
+		return nil
+	}
+
+	// 房间消息通知这次新读到的消息的发送方
+	messages, err := s.storage.GetMessageByRoom(msg.Room, "", prevSeq+1)
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "storage.GetMessageByRoom failed"))
+		return nil
+	}
+	senders := map[string]struct{}{}
+	for _, message := range messages {
+		if message.Seq > cursor.Seq {
+			break
+		}
+		if message.From == sess.username {
+			continue
+		}
+		if _, ok := senders[message.From]; ok {
+			continue
+		}
+		senders[message.From] = struct{}{}
+		s.sendToSessions(message.From, "", res)
+	}
+
+	return nil
+}
+
+// readErr 已读位置只影响回执，存储错误也只通知客户端，不断开连接
+func (s *ChatService) readErr(sess *session, err error) error {
+	switch errors.Cause(err) {
+	case storage.ErrRoomNotFound, storage.ErrNotRoomMember:
+		return s.roomErr(sess, err)
+	}
+	s.rpcLog.Error(errors.WithMessage(err, "storage.UpdateReadCursor failed"))
+	return s.notifyErr(sess, api.ServerMessage_Err_Internal, "内部错误")
+}
+
+// unread 登录时发送每个会话的未读消息数
+func (s *ChatService) unread(stream messageSender, auth *api.ClientMessage_Auth) error {
+	res, err := s.unreadMessage(auth.Username)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(res); err != nil {
+		s.rpcLog.Error(err)
+		return errors.Wrap(err, "stream.Send failed")
+	}
+
+	return nil
+}
+
+// unreadMessage 按存储中的已读位置计算每个会话的未读消息数
+func (s *ChatService) unreadMessage(username string) (*api.ServerMessage, error) {
+	counts, err := s.storage.GetUnreadCounts(username)
+	if err != nil {
+		return nil, errors.WithMessage(err, "storage.GetUnreadCounts failed")
+	}
+
+	res := &api.ServerMessage{
+		Type:   api.ServerMessage_SMTUnread,
+		Unread: &api.ServerMessage_Unread{},
+	}
+	for _, count := range counts {
+		res.Unread.Counts = append(res.Unread.Counts, &api.ServerMessage_Unread_Count{
+			Peer:  count.Peer,
+			Room:  count.Room,
+			Count: count.Count,
+		})
+	}
+
+	return res, nil
+}
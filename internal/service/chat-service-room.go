@@ -0,0 +1,144 @@
+package service
+
+import (
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+)
+
+func (s *ChatService) handleRoom(sess *session, msg *api.ClientMessage_Room) error {
+	stream, username := sess, sess.username
+	var err error
+	switch msg.Op {
+	case api.ClientMessage_Room_Create:
+		err = s.storage.CreateRoom(msg.Name, username)
+	case api.ClientMessage_Room_Join:
+		err = s.storage.JoinRoom(msg.Name, username)
+	case api.ClientMessage_Room_Leave:
+		err = s.storage.LeaveRoom(msg.Name, username)
+	}
+	if err != nil {
+		return s.roomErr(stream, err)
+	}
+
+	res := &api.ServerMessage{
+		Type: api.ServerMessage_SMTRoom,
+		Room: &api.ServerMessage_Room{
+			Op:   msg.Op,
+			Name: msg.Name,
+		},
+	}
+	if msg.Op == api.ClientMessage_Room_List {
+		res.Room.Rooms, err = s.storage.GetRoomsByUser(username)
+	} else {
+		res.Room.Members, err = s.storage.GetRoomMembers(msg.Name)
+	}
+	if err != nil {
+		return s.roomErr(stream, err)
+	}
+
+	if err := stream.Send(res); err != nil {
+		s.rpcLog.Error(err)
+		return errors.Wrap(err, "stream.Send failed")
+	}
+	s.rpcLog.Info(res)
+
+	return nil
+}
+
+func (s *ChatService) handleRoomChat(sess *session, msg *api.ClientMessage_Chat) error {
+	message, err := s.storage.PutRoomMessage(msg.MsgId, msg.Room, sess.username, msg.Content, msg.ReplyTo, time.Duration(msg.Ttl)*time.Second)
+	if errors.Cause(err) == storage.ErrDuplicateMessage {
+		return s.ack(sess, msg, api.ServerMessage_Ack_Stored, message.Seq, "")
+	}
+	if err != nil {
+		return s.ack(sess, msg, api.ServerMessage_Ack_Failed, 0, s.roomErrReason(err))
+	}
+
+	members, err := s.storage.GetRoomMembers(msg.Room)
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "storage.GetRoomMembers failed"))
+		return s.ack(sess, msg, api.ServerMessage_Ack_Stored, message.Seq, "")
+	}
+
+	res := &api.ServerMessage{
+		Type: api.ServerMessage_SMTChat,
+		Chat: chatMessageToApi(message),
+	}
+	delivered := 0
+	for _, member := range members {
+		// 发送方自己的其他设备也要收到
+		if member == sess.username {
+			delivered += s.sendToSessions(member, sess.id, res)
+			continue
+		}
+		n := s.sendToSessions(member, "", res)
+		if n == 0 {
+			s.notifyOffline(member, message)
+		}
+		delivered += n
+	}
+
+	status := api.ServerMessage_Ack_Stored
+	if delivered > 0 {
+		status = api.ServerMessage_Ack_Delivered
+	}
+	return s.ack(sess, msg, status, message.Seq, "")
+}
+
+func (s *ChatService) roomHistory(stream messageSender, auth *api.ClientMessage_Auth) error {
+	rooms, err := s.storage.GetRoomsByUser(auth.Username)
+	if err != nil {
+		return errors.WithMessage(err, "storage.GetRoomsByUser failed")
+	}
+
+	for _, room := range rooms {
+		messages, err := s.storage.GetMessageByRoom(room, auth.Username, auth.RoomLastSeq[room]+1)
+		if err != nil {
+			return errors.WithMessage(err, "storage.GetMessageByRoom failed")
+		}
+		for _, message := range messages {
+			res := &api.ServerMessage{
+				Type: api.ServerMessage_SMTChat,
+				Chat: chatMessageToApi(message),
+			}
+			if err := stream.Send(res); err != nil {
+				s.rpcLog.Error(err)
+				return errors.Wrap(err, "stream.Send failed")
+			}
+		}
+	}
+
+	return nil
+}
+
+// roomErrReason 房间消息发送失败的原因，放在 Ack 中
+func (s *ChatService) roomErrReason(err error) string {
+	switch errors.Cause(err) {
+	case storage.ErrRoomNotFound:
+		return "房间不存在"
+	case storage.ErrNotRoomMember:
+		return "不是房间成员"
+	case storage.ErrMessageNotFound:
+		return "回复的消息不存在"
+	}
+	s.rpcLog.Error(errors.WithMessage(err, "storage.PutRoomMessage failed"))
+	return "内部错误"
+}
+
+// roomErr 房间相关的业务错误只通知客户端，存储错误断开连接
+func (s *ChatService) roomErr(stream messageSender, err error) error {
+	switch errors.Cause(err) {
+	case storage.ErrRoomNotFound:
+		return s.notifyErr(stream, api.ServerMessage_Err_RoomNotFound, "房间不存在")
+	case storage.ErrRoomExists:
+		return s.notifyErr(stream, api.ServerMessage_Err_RoomExists, "房间已存在")
+	case storage.ErrNotRoomMember:
+		return s.notifyErr(stream, api.ServerMessage_Err_NotRoomMember, "不是房间成员")
+	}
+	s.rpcLog.Error(err)
+	return s.setErr(stream, api.ServerMessage_Err_Internal, "内部错误")
+}
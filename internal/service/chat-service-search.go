@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type SearchOptions struct {
+	DefaultLimit int `dft:"20"`
+	MaxLimit     int `dft:"100"`
+}
+
+func (s *ChatService) SearchMessages(ctx context.Context, req *api.SearchMessagesReq) (*api.SearchMessagesRes, error) {
+	id, ok := identityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "需要 access token 或者客户端证书")
+	}
+	if strings.TrimSpace(req.Keyword) == "" {
+		return nil, status.Error(codes.InvalidArgument, "关键词不能为空")
+	}
+	if req.Since < 0 || req.Until < 0 || req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "参数不能为负数")
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = s.options.Search.DefaultLimit
+	}
+	if limit > s.options.Search.MaxLimit {
+		limit = s.options.Search.MaxLimit
+	}
+
+	query := &storage.SearchQuery{
+		Keyword: req.Keyword,
+		From:    req.From,
+		Limit:   limit,
+	}
+	if req.Since != 0 {
+		query.Since = time.Unix(0, req.Since*int64(time.Millisecond))
+	}
+	if req.Until != 0 {
+		query.Until = time.Unix(0, req.Until*int64(time.Millisecond))
+	}
+	messages, err := s.storage.SearchMessages(id.username, query)
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "storage.SearchMessages failed"))
+		return nil, status.Error(codes.Internal, "内部错误")
+	}
+
+	res := &api.SearchMessagesRes{}
+	for _, message := range messages {
+		res.Messages = append(res.Messages, chatMessageToApi(message))
+	}
+	return res, nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+)
+
+func newSessionID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// userSessions 一个用户当前在线的所有会话
+type userSessions struct {
+	sessions map[string]*session
+	status   api.ServerMessage_Presence_Status
+	mutex    sync.RWMutex
+}
+
+func (u *userSessions) add(sess *session) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.sessions[sess.id] = sess
+}
+
+// remove 返回剩余的会话数
+func (u *userSessions) remove(sess *session) int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	delete(u.sessions, sess.id)
+	return len(u.sessions)
+}
+
+func (u *userSessions) list() []*session {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	sessions := make([]*session, 0, len(u.sessions))
+	for _, sess := range u.sessions {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
+// addSession 注册会话，用户的第一个会话返回 true
+func (s *ChatService) addSession(sess *session) bool {
+	s.sessionMutex.Lock()
+	defer s.sessionMutex.Unlock()
+
+	v, loaded := s.conns.LoadOrStore(sess.username, &userSessions{
+		sessions: map[string]*session{},
+		status:   api.ServerMessage_Presence_Online,
+	})
+	v.(*userSessions).add(sess)
+	return !loaded
+}
+
+// removeSession 注销会话，用户的最后一个会话返回 true
+func (s *ChatService) removeSession(sess *session) bool {
+	s.sessionMutex.Lock()
+	defer s.sessionMutex.Unlock()
+
+	v, ok := s.conns.Load(sess.username)
+	if !ok {
+		return false
+	}
+	if v.(*userSessions).remove(sess) > 0 {
+		return false
+	}
+	s.conns.Delete(sess.username)
+	return true
+}
+
+func (s *ChatService) sessions(username string) []*session {
+	v, ok := s.conns.Load(username)
+	if !ok {
+		return nil
+	}
+	return v.(*userSessions).list()
+}
+
+// sendToSessions 发送给用户在所有节点上的在线会话，exceptID 不为空时跳过该会话，返回投递成功的会话数
+func (s *ChatService) sendToSessions(username string, exceptID string, res *api.ServerMessage) int {
+	return s.sendToLocalSessions(username, exceptID, res) + s.sendToRemoteSessions(username, exceptID, res)
+}
+
+// sendToLocalSessions 发送给用户在本节点上的会话
+// 只是放进各个会话的发送队列，不会阻塞发送方
+func (s *ChatService) sendToLocalSessions(username string, exceptID string, res *api.ServerMessage) int {
+	delivered := 0
+	for _, sess := range s.sessions(username) {
+		if sess.id == exceptID {
+			continue
+		}
+		if s.deliver(sess, res) {
+			delivered++
+		}
+	}
+	return delivered
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errShuttingDown 直接返回给 gRPC，客户端根据 Unavailable 稍后重连
+var errShuttingDown = status.Error(codes.Unavailable, "server is shutting down")
+
+// closing 服务是否已经开始关闭，认证之前检查，关闭之后不再读密码、签发 token
+// 认证期间客户端可能一直不发消息，所以认证中的连接不注册，Shutdown 不等待它们
+func (s *ChatService) closing() bool {
+	select {
+	case <-s.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// enter 注册一个已经认证的连接，服务关闭之后返回 false
+func (s *ChatService) enter() bool {
+	s.shutdownMutex.Lock()
+	defer s.shutdownMutex.Unlock()
+
+	select {
+	case <-s.shutdown:
+		return false
+	default:
+	}
+	s.handlers.Add(1)
+	return true
+}
+
+func (s *ChatService) leave() {
+	s.handlers.Done()
+}
+
+// Shutdown 通知所有连接服务即将关闭，等待连接处理完已经收到的消息、发完发送队列后退出
+// ctx 超时返回错误，剩下的连接由调用方强制关闭
+func (s *ChatService) Shutdown(ctx context.Context) error {
+	s.shutdownMutex.Lock()
+	select {
+	case <-s.shutdown:
+	default:
+		close(s.shutdown)
+	}
+	s.shutdownMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "wait for sessions failed")
+	}
+}
+
+// Close 退出集群，关闭 webhook 和存储，日志和快照在这里落盘，需要在所有连接退出之后调用
+func (s *ChatService) Close() error {
+	var err error
+	if e := s.closeCluster(); e != nil {
+		err = errors.WithMessage(e, "closeCluster failed")
+	}
+	if e := s.closeWebhook(); e != nil && err == nil {
+		err = errors.WithMessage(e, "closeWebhook failed")
+	}
+	if e := s.storage.Close(); e != nil && err == nil {
+		err = errors.WithMessage(e, "storage.Close failed")
+	}
+	if e := s.userStorage.Close(); e != nil && err == nil {
+		err = errors.WithMessage(e, "userStorage.Close failed")
+	}
+	return err
+}
+
+// drain 处理完 chatLoop 已经收到但还没处理的消息，然后通知客户端
+func (s *ChatService) drain(sess *session, msgChan <-chan *api.ClientMessage) error {
+	for {
+		select {
+		case msg := <-msgChan:
+			if err := s.handle(sess, msg); err != nil {
+				return err
+			}
+		default:
+			return sess.Send(&api.ServerMessage{
+				Type: api.ServerMessage_SMTShutdown,
+				Shutdown: &api.ServerMessage_Shutdown{
+					Reason: "服务器正在关闭，请稍后重连",
+				},
+			})
+		}
+	}
+}
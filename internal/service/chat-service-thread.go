@@ -0,0 +1,31 @@
+package service
+
+import (
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"github.com/pkg/errors"
+)
+
+// handleThread 只回复给请求的会话
+func (s *ChatService) handleThread(sess *session, msg *api.ClientMessage_Thread) error {
+	parent, replies, err := s.storage.GetThread(sess.username, msg.Room, msg.Seq)
+	if err != nil {
+		return s.messageErr(sess, err)
+	}
+
+	res := &api.ServerMessage{
+		Type: api.ServerMessage_SMTThread,
+		Thread: &api.ServerMessage_Thread{
+			Parent: chatMessageToApi(parent),
+		},
+	}
+	for _, reply := range replies {
+		res.Thread.Replies = append(res.Thread.Replies, chatMessageToApi(reply))
+	}
+	if err := sess.Send(res); err != nil {
+		s.rpcLog.Error(err)
+		return errors.Wrap(err, "stream.Send failed")
+	}
+
+	return nil
+}
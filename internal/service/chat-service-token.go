@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+// tokenClaims 每个 token 有自己的 ID，刷新时只注销旧的 refresh token
+// 一次登录和之后刷新签发的 token 共用一个会话 ID，RevokeToken 注销整个会话，并断开用它登录的连接
+type tokenClaims struct {
+	ID        string `json:"jti"`
+	Session   string `json:"sid,omitempty"`
+	Username  string `json:"sub"`
+	Type      string `json:"typ"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// session 之前签发的 token 没有会话 ID，access token 和 refresh token 共用的 ID 就是会话 ID
+func (c *tokenClaims) session() string {
+	if c.Session == "" {
+		return c.ID
+	}
+	return c.Session
+}
+
+var errInvalidToken = errors.New("invalid token")
+
+// signToken token 格式为 base64(claims).base64(hmac-sha256)
+func (s *ChatService) signToken(claims *tokenClaims) (string, error) {
+	buf, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "json.Marshal failed")
+	}
+	payload := base64.RawURLEncoding.EncodeToString(buf)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.tokenSignature(payload)), nil
+}
+
+func (s *ChatService) tokenSignature(payload string) []byte {
+	mac := hmac.New(sha256.New, s.tokenSecret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// parseToken 校验签名、类型、过期时间和是否已注销
+func (s *ChatService) parseToken(token string, typ string) (*tokenClaims, error) {
+	idx := strings.IndexByte(token, '.')
+	if idx < 0 {
+		return nil, errInvalidToken
+	}
+	payload := token[:idx]
+	signature, err := base64.RawURLEncoding.DecodeString(token[idx+1:])
+	if err != nil || !hmac.Equal(signature, s.tokenSignature(payload)) {
+		return nil, errInvalidToken
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(buf, &claims); err != nil {
+		return nil, errInvalidToken
+	}
+	if typ != "" && claims.Type != typ {
+		return nil, errInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, errInvalidToken
+	}
+
+	for _, id := range []string{claims.ID, claims.Session} {
+		if id == "" {
+			continue
+		}
+		revoked, err := s.userStorage.IsTokenRevoked(id)
+		if err != nil {
+			return nil, errors.WithMessage(err, "userStorage.IsTokenRevoked failed")
+		}
+		if revoked {
+			return nil, errInvalidToken
+		}
+	}
+
+	return &claims, nil
+}
+
+// issueToken 签发一组新的 token，session 为空时是新的登录，返回会话 ID
+func (s *ChatService) issueToken(username string, session string) (*api.Token, string, error) {
+	now := time.Now()
+	if session == "" {
+		session = newSessionID()
+	}
+	accessExpiresAt := now.Add(s.options.Auth.AccessTokenExpiration)
+	refreshExpiresAt := now.Add(s.options.Auth.RefreshTokenExpiration)
+
+	accessToken, err := s.signToken(&tokenClaims{
+		ID:        newSessionID(),
+		Session:   session,
+		Username:  username,
+		Type:      tokenTypeAccess,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: accessExpiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, "", errors.WithMessage(err, "signToken failed")
+	}
+	refreshToken, err := s.signToken(&tokenClaims{
+		ID:        newSessionID(),
+		Session:   session,
+		Username:  username,
+		Type:      tokenTypeRefresh,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: refreshExpiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, "", errors.WithMessage(err, "signToken failed")
+	}
+
+	return &api.Token{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessExpiresAt.UnixNano() / int64(time.Millisecond),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshExpiresAt.UnixNano() / int64(time.Millisecond),
+	}, session, nil
+}
+
+// revokeToken 注销 token 所在的会话，断开所有节点上用它登录的连接
+func (s *ChatService) revokeToken(claims *tokenClaims) error {
+	// 注销之后不会再刷新，会话中最后签发的 refresh token 在这之前过期，之后记录就可以清理了
+	expiresAt := time.Now().Add(s.options.Auth.RefreshTokenExpiration)
+	if err := s.userStorage.RevokeToken(claims.session(), expiresAt); err != nil {
+		return errors.WithMessage(err, "userStorage.RevokeToken failed")
+	}
+
+	s.disconnectToken(claims.Username, claims.session())
+	for _, node := range s.registry.Nodes(claims.Username) {
+		if !s.publisher.Publish(node, &api.ClusterEnvelope{
+			Type:     api.ClusterEnvelope_CETRevoke,
+			Username: claims.Username,
+			TokenId:  claims.session(),
+		}) {
+			// token 已经注销，对方节点上的连接只是暂时不会断开，重连时会被拒绝
+			s.rpcLog.Warn(map[string]interface{}{
+				"message":  "cluster publish queue overflow, drop revoke",
+				"node":     node,
+				"username": claims.Username,
+			})
+		}
+	}
+	return nil
+}
+
+// disconnectToken 断开本节点上用户使用 session 这组 token 登录的连接
+func (s *ChatService) disconnectToken(username string, session string) {
+	for _, sess := range s.sessions(username) {
+		if sess.tokenID == session {
+			sess.cancel()
+		}
+	}
+}
+
+func (s *ChatService) RefreshToken(ctx context.Context, req *api.RefreshTokenReq) (*api.Token, error) {
+	claims, err := s.parseToken(req.RefreshToken, tokenTypeRefresh)
+	if err == errInvalidToken {
+		return nil, status.Error(codes.Unauthenticated, "refresh token 无效")
+	}
+	if err != nil {
+		s.rpcLog.Error(err)
+		return nil, status.Error(codes.Internal, "内部错误")
+	}
+	// 只注销旧的 refresh token，已经建立的连接和还没过期的 access token 不受影响
+	// 注销由存储保证只成功一次，同一个 refresh token 在多个节点上同时刷新也只能换出一组 token
+	err = s.userStorage.RevokeToken(claims.ID, time.Unix(claims.ExpiresAt, 0))
+	if errors.Cause(err) == storage.ErrTokenRevoked {
+		return nil, status.Error(codes.Unauthenticated, "refresh token 无效")
+	}
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "userStorage.RevokeToken failed"))
+		return nil, status.Error(codes.Internal, "内部错误")
+	}
+
+	token, _, err := s.issueToken(claims.Username, claims.session())
+	if err != nil {
+		s.rpcLog.Error(err)
+		return nil, status.Error(codes.Internal, "内部错误")
+	}
+	return token, nil
+}
+
+func (s *ChatService) RevokeToken(ctx context.Context, req *api.RevokeTokenReq) (*api.RevokeTokenRes, error) {
+	claims, err := s.parseToken(req.Token, "")
+	if err == errInvalidToken {
+		return nil, status.Error(codes.Unauthenticated, "token 无效")
+	}
+	if err != nil {
+		s.rpcLog.Error(err)
+		return nil, status.Error(codes.Internal, "内部错误")
+	}
+	err = s.revokeToken(claims)
+	if errors.Cause(err) == storage.ErrTokenRevoked {
+		return nil, status.Error(codes.Unauthenticated, "token 无效")
+	}
+	if err != nil {
+		s.rpcLog.Error(err)
+		return nil, status.Error(codes.Internal, "内部错误")
+	}
+	return &api.RevokeTokenRes{}, nil
+}
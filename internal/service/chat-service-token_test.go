@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRefreshToken(t *testing.T) {
+	s := newTestChatService(t, OverflowPolicySpill, 16)
+	token, sessionID, err := s.issueToken("alice", "")
+	if err != nil {
+		t.Fatalf("issueToken failed: %v", err)
+	}
+	sess := newTestSession(t, s, "alice", sessionID)
+
+	refreshed, err := s.RefreshToken(context.Background(), &api.RefreshTokenReq{RefreshToken: token.RefreshToken})
+	if err != nil {
+		t.Fatalf("RefreshToken failed: %v", err)
+	}
+	// 刷新不影响已经建立的连接
+	if canceled(sess) {
+		t.Fatalf("session canceled by refresh")
+	}
+
+	for _, c := range []struct {
+		name    string
+		token   string
+		typ     string
+		wantErr bool
+	}{
+		{"old access token is still valid", token.AccessToken, tokenTypeAccess, false},
+		{"old refresh token is revoked", token.RefreshToken, tokenTypeRefresh, true},
+		{"new access token", refreshed.AccessToken, tokenTypeAccess, false},
+		{"new refresh token", refreshed.RefreshToken, tokenTypeRefresh, false},
+		{"access token is not a refresh token", refreshed.AccessToken, tokenTypeRefresh, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			claims, err := s.parseToken(c.token, c.typ)
+			if c.wantErr {
+				if err != errInvalidToken {
+					t.Fatalf("parseToken error = %v, want errInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseToken failed: %v", err)
+			}
+			// 刷新之后仍然是同一个会话，RevokeToken 可以一起注销
+			if claims.Username != "alice" || claims.session() != sessionID {
+				t.Fatalf("claims = %+v, want alice in session %s", claims, sessionID)
+			}
+		})
+	}
+
+	// 同一个 refresh token 只能用一次
+	_, err = s.RefreshToken(context.Background(), &api.RefreshTokenReq{RefreshToken: token.RefreshToken})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("second RefreshToken error = %v, want Unauthenticated", err)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		// 用哪个 token 注销
+		revoke func(login *api.Token, refreshed *api.Token) string
+	}{
+		{"revoke with access token", func(login *api.Token, refreshed *api.Token) string { return login.AccessToken }},
+		{"revoke with refresh token", func(login *api.Token, refreshed *api.Token) string { return refreshed.RefreshToken }},
+		{"revoke with refreshed access token", func(login *api.Token, refreshed *api.Token) string { return refreshed.AccessToken }},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestChatService(t, OverflowPolicySpill, 16)
+			login, sessionID, err := s.issueToken("alice", "")
+			if err != nil {
+				t.Fatalf("issueToken failed: %v", err)
+			}
+			other, otherID, err := s.issueToken("alice", "")
+			if err != nil {
+				t.Fatalf("issueToken failed: %v", err)
+			}
+			refreshed, err := s.RefreshToken(context.Background(), &api.RefreshTokenReq{RefreshToken: login.RefreshToken})
+			if err != nil {
+				t.Fatalf("RefreshToken failed: %v", err)
+			}
+			sess := newTestSession(t, s, "alice", sessionID)
+			otherSess := newTestSession(t, s, "alice", otherID)
+
+			if _, err := s.RevokeToken(context.Background(), &api.RevokeTokenReq{Token: c.revoke(login, refreshed)}); err != nil {
+				t.Fatalf("RevokeToken failed: %v", err)
+			}
+
+			// 整个会话的 token 都失效，包括刷新之后签发的
+			for _, token := range []string{login.AccessToken, refreshed.AccessToken, refreshed.RefreshToken} {
+				if _, err := s.parseToken(token, ""); err != errInvalidToken {
+					t.Fatalf("parseToken error = %v, want errInvalidToken", err)
+				}
+			}
+			if !canceled(sess) {
+				t.Fatalf("session logged in with revoked token is not disconnected")
+			}
+
+			// 另一次登录不受影响
+			if _, err := s.parseToken(other.AccessToken, tokenTypeAccess); err != nil {
+				t.Fatalf("parseToken of other login failed: %v", err)
+			}
+			if canceled(otherSess) {
+				t.Fatalf("session of other login is disconnected")
+			}
+		})
+	}
+}
+
+// TestRefreshTokenConcurrent 同一个 refresh token 同时刷新只有一次成功，不依赖节点内的锁
+func TestRefreshTokenConcurrent(t *testing.T) {
+	s := newTestChatService(t, OverflowPolicySpill, 16)
+	token, _, err := s.issueToken("alice", "")
+	if err != nil {
+		t.Fatalf("issueToken failed: %v", err)
+	}
+
+	const n = 8
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.RefreshToken(context.Background(), &api.RefreshTokenReq{RefreshToken: token.RefreshToken})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var succeeded int
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("RefreshToken error = %v, want Unauthenticated", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("succeeded = %d, want 1", succeeded)
+	}
+}
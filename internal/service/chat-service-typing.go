@@ -0,0 +1,200 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+)
+
+type TypingOptions struct {
+	// 超过这个时间没有收到客户端的输入状态，自动结束输入
+	Timeout time.Duration `dft:"5s"`
+	// 一个连接同时在输入的聊天数上限，超过时忽略新的输入状态，每个聊天都有一个定时器
+	MaxEntries int `dft:"32"`
+}
+
+// typingState 会话中正在输入的聊天，只保存在内存中
+type typingState struct {
+	entries map[string]*typingEntry
+	mutex   sync.Mutex
+}
+
+type typingEntry struct {
+	to    string
+	room  string
+	timer *time.Timer
+}
+
+func typingKey(to string, room string) string {
+	if room != "" {
+		return "room:" + room
+	}
+	return "user:" + to
+}
+
+func (s *ChatService) handleTyping(sess *session, msg *api.ClientMessage_Typing) error {
+	if msg.Room != "" {
+		members, err := s.storage.GetRoomMembers(msg.Room)
+		if err != nil {
+			return s.roomErr(sess, err)
+		}
+		if !containsString(members, sess.username) {
+			return s.roomErr(sess, storage.ErrNotRoomMember)
+		}
+	} else if msg.To == sess.username {
+		return nil
+	} else if msg.Typing && !s.isTyping(sess, msg.To, msg.Room) {
+		// 只在开始输入时检查，之后客户端每次按键刷新状态不再查询存储
+		ok, err := s.isTypingPeer(sess.username, msg.To)
+		if err != nil {
+			s.rpcLog.Error(err)
+			return s.notifyErr(sess, api.ServerMessage_Err_Internal, "内部错误")
+		}
+		if !ok {
+			return s.notifyErr(sess, api.ServerMessage_Err_PersonNotFound, "用户不存在")
+		}
+	}
+
+	if msg.Typing {
+		s.startTyping(sess, msg.To, msg.Room)
+	} else {
+		s.stopTyping(sess, msg.To, msg.Room)
+	}
+	return nil
+}
+
+// isTypingPeer 私聊的输入状态只发给联系人或者存在的用户，不能用来给任意用户名发消息
+func (s *ChatService) isTypingPeer(username string, to string) (bool, error) {
+	contacts, err := s.storage.GetContacts(username)
+	if err != nil {
+		return false, errors.WithMessage(err, "storage.GetContacts failed")
+	}
+	if containsString(contacts, to) {
+		return true, nil
+	}
+	_, err = s.userStorage.GetUser(to)
+	if errors.Cause(err) == storage.ErrUserNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithMessage(err, "userStorage.GetUser failed")
+	}
+	return true, nil
+}
+
+func (s *ChatService) isTyping(sess *session, to string, room string) bool {
+	sess.typing.mutex.Lock()
+	defer sess.typing.mutex.Unlock()
+	_, ok := sess.typing.entries[typingKey(to, room)]
+	return ok
+}
+
+// startTyping 开始输入时通知对方，已经在输入时只重置超时
+func (s *ChatService) startTyping(sess *session, to string, room string) {
+	key := typingKey(to, room)
+	entry := &typingEntry{to: to, room: room}
+
+	sess.typing.mutex.Lock()
+	prev, ok := sess.typing.entries[key]
+	if ok {
+		prev.timer.Stop()
+	} else if len(sess.typing.entries) >= s.options.Typing.MaxEntries {
+		sess.typing.mutex.Unlock()
+		s.rpcLog.Warn(map[string]interface{}{
+			"message":  "too many typing entries, ignore",
+			"session":  sess.id,
+			"username": sess.username,
+		})
+		return
+	}
+	entry.timer = time.AfterFunc(s.options.Typing.Timeout, func() {
+		s.expireTyping(sess, key, entry)
+	})
+	sess.typing.entries[key] = entry
+	sess.typing.mutex.Unlock()
+
+	if !ok {
+		s.relayTyping(sess, to, room, true)
+	}
+}
+
+func (s *ChatService) stopTyping(sess *session, to string, room string) {
+	key := typingKey(to, room)
+
+	sess.typing.mutex.Lock()
+	entry, ok := sess.typing.entries[key]
+	if ok {
+		entry.timer.Stop()
+		delete(sess.typing.entries, key)
+	}
+	sess.typing.mutex.Unlock()
+
+	if ok {
+		s.relayTyping(sess, to, room, false)
+	}
+}
+
+// expireTyping 超时回调，期间重新开始过输入时 entry 已经被替换，忽略
+func (s *ChatService) expireTyping(sess *session, key string, entry *typingEntry) {
+	sess.typing.mutex.Lock()
+	if sess.typing.entries[key] != entry {
+		sess.typing.mutex.Unlock()
+		return
+	}
+	delete(sess.typing.entries, key)
+	sess.typing.mutex.Unlock()
+
+	s.relayTyping(sess, entry.to, entry.room, false)
+}
+
+// clearTyping 断开连接时结束所有输入状态
+func (s *ChatService) clearTyping(sess *session) {
+	sess.typing.mutex.Lock()
+	entries := sess.typing.entries
+	sess.typing.entries = map[string]*typingEntry{}
+	sess.typing.mutex.Unlock()
+
+	for _, entry := range entries {
+		entry.timer.Stop()
+		s.relayTyping(sess, entry.to, entry.room, false)
+	}
+}
+
+func (s *ChatService) relayTyping(sess *session, to string, room string, typing bool) {
+	res := &api.ServerMessage{
+		Type: api.ServerMessage_SMTTyping,
+		Typing: &api.ServerMessage_Typing{
+			From:   sess.username,
+			Room:   room,
+			Typing: typing,
+		},
+	}
+	if room == "" {
+		s.sendToSessions(to, "", res)
+		return
+	}
+
+	members, err := s.storage.GetRoomMembers(room)
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "storage.GetRoomMembers failed"))
+		return
+	}
+	for _, member := range members {
+		if member != sess.username {
+			s.sendToSessions(member, "", res)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
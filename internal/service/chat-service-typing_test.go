@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/storage"
+)
+
+func TestHandleTypingPeer(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		to   string
+		// 期望 alice 收到的错误码，nil 表示没有错误，bob 收到输入状态
+		wantErr *api.ServerMessage_Err_Code
+	}{
+		{name: "existing user", to: "bob"},
+		{name: "contact", to: "carol"},
+		{name: "unknown user", to: "nobody", wantErr: api.ServerMessage_Err_PersonNotFound.Enum()},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestChatService(t, OverflowPolicySpill, 16)
+			if err := s.userStorage.PutUser(&storage.User{Username: "bob"}); err != nil {
+				t.Fatalf("PutUser failed: %v", err)
+			}
+			// carol 不在这个节点的用户存储中，但是私聊过
+			if _, _, err := s.storage.PutMessage("", "carol", "alice", "hello", 0, 0); err != nil {
+				t.Fatalf("PutMessage failed: %v", err)
+			}
+			alice := newTestSession(t, s, "alice", "")
+			peer := newTestSession(t, s, c.to, "")
+
+			if err := s.handleTyping(alice, &api.ClientMessage_Typing{To: c.to, Typing: true}); err != nil {
+				t.Fatalf("handleTyping failed: %v", err)
+			}
+			t.Cleanup(func() { s.clearTyping(alice) })
+
+			res := drainSession(alice)
+			received := drainSession(peer)
+			if c.wantErr != nil {
+				if len(res) != 1 || res[0].Type != api.ServerMessage_SMTErr || res[0].Err.Code != *c.wantErr {
+					t.Fatalf("alice received %v, want err %v", res, *c.wantErr)
+				}
+				if len(received) != 0 || s.isTyping(alice, c.to, "") {
+					t.Fatalf("typing relayed to unknown user")
+				}
+				return
+			}
+			if len(res) != 0 {
+				t.Fatalf("alice received %v, want nothing", res)
+			}
+			if len(received) != 1 || received[0].Type != api.ServerMessage_SMTTyping || !received[0].Typing.Typing {
+				t.Fatalf("%s received %v, want typing", c.to, received)
+			}
+		})
+	}
+}
+
+func TestHandleTypingMaxEntries(t *testing.T) {
+	s := newTestChatService(t, OverflowPolicySpill, 16)
+	s.options.Typing.MaxEntries = 2
+	alice := newTestSession(t, s, "alice", "")
+	t.Cleanup(func() { s.clearTyping(alice) })
+
+	for i := 0; i < 3; i++ {
+		if err := s.userStorage.PutUser(&storage.User{Username: fmt.Sprintf("user%d", i)}); err != nil {
+			t.Fatalf("PutUser failed: %v", err)
+		}
+		if err := s.handleTyping(alice, &api.ClientMessage_Typing{To: fmt.Sprintf("user%d", i), Typing: true}); err != nil {
+			t.Fatalf("handleTyping failed: %v", err)
+		}
+	}
+	if got := len(alice.typing.entries); got != 2 {
+		t.Fatalf("typing entries = %d, want 2", got)
+	}
+	if s.isTyping(alice, "user2", "") {
+		t.Fatalf("typing entry over the limit is kept")
+	}
+
+	// 已经在输入的聊天只刷新超时，不受上限影响
+	if err := s.handleTyping(alice, &api.ClientMessage_Typing{To: "user0", Typing: true}); err != nil {
+		t.Fatalf("handleTyping failed: %v", err)
+	}
+	if !s.isTyping(alice, "user0", "") {
+		t.Fatalf("typing entry of user0 is dropped")
+	}
+}
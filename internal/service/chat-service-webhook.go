@@ -0,0 +1,364 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/pkg/errors"
+)
+
+type WebhookOptions struct {
+	// 接收通知的地址，为空时不发送
+	URL string
+	// 签名的密钥，设置了 URL 时必须设置
+	Secret  string
+	Timeout time.Duration `dft:"5s"`
+	// 等待发送的通知数，满了之后新的通知直接进入死信。等待重试的通知也不超过这个数
+	QueueSize int `dft:"1024"`
+	Workers   int `dft:"4"`
+	// 第一次失败之后最多重试的次数，间隔从 InitialBackoff 开始翻倍，不超过 MaxBackoff
+	MaxRetries     int           `dft:"5"`
+	InitialBackoff time.Duration `dft:"1s"`
+	MaxBackoff     time.Duration `dft:"1m"`
+	// 重试之后仍然失败的通知追加到这个文件，每行一个 json，为空时只记录日志
+	DeadLetterFile string
+}
+
+const (
+	webhookEventOfflineMessage = "message.offline"
+
+	// 签名为 hex(hmac-sha256(secret, timestamp + "." + body))，timestamp 是 unix 秒，接收方据此拒绝重放的请求
+	webhookHeaderEventID   = "X-Chat-Event-Id"
+	webhookHeaderTimestamp = "X-Chat-Timestamp"
+	webhookHeaderSignature = "X-Chat-Signature"
+)
+
+// webhook 的监控指标，通过 expvar 暴露
+var (
+	webhookSent    = expvar.NewInt("chat_webhook_sent_total")
+	webhookRetried = expvar.NewInt("chat_webhook_retried_total")
+	webhookDead    = expvar.NewInt("chat_webhook_dead_total")
+)
+
+// webhookEvent 接收方没有在线会话时发给 webhook 的通知，同一个通知重试时 ID 不变，接收方按 ID 去重
+type webhookEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp int64           `json:"timestamp"`
+	Message   *webhookMessage `json:"message"`
+}
+
+// webhookMessage 接收方看到的消息，私聊时序号属于接收方的信箱
+// 房间消息时 Room 不为空，To 是不在线的房间成员，序号属于房间
+type webhookMessage struct {
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Room            string `json:"room,omitempty"`
+	Seq             int64  `json:"seq"`
+	ConversationSeq int64  `json:"conversationSeq"`
+	MsgID           string `json:"msgId,omitempty"`
+	Content         string `json:"content"`
+	Timestamp       int64  `json:"timestamp"`
+	ReplyTo         int64  `json:"replyTo,omitempty"`
+	ExpiresAt       int64  `json:"expiresAt,omitempty"`
+}
+
+// webhookDeadLetter 死信文件中的一行
+type webhookDeadLetter struct {
+	Event    *webhookEvent `json:"event"`
+	Attempts int           `json:"attempts"`
+	Error    string        `json:"error"`
+	Time     time.Time     `json:"time"`
+}
+
+// webhookTask 一个通知的发送状态，重试时 body 不变，只重新签名
+type webhookTask struct {
+	event    *webhookEvent
+	body     []byte
+	attempts int
+	backoff  time.Duration
+	// 最近一次失败的原因
+	err error
+}
+
+// webhookSender 后台发送 webhook 通知，不阻塞发送消息的连接
+type webhookSender struct {
+	options *WebhookOptions
+	client  *http.Client
+	queue   chan *webhookTask
+	// 到了重试时间的通知，和新的通知一起由 worker 发送
+	retry chan *webhookTask
+
+	// 等待重试的通知，定时器到期后放进 retry，worker 不需要等待
+	timers     map[*webhookTask]*time.Timer
+	timerMutex sync.Mutex
+	timerWg    sync.WaitGroup
+
+	deadLetter      *os.File
+	deadLetterMutex sync.Mutex
+
+	// 关闭时取消正在进行的请求和重试等待
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWebhookSender(options *WebhookOptions) (*webhookSender, error) {
+	if options.Secret == "" {
+		return nil, errors.New("webhook secret is not set")
+	}
+	w := &webhookSender{
+		options: options,
+		client:  &http.Client{Timeout: options.Timeout},
+		queue:   make(chan *webhookTask, options.QueueSize),
+		retry:   make(chan *webhookTask),
+		timers:  map[*webhookTask]*time.Timer{},
+	}
+	if options.DeadLetterFile != "" {
+		if err := os.MkdirAll(filepath.Dir(options.DeadLetterFile), 0755); err != nil {
+			return nil, errors.Wrap(err, "os.MkdirAll failed")
+		}
+		file, err := os.OpenFile(options.DeadLetterFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, errors.Wrap(err, "os.OpenFile failed")
+		}
+		w.deadLetter = file
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	return w, nil
+}
+
+// startWebhook 启动发送通知的 goroutine，没有配置 webhook 时什么也不做
+func (s *ChatService) startWebhook() {
+	if s.webhook == nil {
+		return
+	}
+	for i := 0; i < s.options.Webhook.Workers; i++ {
+		s.webhook.wg.Add(1)
+		go s.webhookLoop()
+	}
+}
+
+// closeWebhook 取消正在进行的发送，没有发出去的通知都进入死信。需要在所有连接退出之后调用
+func (s *ChatService) closeWebhook() error {
+	if s.webhook == nil {
+		return nil
+	}
+	s.webhook.cancel()
+	s.webhook.wg.Wait()
+
+	// 还没到期的重试直接进入死信，已经到期的定时器看到 ctx 取消后自己进入死信
+	s.webhook.timerMutex.Lock()
+	for task, timer := range s.webhook.timers {
+		if timer.Stop() {
+			s.deadLetter(task.event, task.attempts, errors.WithMessage(task.err, "server closed"))
+			s.webhook.timerWg.Done()
+		}
+	}
+	s.webhook.timers = map[*webhookTask]*time.Timer{}
+	s.webhook.timerMutex.Unlock()
+	s.webhook.timerWg.Wait()
+
+	for {
+		select {
+		case task := <-s.webhook.queue:
+			s.deadLetter(task.event, 0, errors.New("server closed"))
+		default:
+			if s.webhook.deadLetter == nil {
+				return nil
+			}
+			return errors.Wrap(s.webhook.deadLetter.Close(), "deadLetter.Close failed")
+		}
+	}
+}
+
+// notifyOffline 接收方 to 没有在线会话，放进 webhook 的发送队列
+func (s *ChatService) notifyOffline(to string, message *storage.ChatMessage) {
+	if s.webhook == nil {
+		return
+	}
+	conversationSeq := message.ConversationSeq
+	if message.Room != "" {
+		conversationSeq = message.Seq
+	}
+	event := &webhookEvent{
+		ID:        newSessionID(),
+		Type:      webhookEventOfflineMessage,
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		Message: &webhookMessage{
+			From:            message.From,
+			To:              to,
+			Room:            message.Room,
+			Seq:             message.Seq,
+			ConversationSeq: conversationSeq,
+			MsgID:           message.MsgID,
+			Content:         message.Content,
+			Timestamp:       message.Timestamp.UnixNano() / int64(time.Millisecond),
+			ReplyTo:         message.ReplyTo,
+		},
+	}
+	if message.ExpiresAt != nil {
+		event.Message.ExpiresAt = message.ExpiresAt.UnixNano() / int64(time.Millisecond)
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		s.deadLetter(event, 0, errors.Wrap(err, "json.Marshal failed"))
+		return
+	}
+
+	select {
+	case s.webhook.queue <- &webhookTask{event: event, body: body, backoff: s.webhook.options.InitialBackoff}:
+	case <-s.webhook.ctx.Done():
+		s.deadLetter(event, 0, errors.New("server closed"))
+	default:
+		s.deadLetter(event, 0, errors.New("webhook queue is full"))
+	}
+}
+
+func (s *ChatService) webhookLoop() {
+	defer s.webhook.wg.Done()
+
+	for {
+		select {
+		case <-s.webhook.ctx.Done():
+			return
+		case task := <-s.webhook.retry:
+			s.sendWebhook(task)
+		case task := <-s.webhook.queue:
+			s.sendWebhook(task)
+		}
+	}
+}
+
+// sendWebhook 发送一次通知，失败时交给定时器按退避间隔重试，不能重试或者重试次数用完时进入死信
+func (s *ChatService) sendWebhook(task *webhookTask) {
+	task.attempts++
+	retryable, err := s.postWebhook(task.event, task.body)
+	if err == nil {
+		webhookSent.Add(1)
+		return
+	}
+	task.err = err
+	if !retryable || task.attempts > s.webhook.options.MaxRetries {
+		s.deadLetter(task.event, task.attempts, err)
+		return
+	}
+
+	webhookRetried.Add(1)
+	s.rpcLog.Warn(map[string]interface{}{
+		"message": "webhook failed, retry later",
+		"event":   task.event.ID,
+		"attempt": task.attempts,
+		"error":   err.Error(),
+	})
+	s.retryWebhook(task)
+}
+
+// retryWebhook 等待退避间隔后重新放进 retry，等待期间不占用 worker
+func (s *ChatService) retryWebhook(task *webhookTask) {
+	w := s.webhook
+	// 加上一点随机，避免多个通知同时重试
+	delay := task.backoff + time.Duration(rand.Int63n(int64(task.backoff)/5+1))
+	if task.backoff *= 2; task.backoff > w.options.MaxBackoff {
+		task.backoff = w.options.MaxBackoff
+	}
+
+	w.timerMutex.Lock()
+	defer w.timerMutex.Unlock()
+	if w.ctx.Err() != nil {
+		s.deadLetter(task.event, task.attempts, errors.WithMessage(task.err, "server closed"))
+		return
+	}
+	if len(w.timers) >= w.options.QueueSize {
+		s.deadLetter(task.event, task.attempts, errors.WithMessage(task.err, "webhook retry queue is full"))
+		return
+	}
+	w.timerWg.Add(1)
+	w.timers[task] = time.AfterFunc(delay, func() {
+		defer w.timerWg.Done()
+		w.timerMutex.Lock()
+		delete(w.timers, task)
+		w.timerMutex.Unlock()
+
+		select {
+		case w.retry <- task:
+		case <-w.ctx.Done():
+			s.deadLetter(task.event, task.attempts, errors.WithMessage(task.err, "server closed"))
+		}
+	})
+}
+
+// postWebhook 网络错误、超时、5xx、408 和 429 可以重试，其他的非 2xx 响应重试也不会成功
+func (s *ChatService) postWebhook(event *webhookEvent, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(s.webhook.ctx, http.MethodPost, s.webhook.options.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "http.NewRequest failed")
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookHeaderEventID, event.ID)
+	req.Header.Set(webhookHeaderTimestamp, timestamp)
+	req.Header.Set(webhookHeaderSignature, "sha256="+webhookSignature([]byte(s.webhook.options.Secret), timestamp, body))
+
+	res, err := s.webhook.client.Do(req)
+	if err != nil {
+		return true, errors.Wrap(err, "client.Do failed")
+	}
+	// 读完响应才能复用连接
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	_ = res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := res.StatusCode >= 500 || res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusTooManyRequests
+	return retryable, errors.Errorf("unexpected status code [%d]", res.StatusCode)
+}
+
+func webhookSignature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deadLetter 记录发送失败的通知，attempts 为 0 表示还没有发送过
+func (s *ChatService) deadLetter(event *webhookEvent, attempts int, err error) {
+	webhookDead.Add(1)
+	s.rpcLog.Error(map[string]interface{}{
+		"message":  "webhook dead letter",
+		"event":    event.ID,
+		"attempts": attempts,
+		"error":    err.Error(),
+	})
+	if s.webhook.deadLetter == nil {
+		return
+	}
+
+	buf, e := json.Marshal(&webhookDeadLetter{Event: event, Attempts: attempts, Error: err.Error(), Time: time.Now()})
+	if e != nil {
+		s.rpcLog.Error(errors.Wrap(e, "json.Marshal failed"))
+		return
+	}
+	s.webhook.deadLetterMutex.Lock()
+	defer s.webhook.deadLetterMutex.Unlock()
+	if _, e := s.webhook.deadLetter.Write(append(buf, '\n')); e != nil {
+		s.rpcLog.Error(errors.Wrap(e, "deadLetter.Write failed"))
+	}
+}
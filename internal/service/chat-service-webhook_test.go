@@ -0,0 +1,283 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/chat-server/internal/storage"
+
+	"github.com/hatlonely/go-kit/logger"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+// webhookRecorder 按顺序返回预设的状态码，用完之后一直返回最后一个
+type webhookRecorder struct {
+	t        *testing.T
+	statuses []int
+
+	mutex    sync.Mutex
+	requests []*webhookEvent
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Errorf("io.ReadAll failed: %v", err)
+		return
+	}
+	timestamp := req.Header.Get(webhookHeaderTimestamp)
+	if want := "sha256=" + webhookSignature([]byte(testWebhookSecret), timestamp, body); req.Header.Get(webhookHeaderSignature) != want {
+		r.t.Errorf("signature = %q, want %q", req.Header.Get(webhookHeaderSignature), want)
+	}
+	var event webhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		r.t.Errorf("json.Unmarshal failed: %v", err)
+	}
+	if req.Header.Get(webhookHeaderEventID) != event.ID {
+		r.t.Errorf("event id header = %q, want %q", req.Header.Get(webhookHeaderEventID), event.ID)
+	}
+
+	r.mutex.Lock()
+	r.requests = append(r.requests, &event)
+	status := r.statuses[len(r.statuses)-1]
+	if len(r.requests) <= len(r.statuses) {
+		status = r.statuses[len(r.requests)-1]
+	}
+	r.mutex.Unlock()
+	w.WriteHeader(status)
+}
+
+func (r *webhookRecorder) events() []*webhookEvent {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*webhookEvent(nil), r.requests...)
+}
+
+func newTestWebhookService(t *testing.T, options *WebhookOptions) *ChatService {
+	t.Helper()
+	webhook, err := newWebhookSender(options)
+	if err != nil {
+		t.Fatalf("newWebhookSender failed: %v", err)
+	}
+	s := &ChatService{
+		options: &Options{Webhook: *options},
+		webhook: webhook,
+		rpcLog:  logger.NewStdoutJsonLogger(),
+	}
+	s.startWebhook()
+	return s
+}
+
+func testWebhookOptions(url string, deadLetterFile string) *WebhookOptions {
+	return &WebhookOptions{
+		URL:            url,
+		Secret:         testWebhookSecret,
+		Timeout:        time.Second,
+		QueueSize:      16,
+		Workers:        1,
+		MaxRetries:     2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		DeadLetterFile: deadLetterFile,
+	}
+}
+
+func testOfflineMessage(content string) *storage.ChatMessage {
+	return &storage.ChatMessage{
+		Seq:             3,
+		Timestamp:       time.Now(),
+		From:            "alice",
+		To:              "bob",
+		Content:         content,
+		ConversationSeq: 7,
+	}
+}
+
+// waitWebhookDone 等到 n 个通知发送成功或者进入死信
+func waitWebhookDone(t *testing.T, start int64, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for webhookSent.Value()+webhookDead.Value()-start < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("webhook not done in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func readDeadLetters(t *testing.T, file string) []*webhookDeadLetter {
+	t.Helper()
+	fp, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("os.Open failed: %v", err)
+	}
+	defer fp.Close()
+
+	var letters []*webhookDeadLetter
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		var letter webhookDeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			t.Fatalf("json.Unmarshal failed: %v", err)
+		}
+		letters = append(letters, &letter)
+	}
+	return letters
+}
+
+func TestWebhookSignature(t *testing.T) {
+	for _, c := range []struct {
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac secret
+		{"secret", "1700000000", "{}", "b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"},
+		{testWebhookSecret, "1700000000", `{"id":"1"}`, "0082b76f251523e140803b6b28fcc30d8d4d0239fe3dd2ee8ee63da3b2fea17c"},
+	} {
+		if got := webhookSignature([]byte(c.secret), c.timestamp, []byte(c.body)); got != c.want {
+			t.Fatalf("webhookSignature(%q, %q, %q) = %s, want %s", c.secret, c.timestamp, c.body, got, c.want)
+		}
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		statuses []int
+		// 服务端收到的请求数
+		attempts int
+		dead     bool
+	}{
+		{"ok", []int{http.StatusOK}, 1, false},
+		{"no content", []int{http.StatusNoContent}, 1, false},
+		{"bad request is terminal", []int{http.StatusBadRequest}, 1, true},
+		{"unauthorized is terminal", []int{http.StatusUnauthorized}, 1, true},
+		{"server error then ok", []int{http.StatusInternalServerError, http.StatusOK}, 2, false},
+		{"request timeout is retryable", []int{http.StatusRequestTimeout, http.StatusOK}, 2, false},
+		{"too many requests is retryable", []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK}, 3, false},
+		{"retries exhausted", []int{http.StatusServiceUnavailable}, 3, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := &webhookRecorder{t: t, statuses: c.statuses}
+			server := httptest.NewServer(recorder)
+			defer server.Close()
+			deadLetterFile := filepath.Join(t.TempDir(), "dead", "webhook.log")
+
+			start := webhookSent.Value() + webhookDead.Value()
+			s := newTestWebhookService(t, testWebhookOptions(server.URL, deadLetterFile))
+			s.notifyOffline("bob", testOfflineMessage("hello"))
+			waitWebhookDone(t, start, 1)
+			if err := s.closeWebhook(); err != nil {
+				t.Fatalf("closeWebhook failed: %v", err)
+			}
+
+			events := recorder.events()
+			if len(events) != c.attempts {
+				t.Fatalf("attempts = %d, want %d", len(events), c.attempts)
+			}
+			for _, event := range events {
+				// 重试时 ID 不变，接收方按 ID 去重
+				if event.ID != events[0].ID {
+					t.Fatalf("event id changed on retry: %q != %q", event.ID, events[0].ID)
+				}
+				if event.Type != webhookEventOfflineMessage || event.Message.To != "bob" || event.Message.Content != "hello" ||
+					event.Message.Seq != 3 || event.Message.ConversationSeq != 7 {
+					t.Fatalf("unexpected event %+v", event.Message)
+				}
+			}
+
+			letters := readDeadLetters(t, deadLetterFile)
+			if !c.dead {
+				if len(letters) != 0 {
+					t.Fatalf("dead letters = %d, want 0", len(letters))
+				}
+				return
+			}
+			if len(letters) != 1 {
+				t.Fatalf("dead letters = %d, want 1", len(letters))
+			}
+			if letters[0].Event.ID != events[0].ID || letters[0].Attempts != c.attempts {
+				t.Fatalf("dead letter = %+v, want event %q attempts %d", letters[0], events[0].ID, c.attempts)
+			}
+			if !strings.Contains(letters[0].Error, "unexpected status code") {
+				t.Fatalf("dead letter error = %q", letters[0].Error)
+			}
+		})
+	}
+}
+
+func TestWebhookRetryDoesNotBlockWorker(t *testing.T) {
+	recorder := &webhookRecorder{t: t, statuses: []int{http.StatusServiceUnavailable, http.StatusOK}}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	options := testWebhookOptions(server.URL, "")
+	options.InitialBackoff = 200 * time.Millisecond
+	options.MaxBackoff = 200 * time.Millisecond
+
+	start := webhookSent.Value() + webhookDead.Value()
+	s := newTestWebhookService(t, options)
+	s.notifyOffline("bob", testOfflineMessage("first"))
+	s.notifyOffline("bob", testOfflineMessage("second"))
+	waitWebhookDone(t, start, 2)
+	if err := s.closeWebhook(); err != nil {
+		t.Fatalf("closeWebhook failed: %v", err)
+	}
+
+	// 只有一个 worker，第一个通知等待重试时第二个通知已经发出去了
+	var contents []string
+	for _, event := range recorder.events() {
+		contents = append(contents, event.Message.Content)
+	}
+	if got, want := strings.Join(contents, ","), "first,second,first"; got != want {
+		t.Fatalf("requests = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookCloseDeadLettersPendingRetries(t *testing.T) {
+	recorder := &webhookRecorder{t: t, statuses: []int{http.StatusBadGateway}}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+	deadLetterFile := filepath.Join(t.TempDir(), "webhook.log")
+
+	options := testWebhookOptions(server.URL, deadLetterFile)
+	options.InitialBackoff = time.Hour
+	options.MaxBackoff = time.Hour
+
+	s := newTestWebhookService(t, options)
+	s.notifyOffline("bob", testOfflineMessage("hello"))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.webhook.timerMutex.Lock()
+		pending := len(s.webhook.timers)
+		s.webhook.timerMutex.Unlock()
+		if pending == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("retry not scheduled in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 等待一小时的重试不会拖住关闭，直接进入死信
+	if err := s.closeWebhook(); err != nil {
+		t.Fatalf("closeWebhook failed: %v", err)
+	}
+	letters := readDeadLetters(t, deadLetterFile)
+	if len(letters) != 1 || letters[0].Attempts != 1 || !strings.Contains(letters[0].Error, "server closed") {
+		t.Fatalf("unexpected dead letters %+v", letters)
+	}
+}
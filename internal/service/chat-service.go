@@ -2,38 +2,159 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"io"
 	"sync"
+	"time"
 
 	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/cluster"
 	"github.com/hatlonely/chat-server/internal/storage"
 
 	"github.com/hatlonely/go-kit/logger"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Options struct {
+	Storage     storage.Options
+	UserStorage storage.UserStorageOptions
+	Auth        AuthOptions
+	Outbound    OutboundOptions
+	Typing      TypingOptions
+	History     HistoryOptions
+	Search      SearchOptions
+	Webhook     WebhookOptions
+	// 多节点部署时需要使用共享的存储，比如 MySQL
+	Cluster cluster.Options
 }
 
-func NewChatServiceWithOptions(options *Options) (*ChatService, error) {
-	return &ChatService{
-		options: options,
-		conns:   sync.Map{},
-		rpcLog:  logger.NewStdoutJsonLogger(),
-		storage: storage.NewLocalChatStorageWithOptions(),
-	}, nil
+// NewChatServiceWithOptions 返回时后台的 goroutine 已经开始使用 rpcLog，rpcLog 为 nil 时输出到标准输出
+func NewChatServiceWithOptions(options *Options, rpcLog *logger.Logger) (*ChatService, error) {
+	// 每个节点的本地存储互相看不到，消息和用户只在写入的节点上
+	if options.Cluster.Type == "Grpc" && (options.Storage.Type == "" || options.Storage.Type == "Local" ||
+		options.UserStorage.Type == "" || options.UserStorage.Type == "Local") {
+		return nil, errors.New("grpc message bus requires shared storage, for example Mysql")
+	}
+	// 默认值由 bind 按 dft 标签填充，这里只检查配置之间是否矛盾
+	switch options.Outbound.OverflowPolicy {
+	case OverflowPolicyDropOldest, OverflowPolicyDisconnect, OverflowPolicySpill:
+	default:
+		return nil, errors.Errorf("unsupported overflow policy [%s]", options.Outbound.OverflowPolicy)
+	}
+	if options.Outbound.QueueSize <= 0 {
+		return nil, errors.Errorf("outbound queue size [%d] must be positive", options.Outbound.QueueSize)
+	}
+	if options.History.MaxLimit < options.History.DefaultLimit {
+		return nil, errors.Errorf("history max limit [%d] is less than default limit [%d]", options.History.MaxLimit, options.History.DefaultLimit)
+	}
+	if options.Search.MaxLimit < options.Search.DefaultLimit {
+		return nil, errors.Errorf("search max limit [%d] is less than default limit [%d]", options.Search.MaxLimit, options.Search.DefaultLimit)
+	}
+	if options.Webhook.URL != "" && (options.Webhook.Workers <= 0 || options.Webhook.InitialBackoff <= 0 ||
+		options.Webhook.MaxBackoff < options.Webhook.InitialBackoff) {
+		return nil, errors.New("webhook needs positive workers and initial backoff, and max backoff no less than initial backoff")
+	}
+	if rpcLog == nil {
+		rpcLog = logger.NewStdoutJsonLogger()
+	}
+	tokenSecret := []byte(options.Auth.TokenSecret)
+	if len(tokenSecret) == 0 {
+		tokenSecret = make([]byte, 32)
+		if _, err := rand.Read(tokenSecret); err != nil {
+			return nil, errors.Wrap(err, "rand.Read failed")
+		}
+		rpcLog.Warn(map[string]interface{}{
+			"message": "token secret is not set, use a random one",
+		})
+	}
+
+	// 和真实的密码哈希使用相同的 cost，校验耗时相同
+	dummyPasswordHash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), options.Auth.BcryptCost)
+	if err != nil {
+		return nil, errors.Wrap(err, "bcrypt.GenerateFromPassword failed")
+	}
+
+	chatStorage, err := storage.NewChatStorageWithOptions(&options.Storage)
+	if err != nil {
+		return nil, errors.WithMessage(err, "storage.NewChatStorageWithOptions failed")
+	}
+	userStorage, err := storage.NewUserStorageWithOptions(&options.UserStorage)
+	if err != nil {
+		_ = chatStorage.Close()
+		return nil, errors.WithMessage(err, "storage.NewUserStorageWithOptions failed")
+	}
+	bus, err := cluster.NewMessageBusWithOptions(&options.Cluster)
+	if err != nil {
+		_ = chatStorage.Close()
+		_ = userStorage.Close()
+		return nil, errors.WithMessage(err, "cluster.NewMessageBusWithOptions failed")
+	}
+	var webhook *webhookSender
+	if options.Webhook.URL != "" {
+		if webhook, err = newWebhookSender(&options.Webhook); err != nil {
+			_ = bus.Close()
+			_ = chatStorage.Close()
+			_ = userStorage.Close()
+			return nil, errors.WithMessage(err, "newWebhookSender failed")
+		}
+	}
+
+	s := &ChatService{
+		options:           options,
+		conns:             sync.Map{},
+		rpcLog:            rpcLog,
+		storage:           chatStorage,
+		userStorage:       userStorage,
+		tokenSecret:       tokenSecret,
+		dummyPasswordHash: dummyPasswordHash,
+		shutdown:          make(chan struct{}),
+		webhook:           webhook,
+		bus:               bus,
+		registry:          cluster.NewSessionRegistryWithOptions(bus, &options.Cluster.Registry),
+	}
+	s.publisher = cluster.NewPublishQueue(bus, options.Cluster.QueueSize, s.publishFailed)
+	s.bus.Subscribe(s.handleEnvelope)
+	s.startWebhook()
+	return s, nil
 }
 
 type ChatService struct {
 	api.UnsafeChatServiceServer
 
-	options *Options
-	conns   sync.Map
-	storage storage.ChatStorage
+	options     *Options
+	storage     storage.ChatStorage
+	userStorage storage.UserStorage
+	tokenSecret []byte
+	// 用户不存在时用来校验密码，避免通过耗时判断用户是否存在
+	dummyPasswordHash []byte
+
+	// shutdown 关闭之后不再接受新连接，handlers 记录还没退出的连接
+	shutdown      chan struct{}
+	shutdownMutex sync.Mutex
+	handlers      sync.WaitGroup
+
+	// username -> *userSessions，sessionMutex 保证注册和注销的原子性
+	conns        sync.Map
+	sessionMutex sync.Mutex
+
+	// 接收方不在线时通知 webhook，没有配置时为 nil
+	webhook *webhookSender
+
+	// 节点之间转发消息，registry 记录用户在哪些其他节点上有会话，publisher 是发给每个节点的队列
+	bus       cluster.MessageBus
+	registry  *cluster.SessionRegistry
+	publisher *cluster.PublishQueue
 
 	rpcLog *logger.Logger
 }
 
-func (s *ChatService) setErr(stream api.ChatService_ChatServer, code api.ServerMessage_Err_Code, message string) error {
+// messageSender 认证之前直接写 stream，认证之后通过会话的发送队列
+type messageSender interface {
+	Send(*api.ServerMessage) error
+}
+
+func (s *ChatService) setErr(stream messageSender, code api.ServerMessage_Err_Code, message string) error {
 	res := &api.ServerMessage{
 		Type: api.ServerMessage_SMTErr,
 		Err: &api.ServerMessage_Err{
@@ -50,144 +171,379 @@ func (s *ChatService) setErr(stream api.ChatService_ChatServer, code api.ServerM
 	return errors.New(message)
 }
 
-func (s *ChatService) auth(stream api.ChatService_ChatServer) (*api.ClientMessage_Auth, error) {
+// notifyErr 通知客户端操作失败，但不中断连接
+func (s *ChatService) notifyErr(stream messageSender, code api.ServerMessage_Err_Code, message string) error {
+	res := &api.ServerMessage{
+		Type: api.ServerMessage_SMTErr,
+		Err: &api.ServerMessage_Err{
+			Code:    code,
+			Message: message,
+		},
+	}
+	if err := stream.Send(res); err != nil {
+		s.rpcLog.Error(err)
+		return errors.Wrap(err, "stream.Send failed")
+	}
+	s.rpcLog.Warn(res)
+
+	return nil
+}
+
+func (s *ChatService) auth(stream api.ChatService_ChatServer) (*session, *api.ClientMessage_Auth, error) {
 	message, err := stream.Recv()
 	if err != nil {
-		return nil, errors.Wrap(err, "stream.Recv failed")
+		return nil, nil, errors.Wrap(err, "stream.Recv failed")
 	}
 	s.rpcLog.Info(message)
 
 	// 拒绝非授权请求
-	if message.Type != api.ClientMessage_CMTAuth {
-		return nil, s.setErr(stream, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要授权信息")
+	if message.Type != api.ClientMessage_CMTAuth || message.Auth == nil {
+		return nil, nil, s.setErr(stream, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要授权信息")
 	}
-	// 处理授权
+	// 等待认证消息期间服务可能已经开始关闭
+	if s.closing() {
+		return nil, nil, errShuttingDown
+	}
+	// 处理授权，拦截器已经校验过 token 或者证书的不需要密码，否则密码登录后签发新的 token
+	var token *api.Token
+	var tokenID string
+	if id, ok := identityFromContext(stream.Context()); ok {
+		if message.Auth.Username != "" && message.Auth.Username != id.username {
+			return nil, nil, s.setErr(stream, api.ServerMessage_Err_AuthFailed, "用户名和认证信息不匹配")
+		}
+		message.Auth.Username = id.username
+		tokenID = id.tokenID
+	} else {
+		if err := s.login(stream, message.Auth); err != nil {
+			return nil, nil, err
+		}
+		token, tokenID, err = s.issueToken(message.Auth.Username, "")
+		if err != nil {
+			s.rpcLog.Error(err)
+			return nil, nil, s.setErr(stream, api.ServerMessage_Err_Internal, "内部错误")
+		}
+	}
+	sess := newSession(stream, message.Auth.Username, s.options.Outbound.QueueSize)
+	sess.tokenID = tokenID
 	res := &api.ServerMessage{
 		Type: api.ServerMessage_SMTAuth,
+		Auth: &api.ServerMessage_Auth{
+			SessionId: sess.id,
+			Token:     token,
+		},
 	}
 	if err := stream.Send(res); err != nil {
 		s.rpcLog.Error(err)
-		return nil, errors.Wrap(err, "stream.Send failed")
+		return nil, nil, errors.Wrap(err, "stream.Send failed")
 	}
 	s.rpcLog.Info(res)
 
-	return message.Auth, nil
+	return sess, message.Auth, nil
 }
 
-func (s *ChatService) history(stream api.ChatService_ChatServer, auth *api.ClientMessage_Auth) error {
-	messages := s.storage.GetMessageByUser(auth.Username, 0)
+func (s *ChatService) history(stream messageSender, auth *api.ClientMessage_Auth) error {
+	// 只补发客户端还没收到的消息，写到 stream 之后记录投递位置
+	messages, err := s.undelivered(auth)
+	if err != nil {
+		return errors.WithMessage(err, "undelivered failed")
+	}
 	for _, message := range messages {
 		res := &api.ServerMessage{
 			Type: api.ServerMessage_SMTChat,
-			Chat: &api.ServerMessage_Chat{
-				From:    message.From,
-				Content: message.Content,
-			},
+			Chat: chatMessageToApi(message),
 		}
 		if err := stream.Send(res); err != nil {
 			s.rpcLog.Error(err)
 			return errors.Wrap(err, "stream.Send failed")
 		}
 	}
+
+	if err := s.roomHistory(stream, auth); err != nil {
+		return errors.WithMessage(err, "roomHistory failed")
+	}
 	return nil
 }
 
-func (s *ChatService) conn(stream api.ChatService_ChatServer, auth *api.ClientMessage_Auth) (string, error) {
-	s.conns.Store(auth.Username, stream)
-	return "", nil
+func chatMessageToApi(message *storage.ChatMessage) *api.ServerMessage_Chat {
+	chat := &api.ServerMessage_Chat{
+		From:      message.From,
+		Content:   message.Content,
+		Seq:       message.Seq,
+		Timestamp: message.Timestamp.UnixNano() / int64(time.Millisecond),
+		Room:      message.Room,
+		To:        message.To,
+		MsgId:     message.MsgID,
+		Deleted:   message.DeletedAt != nil,
+		Reactions: reactionsToApi(message.Reactions),
+		ReplyTo:   message.ReplyTo,
+		// 房间消息的序号就是会话中的序号
+		ConversationSeq: message.ConversationSeq,
+	}
+	if message.Room != "" {
+		chat.ConversationSeq = message.Seq
+	}
+	if message.EditedAt != nil {
+		chat.EditedAt = message.EditedAt.UnixNano() / int64(time.Millisecond)
+	}
+	if message.ExpiresAt != nil {
+		chat.ExpiresAt = message.ExpiresAt.UnixNano() / int64(time.Millisecond)
+	}
+	if message.Parent != nil {
+		chat.Parent = chatMessageToApi(message.Parent)
+	}
+	return chat
+}
+
+func (s *ChatService) conn(ctx context.Context, cancel context.CancelFunc, sess *session) (string, error) {
+	sess.start(ctx, cancel)
+	go s.writeLoop(sess)
+
+	if s.addSession(sess) {
+		s.registry.Set(sess.username, api.ServerMessage_Presence_Online)
+		// 已经在其他节点上线的用户，联系人已经收到过通知
+		if len(s.registry.Nodes(sess.username)) == 0 {
+			s.broadcastPresence(sess.username, api.ServerMessage_Presence_Online)
+		}
+	}
+	return sess.id, nil
 }
 
-func (s *ChatService) chat(stream api.ChatService_ChatServer) (*api.ClientMessage_Chat, error) {
-	message, err := stream.Recv()
+func (s *ChatService) disconn(sess *session) {
+	s.clearTyping(sess)
+	if s.removeSession(sess) {
+		s.registry.Set(sess.username, api.ServerMessage_Presence_Offline)
+		if len(s.registry.Nodes(sess.username)) == 0 {
+			s.broadcastPresence(sess.username, api.ServerMessage_Presence_Offline)
+		}
+	}
+	// 等发送队列里剩下的消息发完，handler 返回之后不能再使用 stream
+	sess.stop()
+}
+
+func (s *ChatService) chat(sess *session) (*api.ClientMessage, error) {
+	message, err := sess.stream.Recv()
 	if err != nil {
 		return nil, errors.Wrap(err, "stream.Recv failed")
 	}
 	s.rpcLog.Info(message)
 
-	if message.Type != api.ClientMessage_CMTChat {
-		return nil, s.setErr(stream, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要聊天信息")
+	switch message.Type {
+	case api.ClientMessage_CMTChat:
+		if message.Chat == nil {
+			return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要聊天信息")
+		}
+	case api.ClientMessage_CMTRoom:
+		if message.Room == nil {
+			return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要房间信息")
+		}
+	case api.ClientMessage_CMTPresence:
+		if message.Presence == nil {
+			return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要状态信息")
+		}
+	case api.ClientMessage_CMTRead:
+		if message.Read == nil || (message.Read.Peer == "" && message.Read.Room == "") {
+			return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要已读信息")
+		}
+	case api.ClientMessage_CMTTyping:
+		if message.Typing == nil || (message.Typing.To == "" && message.Typing.Room == "") {
+			return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要输入状态")
+		}
+	case api.ClientMessage_CMTEdit:
+		if message.Edit == nil {
+			return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要修改信息")
+		}
+	case api.ClientMessage_CMTDelete:
+		if message.Delete == nil {
+			return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要删除信息")
+		}
+	case api.ClientMessage_CMTReact:
+		if message.React == nil || message.React.Emoji == "" || len(message.React.Emoji) > maxEmojiLen {
+			return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要表情回应")
+		}
+	case api.ClientMessage_CMTThread:
+		if message.Thread == nil {
+			return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要消息序号")
+		}
+	default:
+		return nil, s.setErr(sess, api.ServerMessage_Err_ProtocolMismatch, "协议错误：需要聊天信息")
 	}
 
-	return message.Chat, nil
+	return message, nil
 }
 
-func (s *ChatService) chatLoop(stream api.ChatService_ChatServer, msgChan chan<- *api.ClientMessage_Chat, errChan chan<- error) {
+func (s *ChatService) chatLoop(ctx context.Context, sess *session, msgChan chan<- *api.ClientMessage, errChan chan<- error) {
 	for {
-		message, err := s.chat(stream)
+		message, err := s.chat(sess)
 		if err != nil {
-			errChan <- err
-			break
+			select {
+			case errChan <- err:
+			case <-ctx.Done():
+			}
+			return
 		}
-		msgChan <- message
+		select {
+		case msgChan <- message:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send 投递给接收方并同步给自己的其他设备，接收方至少有一个在线会话时返回 true
+func (s *ChatService) send(sess *session, fromMessage *storage.ChatMessage, toMessage *storage.ChatMessage) bool {
+	delivered := s.sendToSessions(toMessage.To, "", &api.ServerMessage{
+		Type: api.ServerMessage_SMTChat,
+		Chat: chatMessageToApi(toMessage),
+	})
+	if delivered == 0 {
+		s.notifyOffline(toMessage.To, toMessage)
+	}
+	// 同步给自己的其他设备
+	if fromMessage.From != fromMessage.To {
+		s.sendToSessions(fromMessage.From, sess.id, &api.ServerMessage{
+			Type: api.ServerMessage_SMTChat,
+			Chat: chatMessageToApi(fromMessage),
+		})
 	}
+	return delivered > 0
 }
 
-func (s *ChatService) send(stream api.ChatService_ChatServer, username string, message *api.ClientMessage_Chat) error {
-	conn, ok := s.conns.Load(message.To)
-	if !ok {
-		return nil
+func (s *ChatService) handleChat(sess *session, msg *api.ClientMessage_Chat) error {
+	// 消息发出去了输入也就结束了
+	s.stopTyping(sess, msg.To, msg.Room)
+
+	if msg.Ttl < 0 {
+		return s.ack(sess, msg, api.ServerMessage_Ack_Failed, 0, "ttl 不能为负数")
+	}
+	if msg.Room != "" {
+		return s.handleRoomChat(sess, msg)
 	}
 
-	toStream := conn.(api.ChatService_ChatServer)
+	fromMessage, toMessage, err := s.storage.PutMessage(msg.MsgId, sess.username, msg.To, msg.Content, msg.ReplyTo, time.Duration(msg.Ttl)*time.Second)
+	if errors.Cause(err) == storage.ErrDuplicateMessage {
+		// 客户端重试，之前已经保存并投递过
+		return s.ack(sess, msg, api.ServerMessage_Ack_Stored, fromMessage.Seq, "")
+	}
+	if errors.Cause(err) == storage.ErrMessageNotFound {
+		return s.ack(sess, msg, api.ServerMessage_Ack_Failed, 0, "回复的消息不存在")
+	}
+	if err != nil {
+		s.rpcLog.Error(errors.WithMessage(err, "storage.PutMessage failed"))
+		return s.ack(sess, msg, api.ServerMessage_Ack_Failed, 0, "内部错误")
+	}
 
+	status := api.ServerMessage_Ack_Stored
+	if s.send(sess, fromMessage, toMessage) {
+		status = api.ServerMessage_Ack_Delivered
+	}
+	return s.ack(sess, msg, status, fromMessage.Seq, "")
+}
+
+func (s *ChatService) ack(sess *session, msg *api.ClientMessage_Chat, status api.ServerMessage_Ack_Status, seq int64, reason string) error {
 	res := &api.ServerMessage{
-		Type: api.ServerMessage_SMTChat,
-		Chat: &api.ServerMessage_Chat{
-			From:    username,
-			Content: message.Content,
+		Type: api.ServerMessage_SMTAck,
+		Ack: &api.ServerMessage_Ack{
+			MsgId:  msg.MsgId,
+			Status: status,
+			Seq:    seq,
+			Room:   msg.Room,
+			Reason: reason,
 		},
 	}
-	if err := toStream.Send(res); err != nil {
+	if err := sess.Send(res); err != nil {
 		s.rpcLog.Error(err)
 		return errors.Wrap(err, "stream.Send failed")
 	}
-	s.rpcLog.Info(res)
-
 	return nil
 }
 
 func (s *ChatService) Chat(stream api.ChatService_ChatServer) error {
-	auth, err := s.auth(stream)
+	if s.closing() {
+		return errShuttingDown
+	}
+	sess, auth, err := s.auth(stream)
+	if err == errShuttingDown {
+		return err
+	}
 	if err != nil {
 		return errors.WithMessage(err, "auth failed")
 	}
+	if !s.enter() {
+		return errShuttingDown
+	}
+	defer s.leave()
+
+	// 发送失败或者 Disconnect 策略会取消 ctx
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
 
-	_, err = s.conn(stream, auth)
+	_, err = s.conn(ctx, cancel, sess)
 	if err != nil {
 		return errors.WithMessage(err, "conn failed")
 	}
+	// 无论是客户端断开、出错还是 context 取消，都要注销会话
+	defer s.disconn(sess)
 
-	err = s.history(stream, auth)
+	err = s.history(sess, auth)
 	if err != nil {
 		return errors.WithMessage(err, "history failed")
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	err = s.unread(sess, auth)
+	if err != nil {
+		return errors.WithMessage(err, "unread failed")
+	}
 
+	err = s.presence(sess, auth)
+	if err != nil {
+		return errors.WithMessage(err, "presence failed")
+	}
+
+	// chatLoop 在 ctx 取消后退出，channel 不需要关闭
 	errChan := make(chan error, 5)
-	msgChan := make(chan *api.ClientMessage_Chat, 5)
-	defer close(msgChan)
-	defer close(errChan)
+	msgChan := make(chan *api.ClientMessage, 5)
 
-	go s.chatLoop(stream, msgChan, errChan)
+	go s.chatLoop(ctx, sess, msgChan, errChan)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-s.shutdown:
+			return s.drain(sess, msgChan)
 		case err := <-errChan:
-			cancel()
+			if errors.Cause(err) == io.EOF {
+				return nil
+			}
 			return err
 		case msg := <-msgChan:
-			if err := s.storage.PutMessage(auth.Username, msg.To, msg.Content); err != nil {
-				if err := s.setErr(stream, api.ServerMessage_Err_AuthFailed, "内部错误"); err != nil {
-					errChan <- err
-				}
-			}
-			if err := s.send(stream, auth.Username, msg); err != nil {
+			if err := s.handle(sess, msg); err != nil {
 				errChan <- err
 			}
 		}
 	}
 }
+
+func (s *ChatService) handle(sess *session, msg *api.ClientMessage) error {
+	switch msg.Type {
+	case api.ClientMessage_CMTChat:
+		return s.handleChat(sess, msg.Chat)
+	case api.ClientMessage_CMTRoom:
+		return s.handleRoom(sess, msg.Room)
+	case api.ClientMessage_CMTPresence:
+		s.setPresence(sess, msg.Presence.Status)
+	case api.ClientMessage_CMTRead:
+		return s.handleRead(sess, msg.Read)
+	case api.ClientMessage_CMTTyping:
+		return s.handleTyping(sess, msg.Typing)
+	case api.ClientMessage_CMTEdit:
+		return s.handleEdit(sess, msg.Edit)
+	case api.ClientMessage_CMTDelete:
+		return s.handleDelete(sess, msg.Delete)
+	case api.ClientMessage_CMTReact:
+		return s.handleReact(sess, msg.React)
+	case api.ClientMessage_CMTThread:
+		return s.handleThread(sess, msg.Thread)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/chat-server/api/gen/go/api"
+	"github.com/hatlonely/chat-server/internal/cluster"
+
+	"github.com/hatlonely/go-kit/logger"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestChatService 单节点、数据只在内存中。构造函数不再填默认值，除了 bcrypt 用最低的代价，选项和 dft 标签一致
+func newTestChatService(t *testing.T, policy string, queueSize int) *ChatService {
+	t.Helper()
+	s, err := NewChatServiceWithOptions(&Options{
+		Auth: AuthOptions{
+			BcryptCost:             bcrypt.MinCost,
+			TokenSecret:            "token-secret",
+			AccessTokenExpiration:  time.Hour,
+			RefreshTokenExpiration: 720 * time.Hour,
+		},
+		Outbound: OutboundOptions{QueueSize: queueSize, OverflowPolicy: policy},
+		Typing:   TypingOptions{Timeout: 5 * time.Second, MaxEntries: 32},
+		History:  HistoryOptions{DefaultLimit: 50, MaxLimit: 200},
+		Search:   SearchOptions{DefaultLimit: 20, MaxLimit: 100},
+		Cluster: cluster.Options{
+			Type:      "Local",
+			QueueSize: 1024,
+			Registry:  cluster.SessionRegistryOptions{SyncInterval: 10 * time.Second, Expiration: 30 * time.Second},
+		},
+	}, logger.NewStdoutJsonLogger())
+	if err != nil {
+		t.Fatalf("NewChatServiceWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	})
+	return s
+}
+
+// newTestSession 注册一个没有 writeLoop 的会话，发出的消息留在队列中由测试读取
+func newTestSession(t *testing.T, s *ChatService, username string, tokenID string) *session {
+	t.Helper()
+	sess := newSession(nil, username, s.options.Outbound.QueueSize)
+	sess.tokenID = tokenID
+	ctx, cancel := context.WithCancel(context.Background())
+	sess.start(ctx, cancel)
+	s.addSession(sess)
+	t.Cleanup(func() {
+		cancel()
+		s.removeSession(sess)
+		outboundQueueSession.Delete(sess.id)
+	})
+	return sess
+}
+
+// drainSession 取出队列中的全部消息
+func drainSession(sess *session) []*api.ServerMessage {
+	var res []*api.ServerMessage
+	for {
+		select {
+		case msg := <-sess.queue:
+			sess.incr(-1)
+			res = append(res, msg)
+		default:
+			return res
+		}
+	}
+}
+
+func canceled(sess *session) bool {
+	select {
+	case <-sess.ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHandleChatDedupe(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		msgIDs []string
+		// 接收方收到的消息数和存储中的消息数
+		want       int
+		wantStatus []api.ServerMessage_Ack_Status
+	}{
+		{
+			name:       "retry with same msg id",
+			msgIDs:     []string{"m1", "m1"},
+			want:       1,
+			wantStatus: []api.ServerMessage_Ack_Status{api.ServerMessage_Ack_Delivered, api.ServerMessage_Ack_Stored},
+		},
+		{
+			name:       "different msg ids",
+			msgIDs:     []string{"m1", "m2"},
+			want:       2,
+			wantStatus: []api.ServerMessage_Ack_Status{api.ServerMessage_Ack_Delivered, api.ServerMessage_Ack_Delivered},
+		},
+		{
+			name:       "empty msg id",
+			msgIDs:     []string{"", ""},
+			want:       2,
+			wantStatus: []api.ServerMessage_Ack_Status{api.ServerMessage_Ack_Delivered, api.ServerMessage_Ack_Delivered},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestChatService(t, OverflowPolicySpill, 16)
+			alice := newTestSession(t, s, "alice", "")
+			bob := newTestSession(t, s, "bob", "")
+
+			for _, msgID := range c.msgIDs {
+				if err := s.handleChat(alice, &api.ClientMessage_Chat{MsgId: msgID, To: "bob", Content: "hello"}); err != nil {
+					t.Fatalf("handleChat failed: %v", err)
+				}
+			}
+
+			acks := drainSession(alice)
+			if len(acks) != len(c.msgIDs) {
+				t.Fatalf("acks = %d, want %d", len(acks), len(c.msgIDs))
+			}
+			for i, ack := range acks {
+				if ack.Type != api.ServerMessage_SMTAck || ack.Ack.Status != c.wantStatus[i] || ack.Ack.MsgId != c.msgIDs[i] {
+					t.Fatalf("ack[%d] = %v, want status %v", i, ack, c.wantStatus[i])
+				}
+			}
+			// 重试返回第一次保存的序号
+			if c.want == 1 && acks[0].Ack.Seq != acks[1].Ack.Seq {
+				t.Fatalf("retry ack seq = %d, want %d", acks[1].Ack.Seq, acks[0].Ack.Seq)
+			}
+
+			if got := len(drainSession(bob)); got != c.want {
+				t.Fatalf("bob received %d messages, want %d", got, c.want)
+			}
+			messages, err := s.storage.GetMessageByUser("bob", 0)
+			if err != nil {
+				t.Fatalf("GetMessageByUser failed: %v", err)
+			}
+			if len(messages) != c.want {
+				t.Fatalf("stored %d messages, want %d", len(messages), c.want)
+			}
+		})
+	}
+}
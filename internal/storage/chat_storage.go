@@ -1,6 +1,212 @@
 package storage
 
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 私聊消息在双方的会话中只保存一份，序号属于会话。每个用户还有一个私聊消息的索引，索引中的序号就是用户信箱的序号，
+// 下面私聊相关的接口里 seq 都是信箱的序号，返回的消息 Seq 和 ReplyTo 也换成了读者信箱中的序号
 type ChatStorage interface {
-	PutMessage(from string, to string, content string) error
-	GetMessageByUser(from string, seq int64) []*ChatMessage
+	// PutMessage 消息写入会话，并加入发送方和接收方的索引，返回发送方和接收方看到的消息，自己发给自己时两者相同
+	// msgID 由客户端生成，同一个发送方重复的 msgID 不会重复写入，返回之前保存的消息和 ErrDuplicateMessage
+	// replyTo 不为 0 时是回复的消息在发送方信箱中的序号，ttl 不为 0 时消息到期后删除
+	PutMessage(msgID string, from string, to string, content string, replyTo int64, ttl time.Duration) (*ChatMessage, *ChatMessage, error)
+	// GetMessageByUser 按信箱的序号返回所有私聊会话中的消息，不返回自己删除的消息，为所有人删除的消息只返回墓碑
+	GetMessageByUser(from string, seq int64) ([]*ChatMessage, error)
+	// GetConversations 返回用户参与的私聊会话和房间，按会话 ID 排序
+	GetConversations(username string) ([]*Conversation, error)
+	// GetHistory 分页读私聊会话（peer）或者房间（room）的消息，before 和 after 是会话中的序号，不为 0 时只返回 (after, before) 之间的消息
+	// 有 before 或者都为 0 时返回最后 limit 条，否则返回 after 之后的前 limit 条，结果按序号升序。不返回自己删除的消息
+	GetHistory(username string, peer string, room string, before int64, after int64, limit int) ([]*ChatMessage, error)
+	// SearchMessages 在自己的私聊会话和所在的房间中搜索包含关键词的消息，不返回已删除的消息，按时间倒序
+	SearchMessages(username string, query *SearchQuery) ([]*ChatMessage, error)
+
+	// CreateRoom 创建房间，创建者自动成为成员
+	CreateRoom(room string, owner string) error
+	JoinRoom(room string, username string) error
+	LeaveRoom(room string, username string) error
+	GetRoomMembers(room string) ([]string, error)
+	GetRoomsByUser(username string) ([]string, error)
+	// PutRoomMessage 房间消息只保存一份，序号属于房间。msgID 去重和 PutMessage 一样
+	PutRoomMessage(msgID string, room string, from string, content string, replyTo int64, ttl time.Duration) (*ChatMessage, error)
+	// GetMessageByRoom username 不为空时不返回该用户自己删除的消息
+	GetMessageByRoom(room string, username string, seq int64) ([]*ChatMessage, error)
+
+	// EditMessage 修改自己发送的私聊消息，seq 属于 username 的信箱。旧内容保存为历史版本
+	// 返回自己和对方看到的修改后的消息，自己发给自己时对方的为 nil
+	EditMessage(username string, seq int64, content string) (*ChatMessage, *ChatMessage, error)
+	EditRoomMessage(room string, username string, seq int64, content string) (*ChatMessage, error)
+	// DeleteMessage forEveryone 时只能删除自己发送的消息，消息变成墓碑；否则只对自己隐藏，对方的返回 nil
+	DeleteMessage(username string, seq int64, forEveryone bool) (*ChatMessage, *ChatMessage, error)
+	DeleteRoomMessage(room string, username string, seq int64, forEveryone bool) (*ChatMessage, error)
+
+	// ReactMessage 给私聊消息加上或者去掉表情回应，seq 属于 username 的信箱
+	// 已经是目标状态时不报错，返回自己和对方看到的当前消息
+	ReactMessage(username string, seq int64, emoji string, remove bool) (*ChatMessage, *ChatMessage, error)
+	ReactRoomMessage(room string, username string, seq int64, emoji string, remove bool) (*ChatMessage, error)
+
+	// GetThread 返回一条消息和直接回复它的消息，seq 和 EditMessage 一样，不返回自己删除的回复
+	GetThread(username string, room string, seq int64) (*ChatMessage, []*ChatMessage, error)
+
+	// GetContacts 返回私聊过的用户和同一房间的成员，不包括自己
+	GetContacts(username string) ([]string, error)
+
+	// UpdateReadCursor 更新已读位置，私聊时 peer 不为空，seq 属于自己的信箱；房间时 room 不为空，seq 属于房间
+	// 已读位置只会前进，超过最大序号时取最大序号。返回更新后的位置和更新前的序号，两者相同表示没有前进
+	// 私聊的已读位置前进时同时返回对方信箱中对应的序号 PeerSeq
+	UpdateReadCursor(username string, peer string, room string, seq int64) (*ReadCursor, int64, error)
+	// GetUnreadCounts 返回每个会话中别人发来的、已读位置之后的消息数，不包括已删除的消息，没有未读消息的会话不返回
+	GetUnreadCounts(username string) ([]*UnreadCount, error)
+
+	// GetUndelivered 离线队列，信箱中投递位置之后的消息，不返回自己删除的消息
+	GetUndelivered(username string) ([]*ChatMessage, error)
+	// MarkDelivered 投递位置前进到 seq，seq 属于自己的信箱，只会前进，超过最大序号时取最大序号
+	// 返回这次新投递的别人发来的消息，消息是发送方看到的，Seq 属于发送方的信箱
+	MarkDelivered(username string, seq int64) ([]*ChatMessage, error)
+
+	Close() error
+}
+
+// ReadCursor 已读位置，Timestamp 是已读到的那条消息的时间，私聊时对方用它对应自己信箱里的消息
+type ReadCursor struct {
+	Peer      string `json:",omitempty"`
+	Room      string `json:",omitempty"`
+	Seq       int64
+	Timestamp time.Time
+	// 私聊时 Seq 之前最后一条和 Peer 的消息在 Peer 信箱中的序号，没有时为 0。只在 UpdateReadCursor 中返回，不保存
+	PeerSeq int64 `json:"-"`
+}
+
+// MessageRevision 消息修改前的一个版本，Timestamp 是这个版本写入的时间
+type MessageRevision struct {
+	Content   string
+	Timestamp time.Time
+}
+
+// Reaction 同一个表情的回应，按第一次回应的时间排序
+type Reaction struct {
+	Emoji string
+	Users []string
+}
+
+// SearchQuery 关键词中的所有词都出现才算匹配，中文没有空格，按相邻两个字匹配
+type SearchQuery struct {
+	Keyword string
+	// 发送方，为空时不限制
+	From string
+	// 时间范围 [Since, Until)，零值表示不限制
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+type UnreadCount struct {
+	Peer  string
+	Room  string
+	Count int64
+}
+
+var (
+	ErrRoomNotFound  = errors.New("room not found")
+	ErrRoomExists    = errors.New("room exists")
+	ErrNotRoomMember = errors.New("not room member")
+	// ErrDuplicateMessage 消息已经保存过，同时会返回之前保存的消息
+	ErrDuplicateMessage = errors.New("duplicate message")
+	// ErrMessageNotFound 消息不存在、已经为所有人删除或者已经被自己删除
+	ErrMessageNotFound  = errors.New("message not found")
+	ErrNotMessageSender = errors.New("not message sender")
+)
+
+// Conversation 私聊时 Peer 不为空，房间时 Room 不为空。Seq 是会话中最后一条消息的序号
+type Conversation struct {
+	ID   string
+	Peer string `json:",omitempty"`
+	Room string `json:",omitempty"`
+	Seq  int64
+}
+
+// DirectConversation 私聊会话 ID，和双方的顺序无关
+func DirectConversation(a string, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return directConversationPrefix + a + "\x00" + b
+}
+
+func RoomConversation(room string) string {
+	return roomConversationPrefix + room
+}
+
+const (
+	directConversationPrefix = "user:"
+	roomConversationPrefix   = "room:"
+)
+
+// expiresAt ttl 为 0 时不过期
+func expiresAt(now time.Time, ttl time.Duration) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+	t := now.Add(ttl)
+	return &t
+}
+
+// peerOwner 私聊消息的另一方，自己发给自己时是自己
+func peerOwner(owner string, message *ChatMessage) string {
+	if message.To == owner {
+		return message.From
+	}
+	return message.To
+}
+
+// RetentionPolicy 消息的保留策略，0 表示不限制
+type RetentionPolicy struct {
+	// 超过这个时间的消息删除
+	MaxAge time.Duration
+	// 每个会话只保留最新的这么多条消息
+	MaxCount int
+}
+
+// RetentionOptions 后台定时清理过期的消息，包括超过保留策略的消息和发送方设置了 ttl 已经到期的消息
+type RetentionOptions struct {
+	// 默认的保留策略，同 RetentionPolicy
+	MaxAge   time.Duration
+	MaxCount int
+	// 房间名 -> 房间单独的保留策略，完全替换默认策略
+	Rooms    map[string]*RetentionPolicy
+	Interval time.Duration `dft:"10s"`
+}
+
+// policy 会话使用的保留策略，私聊时 room 为空
+func (o *RetentionOptions) policy(room string) *RetentionPolicy {
+	if policy, ok := o.Rooms[room]; ok && room != "" {
+		return policy
+	}
+	return &RetentionPolicy{MaxAge: o.MaxAge, MaxCount: o.MaxCount}
+}
+
+type Options struct {
+	Type  string `dft:"Local"`
+	Local LocalChatStorageOptions
+	Mysql MysqlChatStorageOptions
+}
+
+func NewChatStorageWithOptions(options *Options) (ChatStorage, error) {
+	switch options.Type {
+	case "", "Local":
+		s, err := NewLocalChatStorageWithOptions(&options.Local)
+		if err != nil {
+			return nil, errors.WithMessage(err, "NewLocalChatStorageWithOptions failed")
+		}
+		return s, nil
+	case "Mysql":
+		s, err := NewMysqlChatStorageWithOptions(&options.Mysql)
+		if err != nil {
+			return nil, errors.WithMessage(err, "NewMysqlChatStorageWithOptions failed")
+		}
+		return s, nil
+	}
+	return nil, errors.Errorf("unsupported storage type [%s]", options.Type)
 }
@@ -1,30 +1,122 @@
 package storage
 
 import (
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 type LocalChatStorageOptions struct {
+	// 数据目录，为空时消息只保存在内存中
+	Directory string
+	// 日志刷盘策略：Always 每次写入后刷盘，Interval 定时刷盘，Never 交给操作系统
+	SyncPolicy       string        `dft:"Interval"`
+	SyncInterval     time.Duration `dft:"1s"`
+	SnapshotInterval time.Duration `dft:"10m"`
+	Retention        RetentionOptions
 }
 
-func NewLocalChatStorageWithOptions() *LocalChatStorage {
-	return &LocalChatStorage{
-		userMessagesMap: map[string]*ChatMessages{},
+func NewLocalChatStorageWithOptions(options *LocalChatStorageOptions) (*LocalChatStorage, error) {
+	s := &LocalChatStorage{
+		options:       options,
+		conversations: map[string]*localConversation{},
+		indexes:       map[string]*localUserIndex{},
+		rooms:         map[string]*localRoom{},
+		userRooms:     map[string]map[string]struct{}{},
+		userContacts:  map[string]map[string]struct{}{},
+		msgIDs:        map[string]*localMessageRef{},
+		terms:         map[string][]*localMessageRef{},
+		ttls:          map[localMessageRef]time.Time{},
+		cursors:       map[string]map[string]*ReadCursor{},
+		delivered:     map[string]int64{},
+	}
+
+	if options.Directory == "" {
+		s.done = make(chan struct{})
+		s.startJanitor()
+		return s, nil
+	}
+
+	wal, err := openWAL(options.Directory, options.SyncPolicy, options.SyncInterval)
+	if err != nil {
+		return nil, errors.WithMessage(err, "openWAL failed")
+	}
+	if err := wal.Replay(s.restore, s.apply); err != nil {
+		_ = wal.Close()
+		return nil, errors.WithMessage(err, "wal.Replay failed")
 	}
+	s.wal = wal
+
+	s.done = make(chan struct{})
+	if options.SnapshotInterval > 0 {
+		s.wg.Add(1)
+		go s.snapshotLoop()
+	}
+	s.startJanitor()
+
+	return s, nil
 }
 
 type LocalChatStorage struct {
-	userMessagesMap map[string]*ChatMessages
-	mutex           sync.RWMutex
+	options *LocalChatStorageOptions
+
+	// 会话 ID -> 会话，包括私聊和房间
+	conversations map[string]*localConversation
+	// 用户名 -> 私聊消息索引
+	indexes   map[string]*localUserIndex
+	rooms     map[string]*localRoom
+	userRooms map[string]map[string]struct{}
+	// 用户名 -> 私聊过的用户，包括自己
+	userContacts map[string]map[string]struct{}
+	// 客户端消息 ID 索引
+	msgIDs map[string]*localMessageRef
+	// 搜索的倒排索引，词 -> 当前内容包含这个词的消息。修改和删除时去掉旧内容中的词
+	terms map[string][]*localMessageRef
+	// 设置了 ttl 的消息 -> 过期时间，后台清理时不用扫描全部消息
+	ttls map[localMessageRef]time.Time
+	// 用户名 -> 会话 -> 已读位置
+	cursors map[string]map[string]*ReadCursor
+	// 用户名 -> 投递位置，信箱中之后的消息还在离线队列中
+	delivered map[string]int64
+	mutex     sync.RWMutex
+
+	// 写操作串行化，保证日志顺序和内存中的应用顺序一致
+	writeMutex sync.Mutex
+	wal        *localWAL
+	done       chan struct{}
+	wg         sync.WaitGroup
 }
 
 type ChatMessage struct {
+	// 私聊消息是读者信箱中的序号，房间消息是房间中的序号
 	Seq       int64
 	Timestamp time.Time
-	From      string
-	To        string
-	Content   string
+	// 客户端生成的消息 ID，用于去重
+	MsgID   string `json:",omitempty"`
+	From    string
+	To      string
+	Room    string `json:",omitempty"`
+	Content string
+	// 最后一次修改的时间，没有修改过为 nil
+	EditedAt *time.Time `json:",omitempty"`
+	// 修改前的各个版本，按时间顺序。读消息时不一定返回
+	Revisions []*MessageRevision `json:",omitempty"`
+	// 为所有人删除的时间，删除后内容和历史版本都会清空
+	DeletedAt *time.Time `json:",omitempty"`
+	// 私聊消息被读者自己删除，只在读出的消息中设置
+	Hidden    bool        `json:",omitempty"`
+	Reactions []*Reaction `json:",omitempty"`
+	// 回复的消息的序号，和 Seq 一样属于读者的信箱或者房间
+	ReplyTo int64 `json:",omitempty"`
+	// 私聊消息在会话中的序号，读消息时填充。房间消息的 Seq 就是会话中的序号，这里为 0
+	ConversationSeq int64 `json:",omitempty"`
+	// 发送方设置了 ttl 时的过期时间，过期之后由后台清理
+	ExpiresAt *time.Time `json:",omitempty"`
+	// 回复的消息，读消息时填充，不保存
+	Parent *ChatMessage `json:"-"`
 }
 
 type ChatMessages struct {
@@ -33,59 +125,495 @@ type ChatMessages struct {
 	mutex    sync.RWMutex
 }
 
-func (m *ChatMessages) Append(from string, to string, content string) {
+func (m *ChatMessages) Append(from string, to string, content string) *ChatMessage {
+	return m.AppendAt(time.Now(), from, to, content)
+}
+
+func (m *ChatMessages) AppendAt(timestamp time.Time, from string, to string, content string) *ChatMessage {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	m.seq += 1
-	m.messages = append(m.messages, &ChatMessage{
-		Timestamp: time.Now(),
+	message := &ChatMessage{
+		Timestamp: timestamp,
 		Seq:       m.seq,
 		From:      from,
 		To:        to,
 		Content:   content,
+	}
+	m.messages = append(m.messages, message)
+	return message
+}
+
+// put 复制一份消息并分配序号
+func (m *ChatMessages) put(message *ChatMessage) *ChatMessage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.seq += 1
+	msg := *message
+	msg.Seq = m.seq
+	m.messages = append(m.messages, &msg)
+	return &msg
+}
+
+// at 返回序号不超过 seq 的最后一条消息
+func (m *ChatMessages) at(seq int64) *ChatMessage {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	idx := sort.Search(len(m.messages), func(i int) bool {
+		return m.messages[i].Seq > seq
 	})
+	if idx == 0 {
+		return nil
+	}
+	return m.messages[idx-1]
+}
+
+// get 返回序号为 seq 的消息，没有时返回 nil
+func (m *ChatMessages) get(seq int64) *ChatMessage {
+	if message := m.at(seq); message != nil && message.Seq == seq {
+		return message
+	}
+	return nil
+}
+
+// update 修改序号为 seq 的消息。读者可能还持有旧消息，所以复制一份修改后替换，返回新的消息
+func (m *ChatMessages) update(seq int64, update func(message *ChatMessage)) *ChatMessage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	idx := sort.Search(len(m.messages), func(i int) bool {
+		return m.messages[i].Seq >= seq
+	})
+	if idx == len(m.messages) || m.messages[idx].Seq != seq {
+		return nil
+	}
+	msg := *m.messages[idx]
+	update(&msg)
+	m.messages[idx] = &msg
+	return &msg
+}
+
+// find 从后往前查找满足条件的消息
+func (m *ChatMessages) find(match func(message *ChatMessage) bool) *ChatMessage {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if match(m.messages[i]) {
+			return m.messages[i]
+		}
+	}
+	return nil
 }
 
 func (m *ChatMessages) Lookup(seq int64) []*ChatMessage {
-	var messages []*ChatMessage
 	m.mutex.RLock()
-	for _, message := range m.messages {
-		if message.Seq < seq {
-			continue
+	defer m.mutex.RUnlock()
+
+	// 消息按序号排序，二分查找起点
+	idx := sort.Search(len(m.messages), func(i int) bool {
+		return m.messages[i].Seq >= seq
+	})
+	if idx == len(m.messages) {
+		return nil
+	}
+	return append([]*ChatMessage(nil), m.messages[idx:]...)
+}
+
+// between 返回序号在 (after, before) 之间的消息，before 为 0 时不限制上界，按序号升序
+// 超过 limit 条时，latest 为 true 取最后 limit 条，否则取前 limit 条。limit <= 0 时不限制
+func (m *ChatMessages) between(after int64, before int64, limit int, latest bool) []*ChatMessage {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	lo := sort.Search(len(m.messages), func(i int) bool {
+		return m.messages[i].Seq > after
+	})
+	hi := len(m.messages)
+	if before > 0 {
+		hi = sort.Search(len(m.messages), func(i int) bool {
+			return m.messages[i].Seq >= before
+		})
+	}
+	if lo >= hi {
+		return nil
+	}
+	if limit > 0 && hi-lo > limit {
+		if latest {
+			lo = hi - limit
+		} else {
+			hi = lo + limit
 		}
-		messages = append(messages, message)
 	}
-	m.mutex.RUnlock()
-	return messages
+	// update 会替换数组中的元素，复制一份返回
+	return append([]*ChatMessage(nil), m.messages[lo:hi]...)
 }
 
-func (s *LocalChatStorage) PutMessage(from string, to string, content string) error {
-	s.putOneMessage(from, from, to, content)
-	s.putOneMessage(to, from, to, content)
-	return nil
+func (s *LocalChatStorage) PutMessage(msgID string, from string, to string, content string, replyTo int64, ttl time.Duration) (*ChatMessage, *ChatMessage, error) {
+	now := time.Now()
+	messages, err := s.write(&walRecord{
+		Op: walOpPutMessage,
+		Message: &ChatMessage{
+			Timestamp: now,
+			MsgID:     msgID,
+			From:      from,
+			To:        to,
+			Content:   content,
+			ReplyTo:   replyTo,
+			ExpiresAt: expiresAt(now, ttl),
+		},
+	})
+	if err != nil && err != ErrDuplicateMessage {
+		return nil, nil, err
+	}
+	return messages[0], messages[1], err
 }
 
-func (s *LocalChatStorage) GetMessageByUser(from string, seq int64) []*ChatMessage {
+func (s *LocalChatStorage) GetMessageByUser(from string, seq int64) ([]*ChatMessage, error) {
+	var res []*ChatMessage
+	for _, message := range s.userMessages(from, seq) {
+		if !message.Hidden {
+			res = append(res, message)
+		}
+	}
+	return res, nil
+}
+
+func (s *LocalChatStorage) GetContacts(username string) ([]string, error) {
 	s.mutex.RLock()
-	messages, ok := s.userMessagesMap[from]
+	contacts := map[string]struct{}{}
+	for contact := range s.userContacts[username] {
+		contacts[contact] = struct{}{}
+	}
+	var rooms []*localRoom
+	for name := range s.userRooms[username] {
+		rooms = append(rooms, s.rooms[name])
+	}
 	s.mutex.RUnlock()
-	if !ok {
-		return nil
+
+	for _, room := range rooms {
+		for _, member := range room.memberList() {
+			contacts[member] = struct{}{}
+		}
+	}
+	delete(contacts, username)
+
+	res := make([]string, 0, len(contacts))
+	for contact := range contacts {
+		res = append(res, contact)
 	}
+	sort.Strings(res)
+	return res, nil
+}
 
-	return messages.Lookup(seq)
+func (s *LocalChatStorage) Close() error {
+	if s.done != nil {
+		close(s.done)
+		s.wg.Wait()
+	}
+	if s.wal == nil {
+		return nil
+	}
+	if err := s.Snapshot(); err != nil {
+		_ = s.wal.Close()
+		return errors.WithMessage(err, "Snapshot failed")
+	}
+	return s.wal.Close()
 }
 
-func (s *LocalChatStorage) putOneMessage(key string, from string, to string, content string) {
+// Snapshot 把当前全部消息写入快照并清空日志
+func (s *LocalChatStorage) Snapshot() error {
+	if s.wal == nil {
+		return nil
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
 	s.mutex.RLock()
-	messages, ok := s.userMessagesMap[key]
+	snapshot := &localSnapshot{
+		Conversations: map[string]*localSnapshotConversation{},
+		Indexes:       map[string]*localSnapshotIndex{},
+		Rooms:         map[string]*localSnapshotRoom{},
+	}
+	for id, conversation := range s.conversations {
+		// 房间的会话和成员一起保存
+		if strings.HasPrefix(id, roomConversationPrefix) {
+			continue
+		}
+		snapshot.Conversations[id] = conversation.snapshot()
+	}
+	for username, index := range s.indexes {
+		snapshot.Indexes[username] = index.snapshot()
+	}
+	for name, room := range s.rooms {
+		snapshot.Rooms[name] = room.snapshot()
+	}
+	snapshot.Cursors = s.snapshotCursors()
+	snapshot.Delivered = map[string]int64{}
+	for username, seq := range s.delivered {
+		snapshot.Delivered[username] = seq
+	}
 	s.mutex.RUnlock()
-	if !ok {
+
+	return s.wal.Checkpoint(snapshot)
+}
+
+func (s *LocalChatStorage) snapshotLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.options.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			// 快照失败不影响日志，下次重试即可
+			_ = s.Snapshot()
+		}
+	}
+}
+
+// write 先校验再写日志，最后修改内存，未开启持久化时直接修改内存
+func (s *LocalChatStorage) write(record *walRecord) ([]*ChatMessage, error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if messages, ok := s.duplicate(record); ok {
+		return messages, ErrDuplicateMessage
+	}
+	if err := s.check(record); err != nil {
+		return nil, err
+	}
+	if s.wal != nil {
+		if err := s.wal.Append(record); err != nil {
+			return nil, errors.WithMessage(err, "wal.Append failed")
+		}
+	}
+	return s.apply(record), nil
+}
+
+// apply 修改内存中的数据，返回受影响的消息
+func (s *LocalChatStorage) apply(record *walRecord) []*ChatMessage {
+	switch record.Op {
+	case walOpPutMessage:
+		return s.putMessage(record.Message)
+	case walOpCreateRoom:
+		s.createRoom(record.Room)
+		s.joinRoom(record.Room, record.Username)
+	case walOpJoinRoom:
+		s.joinRoom(record.Room, record.Username)
+	case walOpLeaveRoom:
+		s.leaveRoom(record.Room, record.Username)
+	case walOpReadCursor:
 		s.mutex.Lock()
-		messages = &ChatMessages{}
-		s.userMessagesMap[key] = messages
+		s.setCursor(record.Username, record.Cursor)
 		s.mutex.Unlock()
+	case walOpPutRoomMessage:
+		message := s.putRoomMessage(record.Message)
+		if message != nil {
+			s.mutex.Lock()
+			s.indexMessage(message)
+			s.mutex.Unlock()
+		}
+		return []*ChatMessage{message}
+	case walOpEditMessage, walOpDeleteMessage, walOpReact:
+		return s.updateMessage(record)
+	case walOpExpire:
+		s.expire(record.Expired)
+	case walOpDeliver:
+		return s.deliver(record.Username, record.Delivered)
+	}
+	return nil
+}
+
+// putMessage 私聊消息写入会话，再加入双方的索引，返回双方看到的消息
+func (s *LocalChatStorage) putMessage(message *ChatMessage) []*ChatMessage {
+	id := conversationID(message)
+	conversation := s.directConversation(id)
+	msg := *message
+	msg.ReplyTo = 0
+	// 日志中的 ReplyTo 是发送方信箱的序号，换成会话中的序号
+	if message.ReplyTo != 0 {
+		if parent, err := s.replyParent(message); err == nil {
+			msg.ReplyTo = parent.Seq
+		}
+	}
+	stored := conversation.messages.put(&msg)
+	s.index(message.From).add(id, stored.Seq)
+	if message.To != message.From {
+		s.index(message.To).add(id, stored.Seq)
+	}
+	s.mutex.Lock()
+	s.addContact(message.From, message.To)
+	s.indexMessage(stored)
+	s.mutex.Unlock()
+
+	fromMessage := s.view(message.From, conversation, stored)
+	return []*ChatMessage{fromMessage, s.view(message.To, conversation, stored)}
+}
+
+// duplicate 查找 msgID 相同的已保存消息，私聊返回双方看到的两份，调用方持有 writeMutex
+func (s *LocalChatStorage) duplicate(record *walRecord) ([]*ChatMessage, bool) {
+	if (record.Op != walOpPutMessage && record.Op != walOpPutRoomMessage) || record.Message.MsgID == "" {
+		return nil, false
+	}
+	s.mutex.RLock()
+	ref, ok := s.msgIDs[msgIDKey(record.Message)]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	conversation, ok := s.conversation(ref.conversation)
+	if !ok {
+		return nil, false
+	}
+	message := conversation.messages.get(ref.seq)
+	if record.Op == walOpPutRoomMessage {
+		return []*ChatMessage{message}, true
+	}
+	return []*ChatMessage{s.view(message.From, conversation, message), s.view(message.To, conversation, message)}, true
+}
+
+func msgIDKey(message *ChatMessage) string {
+	return message.From + "\x00" + message.Room + "\x00" + message.MsgID
+}
+
+// indexMessage message 是会话中保存的消息，调用方持有 s.mutex
+func (s *LocalChatStorage) indexMessage(message *ChatMessage) {
+	ref := &localMessageRef{conversation: conversationID(message), seq: message.Seq}
+	s.indexTerms(ref, message.Content)
+	if message.ExpiresAt != nil {
+		s.ttls[*ref] = *message.ExpiresAt
+	}
+	if message.MsgID == "" {
+		return
+	}
+	s.msgIDs[msgIDKey(message)] = ref
+}
+
+// check 在写日志之前校验操作是否合法，调用方持有 writeMutex
+func (s *LocalChatStorage) check(record *walRecord) error {
+	switch record.Op {
+	case walOpPutMessage:
+		if record.Message.ReplyTo != 0 {
+			if _, err := s.replyParent(record.Message); err != nil {
+				return err
+			}
+		}
+	case walOpCreateRoom:
+		if _, ok := s.room(record.Room); ok {
+			return ErrRoomExists
+		}
+	case walOpJoinRoom:
+		if _, ok := s.room(record.Room); !ok {
+			return ErrRoomNotFound
+		}
+	case walOpLeaveRoom, walOpPutRoomMessage:
+		room, ok := s.room(record.Room)
+		if !ok {
+			return ErrRoomNotFound
+		}
+		if !room.isMember(record.Username) {
+			return ErrNotRoomMember
+		}
+		if record.Op == walOpPutRoomMessage && record.Message.ReplyTo != 0 {
+			if _, err := s.replyParent(record.Message); err != nil {
+				return err
+			}
+		}
+	case walOpReadCursor:
+		if record.Room != "" {
+			room, ok := s.room(record.Room)
+			if !ok {
+				return ErrRoomNotFound
+			}
+			if !room.isMember(record.Username) {
+				return ErrNotRoomMember
+			}
+		}
+		if cursor := s.cursor(record.Username, record.Cursor.Peer, record.Cursor.Room); cursor != nil && cursor.Seq >= record.Cursor.Seq {
+			return errCursorNotAdvanced
+		}
+	case walOpDeliver:
+		if s.deliveredSeq(record.Username) >= record.Delivered {
+			return errCursorNotAdvanced
+		}
+	case walOpEditMessage, walOpDeleteMessage:
+		target, err := s.target(record)
+		if err != nil {
+			return err
+		}
+		if (record.Op == walOpEditMessage || record.ForEveryone) && target.message.From != record.Username {
+			return ErrNotMessageSender
+		}
+	case walOpReact:
+		target, err := s.target(record)
+		if err != nil {
+			return err
+		}
+		if hasReaction(target.message, record.Username, record.Emoji) != record.Remove {
+			return errReactionNotChanged
+		}
+	}
+	return nil
+}
+
+func (s *LocalChatStorage) restore(snapshot *localSnapshot) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, snapshotConversation := range snapshot.Conversations {
+		s.conversations[id] = restoreLocalConversation(snapshotConversation)
+	}
+	for username, snapshotIndex := range snapshot.Indexes {
+		index := newLocalUserIndex()
+		for _, entry := range snapshotIndex.Entries {
+			index.restore(entry)
+		}
+		index.seq = snapshotIndex.Seq
+		s.indexes[username] = index
+	}
+	for _, conversation := range s.conversations {
+		for _, message := range conversation.messages.messages {
+			s.addContact(message.From, message.To)
+			s.indexMessage(message)
+		}
+	}
+	for name, snapshotRoom := range snapshot.Rooms {
+		conversation := restoreLocalConversation(&snapshotRoom.localSnapshotConversation)
+		room := newLocalRoom(conversation)
+		for _, message := range conversation.messages.messages {
+			s.indexMessage(message)
+		}
+		for _, username := range snapshotRoom.Members {
+			room.members[username] = struct{}{}
+			s.addUserRoom(username, name)
+		}
+		s.rooms[name] = room
+		s.conversations[RoomConversation(name)] = conversation
+	}
+	for username, cursors := range snapshot.Cursors {
+		for _, cursor := range cursors {
+			s.setCursor(username, cursor)
+		}
+	}
+	for username, seq := range snapshot.Delivered {
+		s.delivered[username] = seq
+	}
+}
+
+// addContact 调用方持有 s.mutex
+func (s *LocalChatStorage) addContact(from string, to string) {
+	for _, pair := range [][2]string{{from, to}, {to, from}} {
+		if _, ok := s.userContacts[pair[0]]; !ok {
+			s.userContacts[pair[0]] = map[string]struct{}{}
+		}
+		s.userContacts[pair[0]][pair[1]] = struct{}{}
 	}
-	messages.Append(from, to, content)
 }
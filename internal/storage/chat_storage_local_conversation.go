@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// localConversation 一个会话中的消息，私聊和房间都只保存一份，序号属于会话
+type localConversation struct {
+	messages *ChatMessages
+	// 用户名 -> 自己删除的消息序号
+	hidden map[string]map[int64]struct{}
+}
+
+func newLocalConversation() *localConversation {
+	return &localConversation{
+		messages: &ChatMessages{},
+		hidden:   map[string]map[int64]struct{}{},
+	}
+}
+
+func (c *localConversation) isHidden(username string, seq int64) bool {
+	c.messages.mutex.RLock()
+	defer c.messages.mutex.RUnlock()
+	_, ok := c.hidden[username][seq]
+	return ok
+}
+
+func (c *localConversation) hide(username string, seq int64) {
+	c.messages.mutex.Lock()
+	defer c.messages.mutex.Unlock()
+	if _, ok := c.hidden[username]; !ok {
+		c.hidden[username] = map[int64]struct{}{}
+	}
+	c.hidden[username][seq] = struct{}{}
+}
+
+func (c *localConversation) lastSeq() int64 {
+	c.messages.mutex.RLock()
+	defer c.messages.mutex.RUnlock()
+	return c.messages.seq
+}
+
+func (c *localConversation) snapshot() *localSnapshotConversation {
+	c.messages.mutex.RLock()
+	defer c.messages.mutex.RUnlock()
+	hidden := map[string][]int64{}
+	for username, seqs := range c.hidden {
+		for seq := range seqs {
+			hidden[username] = append(hidden[username], seq)
+		}
+	}
+	return &localSnapshotConversation{
+		Seq:      c.messages.seq,
+		Messages: append([]*ChatMessage(nil), c.messages.messages...),
+		Hidden:   hidden,
+	}
+}
+
+func restoreLocalConversation(snapshot *localSnapshotConversation) *localConversation {
+	c := newLocalConversation()
+	c.messages.seq = snapshot.Seq
+	c.messages.messages = snapshot.Messages
+	for username, seqs := range snapshot.Hidden {
+		for _, seq := range seqs {
+			c.hide(username, seq)
+		}
+	}
+	return c
+}
+
+// localUserIndex 用户的私聊消息索引，按信箱的序号排序，指向会话中的消息
+type localUserIndex struct {
+	seq     int64
+	entries []*localIndexEntry
+	// 会话 ID -> 会话中的序号 -> 信箱的序号
+	seqs  map[string]map[int64]int64
+	mutex sync.RWMutex
+}
+
+type localIndexEntry struct {
+	Seq             int64
+	Conversation    string
+	ConversationSeq int64
+}
+
+func newLocalUserIndex() *localUserIndex {
+	return &localUserIndex{seqs: map[string]map[int64]int64{}}
+}
+
+// add 分配下一个信箱序号
+func (x *localUserIndex) add(conversation string, conversationSeq int64) int64 {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.seq += 1
+	x.restore(&localIndexEntry{Seq: x.seq, Conversation: conversation, ConversationSeq: conversationSeq})
+	return x.seq
+}
+
+// restore 按序号顺序加入已有的索引项，调用方持有 x.mutex 或者还没有其他读者
+func (x *localUserIndex) restore(entry *localIndexEntry) {
+	x.entries = append(x.entries, entry)
+	if _, ok := x.seqs[entry.Conversation]; !ok {
+		x.seqs[entry.Conversation] = map[int64]int64{}
+	}
+	x.seqs[entry.Conversation][entry.ConversationSeq] = entry.Seq
+	if entry.Seq > x.seq {
+		x.seq = entry.Seq
+	}
+}
+
+// at 返回序号不超过 seq 的最后一项
+func (x *localUserIndex) at(seq int64) *localIndexEntry {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	idx := sort.Search(len(x.entries), func(i int) bool {
+		return x.entries[i].Seq > seq
+	})
+	if idx == 0 {
+		return nil
+	}
+	return x.entries[idx-1]
+}
+
+// lastIn 返回会话 conversation 中序号不超过 seq 的最后一项
+func (x *localUserIndex) lastIn(conversation string, seq int64) *localIndexEntry {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	idx := sort.Search(len(x.entries), func(i int) bool {
+		return x.entries[i].Seq > seq
+	})
+	for i := idx - 1; i >= 0; i-- {
+		if x.entries[i].Conversation == conversation {
+			return x.entries[i]
+		}
+	}
+	return nil
+}
+
+func (x *localUserIndex) get(seq int64) *localIndexEntry {
+	if entry := x.at(seq); entry != nil && entry.Seq == seq {
+		return entry
+	}
+	return nil
+}
+
+// lookup 返回序号不小于 seq 的所有项
+func (x *localUserIndex) lookup(seq int64) []*localIndexEntry {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	idx := sort.Search(len(x.entries), func(i int) bool {
+		return x.entries[i].Seq >= seq
+	})
+	return append([]*localIndexEntry(nil), x.entries[idx:]...)
+}
+
+// lastSeq 最后分配的信箱序号
+func (x *localUserIndex) lastSeq() int64 {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	return x.seq
+}
+
+// seqOf 会话中的消息在信箱中的序号，没有时为 0
+func (x *localUserIndex) seqOf(conversation string, conversationSeq int64) int64 {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	return x.seqs[conversation][conversationSeq]
+}
+
+func (x *localUserIndex) snapshot() *localSnapshotIndex {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	return &localSnapshotIndex{
+		Seq:     x.seq,
+		Entries: append([]*localIndexEntry(nil), x.entries...),
+	}
+}
+
+// localMessageRef 消息所在的会话和会话中的序号
+type localMessageRef struct {
+	conversation string
+	seq          int64
+}
+
+// conversationID 消息所在的会话
+func conversationID(message *ChatMessage) string {
+	if message.Room != "" {
+		return RoomConversation(message.Room)
+	}
+	return DirectConversation(message.From, message.To)
+}
+
+func (s *LocalChatStorage) GetConversations(username string) ([]*Conversation, error) {
+	s.mutex.RLock()
+	var res []*Conversation
+	for peer := range s.userContacts[username] {
+		id := DirectConversation(username, peer)
+		if conversation, ok := s.conversations[id]; ok {
+			res = append(res, &Conversation{ID: id, Peer: peer, Seq: conversation.lastSeq()})
+		}
+	}
+	for name := range s.userRooms[username] {
+		if room, ok := s.rooms[name]; ok {
+			res = append(res, &Conversation{ID: RoomConversation(name), Room: name, Seq: room.lastSeq()})
+		}
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
+
+func (s *LocalChatStorage) conversation(id string) (*localConversation, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	conversation, ok := s.conversations[id]
+	return conversation, ok
+}
+
+// directConversation 私聊会话不存在时创建
+func (s *LocalChatStorage) directConversation(id string) *localConversation {
+	if conversation, ok := s.conversation(id); ok {
+		return conversation
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	conversation, ok := s.conversations[id]
+	if !ok {
+		conversation = newLocalConversation()
+		s.conversations[id] = conversation
+	}
+	return conversation
+}
+
+func (s *LocalChatStorage) userIndex(username string) (*localUserIndex, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	index, ok := s.indexes[username]
+	return index, ok
+}
+
+// index 用户的索引不存在时创建
+func (s *LocalChatStorage) index(username string) *localUserIndex {
+	if index, ok := s.userIndex(username); ok {
+		return index
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	index, ok := s.indexes[username]
+	if !ok {
+		index = newLocalUserIndex()
+		s.indexes[username] = index
+	}
+	return index
+}
+
+// userMessage 按信箱的序号找到会话和会话中的消息，找不到时返回 nil
+func (s *LocalChatStorage) userMessage(username string, seq int64) (*localConversation, *ChatMessage) {
+	index, ok := s.userIndex(username)
+	if !ok {
+		return nil, nil
+	}
+	entry := index.get(seq)
+	if entry == nil {
+		return nil, nil
+	}
+	conversation, ok := s.conversation(entry.Conversation)
+	if !ok {
+		return nil, nil
+	}
+	message := conversation.messages.get(entry.ConversationSeq)
+	if message == nil {
+		return nil, nil
+	}
+	return conversation, message
+}
+
+// userMessages 信箱序号不小于 seq 的消息，包括自己删除的
+func (s *LocalChatStorage) userMessages(username string, seq int64) []*ChatMessage {
+	index, ok := s.userIndex(username)
+	if !ok {
+		return nil
+	}
+	var res []*ChatMessage
+	for _, entry := range index.lookup(seq) {
+		conversation, ok := s.conversation(entry.Conversation)
+		if !ok {
+			continue
+		}
+		if message := conversation.messages.get(entry.ConversationSeq); message != nil {
+			res = append(res, s.viewAt(username, index, conversation, message, entry.Seq))
+		}
+	}
+	return res
+}
+
+// view 会话中的私聊消息在 username 信箱中的样子，Seq 和 ReplyTo 换成信箱的序号，Parent 同样处理
+func (s *LocalChatStorage) view(username string, conversation *localConversation, message *ChatMessage) *ChatMessage {
+	if message == nil {
+		return nil
+	}
+	index := s.index(username)
+	return s.viewAt(username, index, conversation, message, index.seqOf(conversationID(message), message.Seq))
+}
+
+func (s *LocalChatStorage) viewAt(username string, index *localUserIndex, conversation *localConversation, message *ChatMessage, seq int64) *ChatMessage {
+	msg := *message
+	msg.Seq = seq
+	msg.ConversationSeq = message.Seq
+	msg.Hidden = conversation.isHidden(username, message.Seq)
+	if message.ReplyTo != 0 {
+		msg.ReplyTo = index.seqOf(conversationID(message), message.ReplyTo)
+		if parent := conversation.messages.get(message.ReplyTo); parent != nil {
+			p := *parent
+			p.Seq = msg.ReplyTo
+			p.ConversationSeq = parent.Seq
+			p.Hidden = conversation.isHidden(username, parent.Seq)
+			p.ReplyTo = index.seqOf(conversationID(parent), parent.ReplyTo)
+			msg.Parent = &p
+		}
+	}
+	return &msg
+}
+
+// views 返回自己和对方看到的消息，自己发给自己时对方的为 nil
+func (s *LocalChatStorage) views(username string, conversation *localConversation, message *ChatMessage) []*ChatMessage {
+	peer := peerOwner(username, message)
+	if peer == username {
+		return []*ChatMessage{s.view(username, conversation, message), nil}
+	}
+	return []*ChatMessage{s.view(username, conversation, message), s.view(peer, conversation, message)}
+}
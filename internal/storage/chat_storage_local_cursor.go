@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// errCursorNotAdvanced 已读位置或者投递位置没有前进，不需要写日志
+var errCursorNotAdvanced = errors.New("cursor not advanced")
+
+func cursorKey(peer string, room string) string {
+	if room != "" {
+		return "room:" + room
+	}
+	return "user:" + peer
+}
+
+func (s *LocalChatStorage) UpdateReadCursor(username string, peer string, room string, seq int64) (*ReadCursor, int64, error) {
+	// 序号不超过 seq 的最后一条消息
+	var message *ChatMessage
+	var messageSeq int64
+	if room != "" {
+		r, ok := s.room(room)
+		if !ok {
+			return nil, 0, ErrRoomNotFound
+		}
+		if !r.isMember(username) {
+			return nil, 0, ErrNotRoomMember
+		}
+		if message = r.messages.at(seq); message != nil {
+			messageSeq = message.Seq
+		}
+	} else if index, ok := s.userIndex(username); ok {
+		if entry := index.at(seq); entry != nil {
+			_, message = s.userMessage(username, entry.Seq)
+			messageSeq = entry.Seq
+		}
+	}
+
+	var prevSeq int64
+	if cursor := s.cursor(username, peer, room); cursor != nil {
+		prevSeq = cursor.Seq
+	}
+	if message == nil {
+		return &ReadCursor{Peer: peer, Room: room, Seq: prevSeq}, prevSeq, nil
+	}
+
+	cursor := &ReadCursor{Peer: peer, Room: room, Seq: messageSeq, Timestamp: message.Timestamp}
+	_, err := s.write(&walRecord{Op: walOpReadCursor, Room: room, Username: username, Cursor: cursor})
+	if err == errCursorNotAdvanced {
+		cursor = s.cursor(username, peer, room)
+		return cursor, cursor.Seq, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if peer != "" {
+		// cursor 已经保存，返回一份副本
+		res := *cursor
+		res.PeerSeq = s.peerSeq(username, peer, cursor.Seq)
+		return &res, prevSeq, nil
+	}
+	return cursor, prevSeq, nil
+}
+
+// peerSeq username 信箱中序号不超过 seq 的最后一条和 peer 的消息，在 peer 信箱中的序号，没有时为 0
+func (s *LocalChatStorage) peerSeq(username string, peer string, seq int64) int64 {
+	index, ok := s.userIndex(username)
+	if !ok {
+		return 0
+	}
+	peerIndex, ok := s.userIndex(peer)
+	if !ok {
+		return 0
+	}
+	entry := index.lastIn(DirectConversation(username, peer), seq)
+	if entry == nil {
+		return 0
+	}
+	return peerIndex.seqOf(entry.Conversation, entry.ConversationSeq)
+}
+
+func (s *LocalChatStorage) GetUnreadCounts(username string) ([]*UnreadCount, error) {
+	var counts []*UnreadCount
+
+	// 私聊，一次遍历信箱，按发送方分别和各自的已读位置比较
+	peers := map[string]int64{}
+	for _, message := range s.userMessages(username, 0) {
+		if message.From == username || message.DeletedAt != nil || message.Hidden {
+			continue
+		}
+		if cursor := s.cursor(username, message.From, ""); cursor != nil && message.Seq <= cursor.Seq {
+			continue
+		}
+		peers[message.From]++
+	}
+	for peer, count := range peers {
+		counts = append(counts, &UnreadCount{Peer: peer, Count: count})
+	}
+
+	rooms, err := s.GetRoomsByUser(username)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range rooms {
+		var seq int64
+		if cursor := s.cursor(username, "", name); cursor != nil {
+			seq = cursor.Seq
+		}
+		messages, err := s.GetMessageByRoom(name, username, seq+1)
+		if err != nil {
+			return nil, err
+		}
+		var count int64
+		for _, message := range messages {
+			if message.From != username && message.DeletedAt == nil {
+				count++
+			}
+		}
+		if count > 0 {
+			counts = append(counts, &UnreadCount{Room: name, Count: count})
+		}
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		return cursorKey(counts[i].Peer, counts[i].Room) < cursorKey(counts[j].Peer, counts[j].Room)
+	})
+	return counts, nil
+}
+
+func (s *LocalChatStorage) cursor(username string, peer string, room string) *ReadCursor {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cursors[username][cursorKey(peer, room)]
+}
+
+// setCursor 调用方持有 s.mutex
+func (s *LocalChatStorage) setCursor(username string, cursor *ReadCursor) {
+	if _, ok := s.cursors[username]; !ok {
+		s.cursors[username] = map[string]*ReadCursor{}
+	}
+	s.cursors[username][cursorKey(cursor.Peer, cursor.Room)] = cursor
+}
+
+// snapshotCursors 调用方持有 s.mutex
+func (s *LocalChatStorage) snapshotCursors() map[string][]*ReadCursor {
+	res := map[string][]*ReadCursor{}
+	for username, cursors := range s.cursors {
+		for _, cursor := range cursors {
+			res[username] = append(res[username], cursor)
+		}
+	}
+	return res
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestLocalChatStorageUpdateReadCursorPeerSeq(t *testing.T) {
+	s, err := NewLocalChatStorageWithOptions(&LocalChatStorageOptions{})
+	if err != nil {
+		t.Fatalf("NewLocalChatStorageWithOptions failed: %v", err)
+	}
+	defer s.Close()
+
+	// bob 的信箱：1 carol->bob，2 alice->bob，3 carol->bob，4 alice->bob
+	// alice 的信箱：1 alice->carol，2 alice->bob，3 alice->bob
+	for _, m := range []struct {
+		from string
+		to   string
+	}{
+		{"carol", "bob"},
+		{"alice", "carol"},
+		{"alice", "bob"},
+		{"carol", "bob"},
+		{"alice", "bob"},
+	} {
+		if _, _, err := s.PutMessage("", m.from, m.to, "hello", 0, 0); err != nil {
+			t.Fatalf("PutMessage failed: %v", err)
+		}
+	}
+
+	for _, c := range []struct {
+		name    string
+		seq     int64
+		wantSeq int64
+		// alice 信箱中对应的序号
+		wantPeerSeq int64
+	}{
+		{"first from alice", 2, 2, 2},
+		// 3 是 carol 的消息，和 alice 的会话只读到 bob 信箱中的 2，也就是 alice 信箱中的 2
+		{"message from other peer", 3, 3, 2},
+		{"beyond last", 100, 4, 3},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cursor, prevSeq, err := s.UpdateReadCursor("bob", "alice", "", c.seq)
+			if err != nil {
+				t.Fatalf("UpdateReadCursor failed: %v", err)
+			}
+			if cursor.Seq == prevSeq {
+				t.Fatalf("cursor not advanced from %d", prevSeq)
+			}
+			if cursor.Seq != c.wantSeq || cursor.PeerSeq != c.wantPeerSeq {
+				t.Fatalf("cursor = (%d, %d), want (%d, %d)", cursor.Seq, cursor.PeerSeq, c.wantSeq, c.wantPeerSeq)
+			}
+		})
+	}
+}
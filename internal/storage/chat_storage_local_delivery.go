@@ -0,0 +1,64 @@
+package storage
+
+func (s *LocalChatStorage) GetUndelivered(username string) ([]*ChatMessage, error) {
+	return s.GetMessageByUser(username, s.deliveredSeq(username)+1)
+}
+
+func (s *LocalChatStorage) MarkDelivered(username string, seq int64) ([]*ChatMessage, error) {
+	index, ok := s.userIndex(username)
+	if !ok {
+		return nil, nil
+	}
+	if last := index.lastSeq(); seq > last {
+		seq = last
+	}
+
+	messages, err := s.write(&walRecord{Op: walOpDeliver, Username: username, Delivered: seq})
+	if err == errCursorNotAdvanced {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *LocalChatStorage) deliveredSeq(username string) int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.delivered[username]
+}
+
+// deliver 投递位置前进到 seq，返回新投递的别人发来的消息，消息是发送方看到的
+func (s *LocalChatStorage) deliver(username string, seq int64) []*ChatMessage {
+	s.mutex.Lock()
+	prev := s.delivered[username]
+	if seq > prev {
+		s.delivered[username] = seq
+	}
+	s.mutex.Unlock()
+	if seq <= prev {
+		return nil
+	}
+
+	index, ok := s.userIndex(username)
+	if !ok {
+		return nil
+	}
+	var res []*ChatMessage
+	for _, entry := range index.lookup(prev + 1) {
+		if entry.Seq > seq {
+			break
+		}
+		conversation, ok := s.conversation(entry.Conversation)
+		if !ok {
+			continue
+		}
+		message := conversation.messages.get(entry.ConversationSeq)
+		if message == nil || message.From == username {
+			continue
+		}
+		res = append(res, s.view(message.From, conversation, message))
+	}
+	return res
+}
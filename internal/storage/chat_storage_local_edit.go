@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"time"
+)
+
+func (s *LocalChatStorage) EditMessage(username string, seq int64, content string) (*ChatMessage, *ChatMessage, error) {
+	messages, err := s.write(&walRecord{
+		Op:       walOpEditMessage,
+		Username: username,
+		Message:  &ChatMessage{Seq: seq, Timestamp: time.Now(), Content: content},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return messages[0], messages[1], nil
+}
+
+func (s *LocalChatStorage) EditRoomMessage(room string, username string, seq int64, content string) (*ChatMessage, error) {
+	messages, err := s.write(&walRecord{
+		Op:       walOpEditMessage,
+		Room:     room,
+		Username: username,
+		Message:  &ChatMessage{Seq: seq, Timestamp: time.Now(), Content: content},
+	})
+	if err != nil {
+		return nil, err
+	}
+	r, _ := s.room(room)
+	return withParents(r.messages, []*ChatMessage{messages[0]})[0], nil
+}
+
+func (s *LocalChatStorage) DeleteMessage(username string, seq int64, forEveryone bool) (*ChatMessage, *ChatMessage, error) {
+	messages, err := s.write(&walRecord{
+		Op:          walOpDeleteMessage,
+		Username:    username,
+		Message:     &ChatMessage{Seq: seq, Timestamp: time.Now()},
+		ForEveryone: forEveryone,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return messages[0], messages[1], nil
+}
+
+func (s *LocalChatStorage) DeleteRoomMessage(room string, username string, seq int64, forEveryone bool) (*ChatMessage, error) {
+	messages, err := s.write(&walRecord{
+		Op:          walOpDeleteMessage,
+		Room:        room,
+		Username:    username,
+		Message:     &ChatMessage{Seq: seq, Timestamp: time.Now()},
+		ForEveryone: forEveryone,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages[0], nil
+}
+
+// localTarget 要修改的消息，room 只在房间消息时不为 nil
+type localTarget struct {
+	room         *localRoom
+	conversation *localConversation
+	message      *ChatMessage
+}
+
+// target 找到记录要修改的消息，私聊时记录中的序号属于 Username 的信箱
+func (s *LocalChatStorage) target(record *walRecord) (*localTarget, error) {
+	if record.Room != "" {
+		room, ok := s.room(record.Room)
+		if !ok {
+			return nil, ErrRoomNotFound
+		}
+		if !room.isMember(record.Username) {
+			return nil, ErrNotRoomMember
+		}
+		message := room.messages.get(record.Message.Seq)
+		if message == nil || message.DeletedAt != nil || room.isHidden(record.Username, message.Seq) {
+			return nil, ErrMessageNotFound
+		}
+		return &localTarget{room: room, conversation: room.localConversation, message: message}, nil
+	}
+
+	conversation, message := s.userMessage(record.Username, record.Message.Seq)
+	if message == nil || message.DeletedAt != nil || conversation.isHidden(record.Username, message.Seq) {
+		return nil, ErrMessageNotFound
+	}
+	return &localTarget{conversation: conversation, message: message}, nil
+}
+
+// updateMessage 编辑、删除消息或者修改表情回应，私聊返回 [自己看到的, 对方看到的]，房间返回一份
+func (s *LocalChatStorage) updateMessage(record *walRecord) []*ChatMessage {
+	target, err := s.target(record)
+	if err != nil {
+		return nil
+	}
+
+	timestamp := record.Message.Timestamp
+	var update func(message *ChatMessage)
+	switch {
+	case record.Op == walOpReact:
+		update = func(message *ChatMessage) {
+			reactMessage(message, record.Username, record.Emoji, record.Remove)
+		}
+	case record.Op == walOpEditMessage:
+		update = func(message *ChatMessage) {
+			editMessage(message, record.Message.Content, timestamp)
+		}
+	case record.ForEveryone:
+		update = func(message *ChatMessage) {
+			message.Content = ""
+			message.Revisions = nil
+			message.Reactions = nil
+			message.DeletedAt = &timestamp
+		}
+	default:
+		target.conversation.hide(record.Username, target.message.Seq)
+		if target.room != nil {
+			return []*ChatMessage{target.message}
+		}
+		return []*ChatMessage{s.view(record.Username, target.conversation, target.message), nil}
+	}
+
+	message := target.conversation.messages.update(target.message.Seq, update)
+	// 修改和删除都会替换当前内容，索引中旧内容的词要去掉，历史版本不参与搜索
+	if record.Op != walOpReact {
+		ref := localMessageRef{conversation: conversationID(message), seq: message.Seq}
+		s.mutex.Lock()
+		s.unindexTerms(ref, target.message.Content)
+		s.indexTerms(&ref, message.Content)
+		s.mutex.Unlock()
+	}
+	if target.room != nil {
+		return []*ChatMessage{message}
+	}
+	return s.views(record.Username, target.conversation, message)
+}
+
+// editMessage 当前内容保存为历史版本，历史版本复制一份，不影响旧消息的读者
+func editMessage(message *ChatMessage, content string, timestamp time.Time) {
+	written := message.Timestamp
+	if message.EditedAt != nil {
+		written = *message.EditedAt
+	}
+	message.Revisions = append(append([]*MessageRevision(nil), message.Revisions...), &MessageRevision{
+		Content:   message.Content,
+		Timestamp: written,
+	})
+	message.Content = content
+	message.EditedAt = &timestamp
+}
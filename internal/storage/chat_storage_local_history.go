@@ -0,0 +1,60 @@
+package storage
+
+func (s *LocalChatStorage) GetHistory(username string, peer string, room string, before int64, after int64, limit int) ([]*ChatMessage, error) {
+	if room != "" {
+		r, ok := s.room(room)
+		if !ok {
+			return nil, ErrRoomNotFound
+		}
+		if !r.isMember(username) {
+			return nil, ErrNotRoomMember
+		}
+		return withParents(r.messages, r.history(username, before, after, limit)), nil
+	}
+
+	conversation, ok := s.conversation(DirectConversation(username, peer))
+	if !ok {
+		return nil, nil
+	}
+	messages := conversation.history(username, before, after, limit)
+	for i, message := range messages {
+		messages[i] = s.view(username, conversation, message)
+	}
+	return messages, nil
+}
+
+// history 按会话中的序号分页，跳过 username 自己删除的消息。有删除的消息时一次取不满，继续往前或者往后取
+func (c *localConversation) history(username string, before int64, after int64, limit int) []*ChatMessage {
+	latest := before > 0 || after == 0
+	var res []*ChatMessage
+	for {
+		messages := c.messages.between(after, before, limit, latest)
+		var visible []*ChatMessage
+		for _, message := range messages {
+			if !c.isHidden(username, message.Seq) {
+				visible = append(visible, message)
+			}
+		}
+		if latest {
+			res = append(visible, res...)
+		} else {
+			res = append(res, visible...)
+		}
+		if limit <= 0 || len(messages) < limit || len(res) >= limit {
+			break
+		}
+		if latest {
+			before = messages[0].Seq
+		} else {
+			after = messages[len(messages)-1].Seq
+		}
+	}
+	if limit > 0 && len(res) > limit {
+		if latest {
+			res = res[len(res)-limit:]
+		} else {
+			res = res[:limit]
+		}
+	}
+	return res
+}
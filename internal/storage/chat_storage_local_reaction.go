@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"github.com/pkg/errors"
+)
+
+// errReactionNotChanged 已经是目标状态，不需要写日志
+var errReactionNotChanged = errors.New("reaction not changed")
+
+func (s *LocalChatStorage) ReactMessage(username string, seq int64, emoji string, remove bool) (*ChatMessage, *ChatMessage, error) {
+	record := &walRecord{
+		Op:       walOpReact,
+		Username: username,
+		Message:  &ChatMessage{Seq: seq},
+		Emoji:    emoji,
+		Remove:   remove,
+	}
+	messages, err := s.write(record)
+	if err == errReactionNotChanged {
+		target, err := s.target(record)
+		if err != nil {
+			return nil, nil, err
+		}
+		messages := s.views(username, target.conversation, target.message)
+		return messages[0], messages[1], nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return messages[0], messages[1], nil
+}
+
+func (s *LocalChatStorage) ReactRoomMessage(room string, username string, seq int64, emoji string, remove bool) (*ChatMessage, error) {
+	record := &walRecord{
+		Op:       walOpReact,
+		Room:     room,
+		Username: username,
+		Message:  &ChatMessage{Seq: seq},
+		Emoji:    emoji,
+		Remove:   remove,
+	}
+	messages, err := s.write(record)
+	if err == errReactionNotChanged {
+		target, err := s.target(record)
+		if err != nil {
+			return nil, err
+		}
+		return target.message, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return messages[0], nil
+}
+
+func hasReaction(message *ChatMessage, username string, emoji string) bool {
+	for _, reaction := range message.Reactions {
+		if reaction.Emoji != emoji {
+			continue
+		}
+		for _, user := range reaction.Users {
+			if user == username {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reactMessage 回应整体复制一份再修改，不影响旧消息的读者。没有人回应的表情去掉
+func reactMessage(message *ChatMessage, username string, emoji string, remove bool) {
+	var reactions []*Reaction
+	found := false
+	for _, reaction := range message.Reactions {
+		if reaction.Emoji != emoji {
+			reactions = append(reactions, reaction)
+			continue
+		}
+		found = true
+		users := make([]string, 0, len(reaction.Users)+1)
+		for _, user := range reaction.Users {
+			if user != username {
+				users = append(users, user)
+			}
+		}
+		if !remove {
+			users = append(users, username)
+		}
+		if len(users) != 0 {
+			reactions = append(reactions, &Reaction{Emoji: emoji, Users: users})
+		}
+	}
+	if !found && !remove {
+		reactions = append(reactions, &Reaction{Emoji: emoji, Users: []string{username}})
+	}
+	message.Reactions = reactions
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+func (s *LocalChatStorage) startJanitor() {
+	if s.options.Retention.Interval <= 0 {
+		s.options.Retention.Interval = 10 * time.Second
+	}
+	s.wg.Add(1)
+	go s.janitorLoop()
+}
+
+func (s *LocalChatStorage) janitorLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.options.Retention.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			// 失败了下次重试即可
+			_ = s.expireMessages(now)
+		}
+	}
+}
+
+// expireMessages 删除过期的消息。删除的消息写入日志，回放时不依赖当时的保留策略
+func (s *LocalChatStorage) expireMessages(now time.Time) error {
+	expired := s.expiredMessages(now)
+	if len(expired) == 0 {
+		return nil
+	}
+	_, err := s.write(&walRecord{Op: walOpExpire, Expired: expired})
+	return err
+}
+
+// expiredMessages 返回会话 ID -> 过期的消息序号
+func (s *LocalChatStorage) expiredMessages(now time.Time) map[string][]int64 {
+	s.mutex.RLock()
+	conversations := make(map[string]*localConversation, len(s.conversations))
+	for id, conversation := range s.conversations {
+		conversations[id] = conversation
+	}
+	seqs := map[string]map[int64]struct{}{}
+	add := func(id string, seq int64) {
+		if _, ok := seqs[id]; !ok {
+			seqs[id] = map[int64]struct{}{}
+		}
+		seqs[id][seq] = struct{}{}
+	}
+	for ref, expiresAt := range s.ttls {
+		if !expiresAt.After(now) {
+			add(ref.conversation, ref.seq)
+		}
+	}
+	s.mutex.RUnlock()
+
+	for id, conversation := range conversations {
+		room := ""
+		if strings.HasPrefix(id, roomConversationPrefix) {
+			room = strings.TrimPrefix(id, roomConversationPrefix)
+		}
+		for _, seq := range conversation.expired(s.options.Retention.policy(room), now) {
+			add(id, seq)
+		}
+	}
+
+	res := map[string][]int64{}
+	for id, set := range seqs {
+		for seq := range set {
+			res[id] = append(res[id], seq)
+		}
+		sort.Slice(res[id], func(i, j int) bool { return res[id][i] < res[id][j] })
+	}
+	return res
+}
+
+// expired 超过保留条数或者保留时间的消息序号。消息按序号排序，时间基本有序，从头找到第一条没有过期的为止
+func (c *localConversation) expired(policy *RetentionPolicy, now time.Time) []int64 {
+	c.messages.mutex.RLock()
+	defer c.messages.mutex.RUnlock()
+
+	n := 0
+	if policy.MaxCount > 0 && len(c.messages.messages) > policy.MaxCount {
+		n = len(c.messages.messages) - policy.MaxCount
+	}
+	if policy.MaxAge > 0 {
+		cutoff := now.Add(-policy.MaxAge)
+		for n < len(c.messages.messages) && c.messages.messages[n].Timestamp.Before(cutoff) {
+			n++
+		}
+	}
+	seqs := make([]int64, 0, n)
+	for _, message := range c.messages.messages[:n] {
+		seqs = append(seqs, message.Seq)
+	}
+	return seqs
+}
+
+// remove 删除消息和自己删除的记录，返回删除的消息
+func (c *localConversation) remove(seqs []int64) []*ChatMessage {
+	c.messages.mutex.Lock()
+	defer c.messages.mutex.Unlock()
+
+	set := map[int64]struct{}{}
+	for _, seq := range seqs {
+		set[seq] = struct{}{}
+	}
+	// 读者可能还持有旧的数组，不在原地修改
+	messages := make([]*ChatMessage, 0, len(c.messages.messages))
+	var removed []*ChatMessage
+	for _, message := range c.messages.messages {
+		if _, ok := set[message.Seq]; ok {
+			removed = append(removed, message)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	c.messages.messages = messages
+	for _, hidden := range c.hidden {
+		for seq := range set {
+			delete(hidden, seq)
+		}
+	}
+	return removed
+}
+
+// expire 删除消息以及索引中指向它们的项
+func (s *LocalChatStorage) expire(expired map[string][]int64) {
+	for id, seqs := range expired {
+		conversation, ok := s.conversation(id)
+		if !ok {
+			continue
+		}
+		removed := conversation.remove(seqs)
+		if len(removed) == 0 {
+			continue
+		}
+
+		s.mutex.Lock()
+		for _, message := range removed {
+			ref := localMessageRef{conversation: id, seq: message.Seq}
+			delete(s.ttls, ref)
+			if message.MsgID != "" {
+				if r, ok := s.msgIDs[msgIDKey(message)]; ok && *r == ref {
+					delete(s.msgIDs, msgIDKey(message))
+				}
+			}
+			s.unindexTerms(ref, message.Content)
+		}
+		s.mutex.Unlock()
+
+		if strings.HasPrefix(id, roomConversationPrefix) {
+			continue
+		}
+		var conversationSeqs []int64
+		for _, message := range removed {
+			conversationSeqs = append(conversationSeqs, message.Seq)
+		}
+		for _, username := range []string{removed[0].From, removed[0].To} {
+			if index, ok := s.userIndex(username); ok {
+				index.remove(id, conversationSeqs)
+			}
+		}
+	}
+}
+
+// remove 去掉会话中已经删除的消息对应的项
+func (x *localUserIndex) remove(conversation string, conversationSeqs []int64) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	set := map[int64]struct{}{}
+	for _, seq := range conversationSeqs {
+		set[seq] = struct{}{}
+		delete(x.seqs[conversation], seq)
+	}
+	if len(x.seqs[conversation]) == 0 {
+		delete(x.seqs, conversation)
+	}
+	entries := make([]*localIndexEntry, 0, len(x.entries))
+	for _, entry := range x.entries {
+		if _, ok := set[entry.ConversationSeq]; ok && entry.Conversation == conversation {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	x.entries = entries
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// localRoom 房间也是一个会话，成员由会话的锁保护
+type localRoom struct {
+	*localConversation
+	members map[string]struct{}
+}
+
+func newLocalRoom(conversation *localConversation) *localRoom {
+	return &localRoom{
+		localConversation: conversation,
+		members:           map[string]struct{}{},
+	}
+}
+
+func (r *localRoom) isMember(username string) bool {
+	r.messages.mutex.RLock()
+	defer r.messages.mutex.RUnlock()
+	_, ok := r.members[username]
+	return ok
+}
+
+func (r *localRoom) memberList() []string {
+	r.messages.mutex.RLock()
+	defer r.messages.mutex.RUnlock()
+	members := make([]string, 0, len(r.members))
+	for username := range r.members {
+		members = append(members, username)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func (r *localRoom) snapshot() *localSnapshotRoom {
+	return &localSnapshotRoom{
+		Members:                   r.memberList(),
+		localSnapshotConversation: *r.localConversation.snapshot(),
+	}
+}
+
+func (s *LocalChatStorage) CreateRoom(room string, owner string) error {
+	_, err := s.write(&walRecord{Op: walOpCreateRoom, Room: room, Username: owner})
+	return err
+}
+
+func (s *LocalChatStorage) JoinRoom(room string, username string) error {
+	_, err := s.write(&walRecord{Op: walOpJoinRoom, Room: room, Username: username})
+	return err
+}
+
+func (s *LocalChatStorage) LeaveRoom(room string, username string) error {
+	_, err := s.write(&walRecord{Op: walOpLeaveRoom, Room: room, Username: username})
+	return err
+}
+
+func (s *LocalChatStorage) GetRoomMembers(room string) ([]string, error) {
+	r, ok := s.room(room)
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	return r.memberList(), nil
+}
+
+func (s *LocalChatStorage) GetRoomsByUser(username string) ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	rooms := make([]string, 0, len(s.userRooms[username]))
+	for room := range s.userRooms[username] {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms, nil
+}
+
+func (s *LocalChatStorage) PutRoomMessage(msgID string, room string, from string, content string, replyTo int64, ttl time.Duration) (*ChatMessage, error) {
+	now := time.Now()
+	messages, err := s.write(&walRecord{
+		Op:       walOpPutRoomMessage,
+		Room:     room,
+		Username: from,
+		Message: &ChatMessage{
+			Timestamp: now,
+			MsgID:     msgID,
+			From:      from,
+			Room:      room,
+			Content:   content,
+			ReplyTo:   replyTo,
+			ExpiresAt: expiresAt(now, ttl),
+		},
+	})
+	if err != nil && err != ErrDuplicateMessage {
+		return nil, err
+	}
+	r, _ := s.room(room)
+	return withParents(r.messages, []*ChatMessage{messages[0]})[0], err
+}
+
+func (s *LocalChatStorage) GetMessageByRoom(room string, username string, seq int64) ([]*ChatMessage, error) {
+	r, ok := s.room(room)
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	messages := r.messages.Lookup(seq)
+	if username == "" {
+		return withParents(r.messages, messages), nil
+	}
+	var res []*ChatMessage
+	for _, message := range messages {
+		if !r.isHidden(username, message.Seq) {
+			res = append(res, message)
+		}
+	}
+	return withParents(r.messages, res), nil
+}
+
+func (s *LocalChatStorage) room(name string) (*localRoom, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	room, ok := s.rooms[name]
+	return room, ok
+}
+
+func (s *LocalChatStorage) createRoom(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.rooms[name]; !ok {
+		conversation := newLocalConversation()
+		s.rooms[name] = newLocalRoom(conversation)
+		s.conversations[RoomConversation(name)] = conversation
+	}
+}
+
+func (s *LocalChatStorage) joinRoom(name string, username string) {
+	room, ok := s.room(name)
+	if !ok {
+		return
+	}
+	room.messages.mutex.Lock()
+	room.members[username] = struct{}{}
+	room.messages.mutex.Unlock()
+
+	s.mutex.Lock()
+	s.addUserRoom(username, name)
+	s.mutex.Unlock()
+}
+
+func (s *LocalChatStorage) leaveRoom(name string, username string) {
+	room, ok := s.room(name)
+	if !ok {
+		return
+	}
+	room.messages.mutex.Lock()
+	delete(room.members, username)
+	room.messages.mutex.Unlock()
+
+	s.mutex.Lock()
+	delete(s.userRooms[username], name)
+	s.mutex.Unlock()
+}
+
+func (s *LocalChatStorage) putRoomMessage(message *ChatMessage) *ChatMessage {
+	room, ok := s.room(message.Room)
+	if !ok {
+		return nil
+	}
+	return room.messages.put(message)
+}
+
+// addUserRoom 调用方持有 s.mutex
+func (s *LocalChatStorage) addUserRoom(username string, room string) {
+	if _, ok := s.userRooms[username]; !ok {
+		s.userRooms[username] = map[string]struct{}{}
+	}
+	s.userRooms[username][room] = struct{}{}
+}
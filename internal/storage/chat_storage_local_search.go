@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+func (s *LocalChatStorage) SearchMessages(username string, query *SearchQuery) ([]*ChatMessage, error) {
+	terms := tokenize(query.Keyword, true)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// 从最短的倒排列表开始，逐条校验当前内容和权限
+	s.mutex.RLock()
+	var candidates []*localMessageRef
+	for i, term := range terms {
+		refs := s.terms[term]
+		if i == 0 || len(refs) < len(candidates) {
+			candidates = refs
+		}
+	}
+	candidates = append([]*localMessageRef(nil), candidates...)
+	s.mutex.RUnlock()
+
+	index, _ := s.userIndex(username)
+	seen := map[localMessageRef]struct{}{}
+	var res []*ChatMessage
+	for _, ref := range candidates {
+		if _, ok := seen[*ref]; ok {
+			continue
+		}
+		seen[*ref] = struct{}{}
+
+		conversation, ok := s.conversation(ref.conversation)
+		if !ok {
+			continue
+		}
+		message := conversation.messages.get(ref.seq)
+		if message == nil || message.DeletedAt != nil || conversation.isHidden(username, message.Seq) || !query.match(message, terms) {
+			continue
+		}
+		if message.Room != "" {
+			room, ok := s.room(message.Room)
+			if !ok || !room.isMember(username) {
+				continue
+			}
+			res = append(res, withParents(room.messages, []*ChatMessage{message})[0])
+			continue
+		}
+		if index == nil {
+			continue
+		}
+		seq := index.seqOf(ref.conversation, message.Seq)
+		if seq == 0 {
+			continue
+		}
+		res = append(res, s.viewAt(username, index, conversation, message, seq))
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Timestamp.Equal(res[j].Timestamp) {
+			return res[i].Timestamp.After(res[j].Timestamp)
+		}
+		if res[i].Room != res[j].Room {
+			return res[i].Room < res[j].Room
+		}
+		return res[i].Seq > res[j].Seq
+	})
+	if query.Limit > 0 && len(res) > query.Limit {
+		res = res[:query.Limit]
+	}
+	return res, nil
+}
+
+// match 校验发送方、时间范围和当前内容。倒排索引只包含当前内容，这里防止分词规则之外的误匹配
+func (q *SearchQuery) match(message *ChatMessage, terms []string) bool {
+	if q.From != "" && message.From != q.From {
+		return false
+	}
+	if !q.Since.IsZero() && message.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !message.Timestamp.Before(q.Until) {
+		return false
+	}
+	contentTerms := map[string]struct{}{}
+	for _, term := range tokenize(message.Content, false) {
+		contentTerms[term] = struct{}{}
+	}
+	for _, term := range terms {
+		if _, ok := contentTerms[term]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// indexTerms 把内容中的词加入倒排索引，调用方持有 s.mutex
+func (s *LocalChatStorage) indexTerms(ref *localMessageRef, content string) {
+	for _, term := range tokenize(content, false) {
+		s.terms[term] = append(s.terms[term], ref)
+	}
+}
+
+// unindexTerms 从倒排索引中去掉消息的 content 中的词，调用方持有 s.mutex
+func (s *LocalChatStorage) unindexTerms(ref localMessageRef, content string) {
+	for _, term := range tokenize(content, false) {
+		refs := s.terms[term]
+		kept := refs[:0:0]
+		for _, r := range refs {
+			if *r != ref {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.terms, term)
+		} else {
+			s.terms[term] = kept
+		}
+	}
+}
+
+// tokenize 字母和数字连续的部分是一个词，转成小写；中日韩文字没有空格分词，按单字和相邻两个字切分
+// 查询时连续两个以上的中文只用相邻两个字，要求全部出现，单独一个字时用单字。返回的词不重复
+func tokenize(text string, query bool) []string {
+	var terms []string
+	seen := map[string]struct{}{}
+	add := func(term string) {
+		if _, ok := seen[term]; ok {
+			return
+		}
+		seen[term] = struct{}{}
+		terms = append(terms, term)
+	}
+
+	var word []rune
+	var cjk []rune
+	flush := func() {
+		if len(word) != 0 {
+			add(string(word))
+			word = word[:0]
+		}
+		if len(cjk) == 1 || (len(cjk) != 0 && !query) {
+			for _, r := range cjk {
+				add(string(r))
+			}
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			add(string(cjk[i : i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			if len(word) != 0 {
+				flush()
+			}
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(cjk) != 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestLocalChatStorageSearchAfterEditAndDelete(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		// 在 "hello world" 上依次执行的操作
+		ops []func(s *LocalChatStorage, seq int64) error
+		// 关键词 -> 能搜到的消息数
+		want map[string]int
+	}{
+		{
+			name: "not changed",
+			want: map[string]int{"hello": 1, "world": 1},
+		},
+		{
+			name: "edit",
+			ops: []func(s *LocalChatStorage, seq int64) error{
+				func(s *LocalChatStorage, seq int64) error {
+					_, _, err := s.EditMessage("alice", seq, "goodbye world")
+					return err
+				},
+			},
+			want: map[string]int{"hello": 0, "world": 1, "goodbye": 1},
+		},
+		{
+			name: "edit twice",
+			ops: []func(s *LocalChatStorage, seq int64) error{
+				func(s *LocalChatStorage, seq int64) error {
+					_, _, err := s.EditMessage("alice", seq, "goodbye world")
+					return err
+				},
+				func(s *LocalChatStorage, seq int64) error {
+					_, _, err := s.EditMessage("alice", seq, "see you")
+					return err
+				},
+			},
+			want: map[string]int{"hello": 0, "world": 0, "goodbye": 0, "see": 1},
+		},
+		{
+			name: "delete for everyone",
+			ops: []func(s *LocalChatStorage, seq int64) error{
+				func(s *LocalChatStorage, seq int64) error {
+					_, _, err := s.DeleteMessage("alice", seq, true)
+					return err
+				},
+			},
+			want: map[string]int{"hello": 0, "world": 0},
+		},
+		{
+			name: "edit then delete",
+			ops: []func(s *LocalChatStorage, seq int64) error{
+				func(s *LocalChatStorage, seq int64) error {
+					_, _, err := s.EditMessage("alice", seq, "goodbye")
+					return err
+				},
+				func(s *LocalChatStorage, seq int64) error {
+					_, _, err := s.DeleteMessage("alice", seq, true)
+					return err
+				},
+			},
+			want: map[string]int{"hello": 0, "goodbye": 0},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := NewLocalChatStorageWithOptions(&LocalChatStorageOptions{})
+			if err != nil {
+				t.Fatalf("NewLocalChatStorageWithOptions failed: %v", err)
+			}
+			defer s.Close()
+
+			message, _, err := s.PutMessage("", "alice", "bob", "hello world", 0, 0)
+			if err != nil {
+				t.Fatalf("PutMessage failed: %v", err)
+			}
+			for _, op := range c.ops {
+				if err := op(s, message.Seq); err != nil {
+					t.Fatalf("op failed: %v", err)
+				}
+			}
+
+			for keyword, want := range c.want {
+				res, err := s.SearchMessages("bob", &SearchQuery{Keyword: keyword})
+				if err != nil {
+					t.Fatalf("SearchMessages failed: %v", err)
+				}
+				if len(res) != want {
+					t.Fatalf("SearchMessages(%q) = %d messages, want %d", keyword, len(res), want)
+				}
+				// 旧内容中的词不能留在倒排索引里
+				s.mutex.RLock()
+				refs := len(s.terms[keyword])
+				s.mutex.RUnlock()
+				if refs != want {
+					t.Fatalf("terms[%q] = %d refs, want %d", keyword, refs, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalChatStorageSearchRoomAfterEdit(t *testing.T) {
+	s, err := NewLocalChatStorageWithOptions(&LocalChatStorageOptions{})
+	if err != nil {
+		t.Fatalf("NewLocalChatStorageWithOptions failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateRoom("lobby", "alice"); err != nil {
+		t.Fatalf("CreateRoom failed: %v", err)
+	}
+	message, err := s.PutRoomMessage("", "lobby", "alice", "你好世界", 0, 0)
+	if err != nil {
+		t.Fatalf("PutRoomMessage failed: %v", err)
+	}
+	if _, err := s.EditRoomMessage("lobby", "alice", message.Seq, "再见"); err != nil {
+		t.Fatalf("EditRoomMessage failed: %v", err)
+	}
+
+	for keyword, want := range map[string]int{"你好": 0, "世界": 0, "再见": 1} {
+		res, err := s.SearchMessages("alice", &SearchQuery{Keyword: keyword})
+		if err != nil {
+			t.Fatalf("SearchMessages failed: %v", err)
+		}
+		if len(res) != want {
+			t.Fatalf("SearchMessages(%q) = %d messages, want %d", keyword, len(res), want)
+		}
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, term := range []string{"你", "好", "世", "界", "你好", "世界", "好世"} {
+		if len(s.terms[term]) != 0 {
+			t.Fatalf("terms[%q] still has %d refs after edit", term, len(s.terms[term]))
+		}
+	}
+}
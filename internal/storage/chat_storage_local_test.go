@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestLocalChatStorageDedupe(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		msgIDs []string
+		// 每次写入之后重启一次，去重索引要能从日志和快照恢复
+		reopen bool
+		want   int
+	}{
+		{name: "same msg id", msgIDs: []string{"m1", "m1"}, want: 1},
+		{name: "different msg ids", msgIDs: []string{"m1", "m2"}, want: 2},
+		{name: "empty msg id is not deduped", msgIDs: []string{"", ""}, want: 2},
+		{name: "same msg id after restart", msgIDs: []string{"m1", "m1"}, reopen: true, want: 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			directory := t.TempDir()
+			s := openTestLocalChatStorage(t, directory)
+			defer func() { _ = s.Close() }()
+
+			var first *ChatMessage
+			for _, msgID := range c.msgIDs {
+				message, _, err := s.PutMessage(msgID, "alice", "bob", "hello", 0, 0)
+				if err != nil && err != ErrDuplicateMessage {
+					t.Fatalf("PutMessage failed: %v", err)
+				}
+				if first == nil {
+					first = message
+				} else if err == ErrDuplicateMessage && message.Seq != first.Seq {
+					t.Fatalf("duplicate returns seq %d, want %d", message.Seq, first.Seq)
+				}
+				if c.reopen {
+					crashLocalChatStorage(t, s)
+					s = openTestLocalChatStorage(t, directory)
+				}
+			}
+
+			messages, err := s.GetMessageByUser("bob", 0)
+			if err != nil {
+				t.Fatalf("GetMessageByUser failed: %v", err)
+			}
+			if len(messages) != c.want {
+				t.Fatalf("messages = %d, want %d", len(messages), c.want)
+			}
+		})
+	}
+}
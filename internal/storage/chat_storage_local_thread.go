@@ -0,0 +1,71 @@
+package storage
+
+func (s *LocalChatStorage) GetThread(username string, room string, seq int64) (*ChatMessage, []*ChatMessage, error) {
+	var conversation *localConversation
+	var parent *ChatMessage
+	if room != "" {
+		r, ok := s.room(room)
+		if !ok {
+			return nil, nil, ErrRoomNotFound
+		}
+		if !r.isMember(username) {
+			return nil, nil, ErrNotRoomMember
+		}
+		conversation = r.localConversation
+		parent = r.messages.get(seq)
+	} else {
+		conversation, parent = s.userMessage(username, seq)
+	}
+	if parent == nil || conversation.isHidden(username, parent.Seq) {
+		return nil, nil, ErrMessageNotFound
+	}
+
+	// 回复一定在被回复的消息之后
+	var replies []*ChatMessage
+	for _, message := range conversation.messages.Lookup(parent.Seq + 1) {
+		if message.ReplyTo == parent.Seq && !conversation.isHidden(username, message.Seq) {
+			replies = append(replies, message)
+		}
+	}
+	if room != "" {
+		return withParents(conversation.messages, []*ChatMessage{parent})[0], withParents(conversation.messages, replies), nil
+	}
+	for i, message := range replies {
+		replies[i] = s.view(username, conversation, message)
+	}
+	return s.view(username, conversation, parent), replies, nil
+}
+
+// replyParent 校验回复的消息，必须存在、没有被删除，私聊时还必须是同一个会话中的消息。返回会话中保存的消息
+func (s *LocalChatStorage) replyParent(message *ChatMessage) (*ChatMessage, error) {
+	var parent *ChatMessage
+	if message.Room != "" {
+		if room, ok := s.room(message.Room); ok {
+			parent = room.messages.get(message.ReplyTo)
+		}
+	} else {
+		conversation, m := s.userMessage(message.From, message.ReplyTo)
+		if m != nil && conversationID(m) == conversationID(message) && !conversation.isHidden(message.From, m.Seq) {
+			parent = m
+		}
+	}
+	if parent == nil || parent.DeletedAt != nil {
+		return nil, ErrMessageNotFound
+	}
+	return parent, nil
+}
+
+// withParents 房间消息复制一份再填充 Parent，不修改保存的消息。私聊消息在 view 中填充
+func withParents(messages *ChatMessages, list []*ChatMessage) []*ChatMessage {
+	res := make([]*ChatMessage, len(list))
+	for i, message := range list {
+		res[i] = message
+		if message == nil || message.ReplyTo == 0 {
+			continue
+		}
+		msg := *message
+		msg.Parent = messages.get(message.ReplyTo)
+		res[i] = &msg
+	}
+	return res
+}
@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	walFileName      = "chat.wal"
+	snapshotFileName = "chat.snapshot"
+)
+
+const (
+	walOpPutMessage     = "PutMessage"
+	walOpCreateRoom     = "CreateRoom"
+	walOpJoinRoom       = "JoinRoom"
+	walOpLeaveRoom      = "LeaveRoom"
+	walOpPutRoomMessage = "PutRoomMessage"
+	walOpReadCursor     = "ReadCursor"
+	walOpEditMessage    = "EditMessage"
+	walOpDeleteMessage  = "DeleteMessage"
+	walOpReact          = "React"
+	walOpExpire         = "Expire"
+	walOpDeliver        = "Deliver"
+)
+
+const (
+	SyncPolicyAlways   = "Always"
+	SyncPolicyInterval = "Interval"
+	SyncPolicyNever    = "Never"
+)
+
+// walRecord 日志中的一条记录，每行一个 json
+type walRecord struct {
+	LSN      int64
+	Op       string
+	Message  *ChatMessage `json:",omitempty"`
+	Room     string       `json:",omitempty"`
+	Username string       `json:",omitempty"`
+	Cursor   *ReadCursor  `json:",omitempty"`
+	// 删除消息时是否为所有人删除
+	ForEveryone bool   `json:",omitempty"`
+	Emoji       string `json:",omitempty"`
+	// 去掉表情回应
+	Remove bool `json:",omitempty"`
+	// 会话 ID -> 过期删除的消息序号
+	Expired map[string][]int64 `json:",omitempty"`
+	// 投递位置，信箱的序号
+	Delivered int64 `json:",omitempty"`
+}
+
+type localSnapshotConversation struct {
+	Seq      int64
+	Messages []*ChatMessage
+	// 用户名 -> 自己删除的消息序号
+	Hidden map[string][]int64 `json:",omitempty"`
+}
+
+type localSnapshotIndex struct {
+	Seq     int64
+	Entries []*localIndexEntry
+}
+
+type localSnapshotRoom struct {
+	Members []string
+	localSnapshotConversation
+}
+
+// localSnapshot 包含 LSN 之前（含）所有日志记录的结果，回放时跳过这些记录
+type localSnapshot struct {
+	LSN int64
+	// 私聊会话 ID -> 会话，房间的会话在 Rooms 中
+	Conversations map[string]*localSnapshotConversation `json:",omitempty"`
+	// 用户名 -> 私聊消息索引
+	Indexes map[string]*localSnapshotIndex `json:",omitempty"`
+	Rooms   map[string]*localSnapshotRoom
+	// 用户名 -> 已读位置
+	Cursors map[string][]*ReadCursor `json:",omitempty"`
+	// 用户名 -> 投递位置
+	Delivered map[string]int64 `json:",omitempty"`
+}
+
+// walFile 日志文件，测试中可以换成会出错的实现
+type walFile interface {
+	io.ReadWriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+type localWAL struct {
+	directory  string
+	syncPolicy string
+
+	file  walFile
+	lsn   int64
+	dirty bool
+	// 写入失败后没能截掉已经写入的内容，日志末尾不可信，之后的写入都返回这个错误
+	broken error
+	mutex  sync.Mutex
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func openWAL(directory string, syncPolicy string, syncInterval time.Duration) (*localWAL, error) {
+	switch syncPolicy {
+	case "":
+		syncPolicy = SyncPolicyInterval
+	case SyncPolicyAlways, SyncPolicyInterval, SyncPolicyNever:
+	default:
+		return nil, errors.Errorf("unsupported sync policy [%s]", syncPolicy)
+	}
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, errors.Wrap(err, "os.MkdirAll failed")
+	}
+	file, err := os.OpenFile(filepath.Join(directory, walFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "os.OpenFile failed")
+	}
+
+	w := &localWAL{
+		directory:  directory,
+		syncPolicy: syncPolicy,
+		file:       file,
+	}
+
+	if syncPolicy == SyncPolicyInterval {
+		if syncInterval <= 0 {
+			syncInterval = time.Second
+		}
+		w.done = make(chan struct{})
+		w.wg.Add(1)
+		go w.syncLoop(syncInterval)
+	}
+
+	return w, nil
+}
+
+// Replay 先加载快照，再按顺序回放快照之后的日志。只有末尾没有换行的记录是写了一半的，会被截掉
+// 中间的记录损坏时返回错误，不能丢掉它之后的记录
+func (w *localWAL) Replay(restore func(*localSnapshot), apply func(*walRecord) []*ChatMessage) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	buf, err := os.ReadFile(filepath.Join(w.directory, snapshotFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "os.ReadFile failed")
+	}
+	if err == nil {
+		var snapshot localSnapshot
+		if err := json.Unmarshal(buf, &snapshot); err != nil {
+			return errors.Wrap(err, "json.Unmarshal snapshot failed")
+		}
+		restore(&snapshot)
+		w.lsn = snapshot.LSN
+	}
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "file.Seek failed")
+	}
+	reader := bufio.NewReader(w.file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "reader.ReadBytes failed")
+		}
+		var record walRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return errors.Wrapf(err, "json.Unmarshal record at offset [%d] failed", offset)
+		}
+		offset += int64(len(line))
+		if record.LSN <= w.lsn {
+			continue
+		}
+		apply(&record)
+		w.lsn = record.LSN
+	}
+
+	if err := w.file.Truncate(offset); err != nil {
+		return errors.Wrap(err, "file.Truncate failed")
+	}
+	if _, err := w.file.Seek(offset, io.SeekStart); err != nil {
+		return errors.Wrap(err, "file.Seek failed")
+	}
+
+	return nil
+}
+
+func (w *localWAL) Append(record *walRecord) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.broken != nil {
+		return w.broken
+	}
+
+	record.LSN = w.lsn + 1
+	buf, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal failed")
+	}
+	offset, err := w.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.Wrap(err, "file.Seek failed")
+	}
+	if _, err := w.file.Write(append(buf, '\n')); err != nil {
+		// 写了一部分时截掉，否则之后的记录都会跟在一行损坏的记录后面
+		return w.rollback(offset, errors.Wrap(err, "file.Write failed"))
+	}
+	w.dirty = true
+
+	if w.syncPolicy == SyncPolicyAlways {
+		if err := w.sync(); err != nil {
+			// 调用方收到错误不会修改内存，记录留在日志中的话重启后会回放出来，客户端重试时也不会被当成重复消息
+			return w.rollback(offset, err)
+		}
+	}
+	w.lsn = record.LSN
+	return nil
+}
+
+// rollback 截掉 offset 之后写入的内容，lsn 保持不变。截不掉时之后的写入全部拒绝
+func (w *localWAL) rollback(offset int64, err error) error {
+	if e := w.file.Truncate(offset); e != nil {
+		w.broken = errors.WithMessagef(err, "file.Truncate failed: %v", e)
+		return w.broken
+	}
+	if _, e := w.file.Seek(offset, io.SeekStart); e != nil {
+		w.broken = errors.WithMessagef(err, "file.Seek failed: %v", e)
+		return w.broken
+	}
+	return err
+}
+
+// Checkpoint 写入快照后清空日志。快照落盘后、清空日志前崩溃也没关系，回放时会按 LSN 跳过
+func (w *localWAL) Checkpoint(snapshot *localSnapshot) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	snapshot.LSN = w.lsn
+	buf, err := json.Marshal(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal failed")
+	}
+
+	filename := filepath.Join(w.directory, snapshotFileName)
+	if err := writeFileSync(filename+".tmp", buf); err != nil {
+		return errors.WithMessage(err, "writeFileSync failed")
+	}
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		return errors.Wrap(err, "os.Rename failed")
+	}
+	if err := syncDir(w.directory); err != nil {
+		return errors.WithMessage(err, "syncDir failed")
+	}
+
+	if err := w.file.Truncate(0); err != nil {
+		return errors.Wrap(err, "file.Truncate failed")
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "file.Seek failed")
+	}
+	// 快照只包含内存中的记录，没截掉的内容随日志一起清空，可以恢复写入
+	w.broken = nil
+	w.dirty = true
+	return w.sync()
+}
+
+func (w *localWAL) Close() error {
+	if w.done != nil {
+		close(w.done)
+		w.wg.Wait()
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.sync(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return errors.Wrap(w.file.Close(), "file.Close failed")
+}
+
+func (w *localWAL) syncLoop(interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mutex.Lock()
+			_ = w.sync()
+			w.mutex.Unlock()
+		}
+	}
+}
+
+func (w *localWAL) sync() error {
+	if !w.dirty {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return errors.Wrap(err, "file.Sync failed")
+	}
+	w.dirty = false
+	return nil
+}
+
+func writeFileSync(filename string, buf []byte) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrap(err, "os.OpenFile failed")
+	}
+	if _, err := file.Write(buf); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "file.Write failed")
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "file.Sync failed")
+	}
+	return errors.Wrap(file.Close(), "file.Close failed")
+}
+
+func syncDir(directory string) error {
+	dir, err := os.Open(directory)
+	if err != nil {
+		return errors.Wrap(err, "os.Open failed")
+	}
+	defer dir.Close()
+	return errors.Wrap(dir.Sync(), "dir.Sync failed")
+}
@@ -0,0 +1,373 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func walLine(t *testing.T, lsn int64) string {
+	t.Helper()
+	buf, err := json.Marshal(&walRecord{LSN: lsn, Op: walOpPutMessage, Message: &ChatMessage{From: "alice", To: "bob", Content: "hello"}})
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	return string(buf) + "\n"
+}
+
+func TestLocalWALReplay(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		// 快照中的 LSN，0 表示没有快照
+		snapshotLSN int64
+		wal         func(t *testing.T) string
+		wantErr     bool
+		wantLSNs    []int64
+		// 回放之后日志文件应该保留的内容
+		wantWAL func(t *testing.T) string
+	}{
+		{
+			name:     "empty",
+			wal:      func(t *testing.T) string { return "" },
+			wantWAL:  func(t *testing.T) string { return "" },
+			wantLSNs: nil,
+		},
+		{
+			name: "complete",
+			wal: func(t *testing.T) string {
+				return walLine(t, 1) + walLine(t, 2) + walLine(t, 3)
+			},
+			wantWAL: func(t *testing.T) string {
+				return walLine(t, 1) + walLine(t, 2) + walLine(t, 3)
+			},
+			wantLSNs: []int64{1, 2, 3},
+		},
+		{
+			name: "torn tail is truncated",
+			wal: func(t *testing.T) string {
+				return walLine(t, 1) + walLine(t, 2) + `{"LSN":3,"Op":"PutMe`
+			},
+			wantWAL: func(t *testing.T) string {
+				return walLine(t, 1) + walLine(t, 2)
+			},
+			wantLSNs: []int64{1, 2},
+		},
+		{
+			name: "torn tail only",
+			wal: func(t *testing.T) string {
+				return `{"LSN":1,`
+			},
+			wantWAL:  func(t *testing.T) string { return "" },
+			wantLSNs: nil,
+		},
+		{
+			name: "corrupt middle record",
+			wal: func(t *testing.T) string {
+				return walLine(t, 1) + "not a json record\n" + walLine(t, 3)
+			},
+			wantErr: true,
+		},
+		{
+			name: "corrupt last complete record",
+			wal: func(t *testing.T) string {
+				return walLine(t, 1) + `{"LSN":2,"Op":` + "\n"
+			},
+			wantErr: true,
+		},
+		{
+			name:        "skip records in snapshot",
+			snapshotLSN: 2,
+			wal: func(t *testing.T) string {
+				return walLine(t, 1) + walLine(t, 2) + walLine(t, 3)
+			},
+			wantWAL: func(t *testing.T) string {
+				return walLine(t, 1) + walLine(t, 2) + walLine(t, 3)
+			},
+			wantLSNs: []int64{3},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			directory := t.TempDir()
+			if err := os.WriteFile(filepath.Join(directory, walFileName), []byte(c.wal(t)), 0644); err != nil {
+				t.Fatalf("os.WriteFile failed: %v", err)
+			}
+			if c.snapshotLSN != 0 {
+				buf, _ := json.Marshal(&localSnapshot{LSN: c.snapshotLSN})
+				if err := os.WriteFile(filepath.Join(directory, snapshotFileName), buf, 0644); err != nil {
+					t.Fatalf("os.WriteFile failed: %v", err)
+				}
+			}
+
+			wal, err := openWAL(directory, SyncPolicyAlways, 0)
+			if err != nil {
+				t.Fatalf("openWAL failed: %v", err)
+			}
+			defer wal.Close()
+
+			var lsns []int64
+			err = wal.Replay(func(*localSnapshot) {}, func(record *walRecord) []*ChatMessage {
+				lsns = append(lsns, record.LSN)
+				return nil
+			})
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("Replay succeeded, want error")
+				}
+				// 损坏的日志原样保留，等人工处理
+				buf, err := os.ReadFile(filepath.Join(directory, walFileName))
+				if err != nil {
+					t.Fatalf("os.ReadFile failed: %v", err)
+				}
+				if string(buf) != c.wal(t) {
+					t.Fatalf("wal = %q, want %q", buf, c.wal(t))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Replay failed: %v", err)
+			}
+			if len(lsns) != len(c.wantLSNs) {
+				t.Fatalf("applied %v, want %v", lsns, c.wantLSNs)
+			}
+			for i := range lsns {
+				if lsns[i] != c.wantLSNs[i] {
+					t.Fatalf("applied %v, want %v", lsns, c.wantLSNs)
+				}
+			}
+
+			buf, err := os.ReadFile(filepath.Join(directory, walFileName))
+			if err != nil {
+				t.Fatalf("os.ReadFile failed: %v", err)
+			}
+			if string(buf) != c.wantWAL(t) {
+				t.Fatalf("wal = %q, want %q", buf, c.wantWAL(t))
+			}
+
+			// 新的记录接在回放位置之后，序号继续增长
+			record := &walRecord{Op: walOpPutMessage, Message: &ChatMessage{From: "alice", To: "bob", Content: "next"}}
+			if err := wal.Append(record); err != nil {
+				t.Fatalf("Append failed: %v", err)
+			}
+			want := c.snapshotLSN
+			if len(c.wantLSNs) != 0 {
+				want = c.wantLSNs[len(c.wantLSNs)-1]
+			}
+			if record.LSN != want+1 {
+				t.Fatalf("appended LSN = %d, want %d", record.LSN, want+1)
+			}
+			buf, err = os.ReadFile(filepath.Join(directory, walFileName))
+			if err != nil {
+				t.Fatalf("os.ReadFile failed: %v", err)
+			}
+			if !strings.HasPrefix(string(buf), c.wantWAL(t)) || strings.Count(string(buf), "\n") != strings.Count(c.wantWAL(t), "\n")+1 {
+				t.Fatalf("wal after append = %q", buf)
+			}
+		})
+	}
+}
+
+// crashLocalChatStorage 模拟进程崩溃：停止后台任务、关闭日志文件，不写快照
+func crashLocalChatStorage(t *testing.T, s *LocalChatStorage) {
+	t.Helper()
+	if s.done != nil {
+		close(s.done)
+		s.wg.Wait()
+	}
+	if err := s.wal.Close(); err != nil {
+		t.Fatalf("wal.Close failed: %v", err)
+	}
+}
+
+func openTestLocalChatStorage(t *testing.T, directory string) *LocalChatStorage {
+	t.Helper()
+	s, err := NewLocalChatStorageWithOptions(&LocalChatStorageOptions{
+		Directory:  directory,
+		SyncPolicy: SyncPolicyAlways,
+	})
+	if err != nil {
+		t.Fatalf("NewLocalChatStorageWithOptions failed: %v", err)
+	}
+	return s
+}
+
+func TestLocalChatStorageRecover(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		// 写完第几条消息之后写快照，0 表示不写
+		snapshotAfter int
+		// 崩溃时日志末尾写了一半的记录
+		tornTail string
+		// 正常关闭，关闭时会写快照
+		close bool
+	}{
+		{name: "wal only"},
+		{name: "snapshot then wal", snapshotAfter: 2},
+		{name: "snapshot only", snapshotAfter: 3},
+		{name: "torn tail", tornTail: `{"LSN":100,"Op":"PutMessage","Message":{"Content":"lo`},
+		{name: "snapshot and torn tail", snapshotAfter: 1, tornTail: `{"LSN":100,`},
+		{name: "close", close: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			directory := t.TempDir()
+			s := openTestLocalChatStorage(t, directory)
+			for i, content := range []string{"one", "two", "three"} {
+				if _, _, err := s.PutMessage("", "alice", "bob", content, 0, 0); err != nil {
+					t.Fatalf("PutMessage failed: %v", err)
+				}
+				if i+1 == c.snapshotAfter {
+					if err := s.Snapshot(); err != nil {
+						t.Fatalf("Snapshot failed: %v", err)
+					}
+				}
+			}
+			if _, _, err := s.EditMessage("alice", 1, "one edited"); err != nil {
+				t.Fatalf("EditMessage failed: %v", err)
+			}
+			if c.close {
+				if err := s.Close(); err != nil {
+					t.Fatalf("Close failed: %v", err)
+				}
+			} else {
+				crashLocalChatStorage(t, s)
+			}
+			if c.tornTail != "" {
+				fp, err := os.OpenFile(filepath.Join(directory, walFileName), os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					t.Fatalf("os.OpenFile failed: %v", err)
+				}
+				if _, err := fp.WriteString(c.tornTail); err != nil {
+					t.Fatalf("fp.WriteString failed: %v", err)
+				}
+				_ = fp.Close()
+			}
+
+			// 恢复之后还能继续写，再次恢复时不受截掉的记录影响
+			s = openTestLocalChatStorage(t, directory)
+			if _, _, err := s.PutMessage("", "bob", "alice", "four", 0, 0); err != nil {
+				t.Fatalf("PutMessage failed: %v", err)
+			}
+			crashLocalChatStorage(t, s)
+			s = openTestLocalChatStorage(t, directory)
+			defer s.Close()
+
+			messages, err := s.GetMessageByUser("bob", 0)
+			if err != nil {
+				t.Fatalf("GetMessageByUser failed: %v", err)
+			}
+			var contents []string
+			for i, message := range messages {
+				if message.Seq != int64(i+1) {
+					t.Fatalf("message %q seq = %d, want %d", message.Content, message.Seq, i+1)
+				}
+				contents = append(contents, message.Content)
+			}
+			want := []string{"one edited", "two", "three", "four"}
+			if len(contents) != len(want) {
+				t.Fatalf("contents = %q, want %q", contents, want)
+			}
+			for i := range want {
+				if contents[i] != want[i] {
+					t.Fatalf("contents = %q, want %q", contents, want)
+				}
+			}
+		})
+	}
+}
+
+// faultyWALFile 按设置的错误让写入、刷盘和截断失败，写入失败时只写进去一半
+type faultyWALFile struct {
+	walFile
+	writeErr    error
+	syncErr     error
+	truncateErr error
+}
+
+func (f *faultyWALFile) Write(p []byte) (int, error) {
+	if f.writeErr != nil {
+		n, _ := f.walFile.Write(p[:len(p)/2])
+		return n, f.writeErr
+	}
+	return f.walFile.Write(p)
+}
+
+func (f *faultyWALFile) Sync() error {
+	if f.syncErr != nil {
+		return f.syncErr
+	}
+	return f.walFile.Sync()
+}
+
+func (f *faultyWALFile) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.walFile.Truncate(size)
+}
+
+func TestLocalChatStorageAppendFailure(t *testing.T) {
+	ioErr := errors.New("input/output error")
+	for _, c := range []struct {
+		name  string
+		fault faultyWALFile
+		// 截不掉写入的内容时，之后的写入都会失败，直到下一次快照
+		wantBroken bool
+	}{
+		{name: "partial write", fault: faultyWALFile{writeErr: ioErr}},
+		{name: "sync failure", fault: faultyWALFile{syncErr: ioErr}},
+		{name: "sync and truncate failure", fault: faultyWALFile{syncErr: ioErr, truncateErr: ioErr}, wantBroken: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			directory := t.TempDir()
+			s := openTestLocalChatStorage(t, directory)
+			if _, _, err := s.PutMessage("m1", "alice", "bob", "one", 0, 0); err != nil {
+				t.Fatalf("PutMessage failed: %v", err)
+			}
+
+			fault := c.fault
+			fault.walFile = s.wal.file
+			s.wal.file = &fault
+			if _, _, err := s.PutMessage("m2", "alice", "bob", "two", 0, 0); err == nil {
+				t.Fatalf("PutMessage succeeded, want error")
+			}
+			s.wal.file = fault.walFile
+
+			// 客户端用同一个 msgID 重试，不能被当成重复消息
+			_, _, err := s.PutMessage("m2", "alice", "bob", "two", 0, 0)
+			if c.wantBroken {
+				if err == nil {
+					t.Fatalf("PutMessage on broken wal succeeded, want error")
+				}
+				if err := s.Snapshot(); err != nil {
+					t.Fatalf("Snapshot failed: %v", err)
+				}
+				_, _, err = s.PutMessage("m2", "alice", "bob", "two", 0, 0)
+			}
+			if err != nil {
+				t.Fatalf("retry PutMessage failed: %v", err)
+			}
+
+			crashLocalChatStorage(t, s)
+			s = openTestLocalChatStorage(t, directory)
+			defer s.Close()
+
+			messages, err := s.GetMessageByUser("bob", 0)
+			if err != nil {
+				t.Fatalf("GetMessageByUser failed: %v", err)
+			}
+			var contents []string
+			for i, message := range messages {
+				if message.Seq != int64(i+1) {
+					t.Fatalf("message %q seq = %d, want %d", message.Content, message.Seq, i+1)
+				}
+				contents = append(contents, message.Content)
+			}
+			if len(contents) != 2 || contents[0] != "one" || contents[1] != "two" {
+				t.Fatalf("contents = %q, want [one two]", contents)
+			}
+		})
+	}
+}
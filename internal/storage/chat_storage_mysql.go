@@ -1,4 +1,317 @@
 package storage
 
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+// MysqlChatStorageOptions 需要 MySQL 8.0 及以上，按条数保留时用到了窗口函数 ROW_NUMBER
+type MysqlChatStorageOptions struct {
+	Username        string `dft:"root"`
+	Password        string
+	Address         string        `dft:"127.0.0.1:3306"`
+	Database        string        `dft:"chat"`
+	MaxOpenConns    int           `dft:"20"`
+	MaxIdleConns    int           `dft:"10"`
+	ConnMaxLifetime time.Duration `dft:"60s"`
+	Retention       RetentionOptions
+}
+
+func NewMysqlChatStorageWithOptions(options *MysqlChatStorageOptions) (*MysqlChatStorage, error) {
+	db, err := openMysql(options)
+	if err != nil {
+		return nil, errors.WithMessage(err, "openMysql failed")
+	}
+
+	s := &MysqlChatStorage{db: db, options: options, done: make(chan struct{})}
+	s.startJanitor()
+	return s, nil
+}
+
 type MysqlChatStorage struct {
+	db      *sql.DB
+	options *MysqlChatStorageOptions
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func (s *MysqlChatStorage) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	return s.db.Close()
+}
+
+// openMysql 连接数据库并执行结构变更，MysqlChatStorage 和 MysqlUserStorage 共用
+func openMysql(options *MysqlChatStorageOptions) (*sql.DB, error) {
+	cfg := mysql.NewConfig()
+	cfg.User = options.Username
+	cfg.Passwd = options.Password
+	cfg.Net = "tcp"
+	cfg.Addr = options.Address
+	cfg.DBName = options.Database
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	cfg.Params = map[string]string{"charset": "utf8mb4"}
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, errors.Wrap(err, "sql.Open failed")
+	}
+	db.SetMaxOpenConns(options.MaxOpenConns)
+	db.SetMaxIdleConns(options.MaxIdleConns)
+	db.SetConnMaxLifetime(options.ConnMaxLifetime)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "db.Ping failed")
+	}
+	if err := migrateMysql(db); err != nil {
+		_ = db.Close()
+		return nil, errors.WithMessage(err, "migrateMysql failed")
+	}
+
+	return db, nil
+}
+
+func (s *MysqlChatStorage) PutMessage(msgID string, from string, to string, content string, replyTo int64, ttl time.Duration) (*ChatMessage, *ChatMessage, error) {
+	if msgID != "" {
+		fromMessage, toMessage, err := s.getMessageByMsgID(msgID, from, to)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "getMessageByMsgID failed")
+		}
+		if fromMessage != nil {
+			return fromMessage, toMessage, ErrDuplicateMessage
+		}
+	}
+
+	fromMessage, toMessage, err := s.putMessage(msgID, from, to, content, replyTo, ttl)
+	// 同一条消息并发重试，另一个请求已经写入
+	if e, ok := errors.Cause(err).(*mysql.MySQLError); ok && e.Number == mysqlErrDupEntry && msgID != "" {
+		fromMessage, toMessage, err := s.getMessageByMsgID(msgID, from, to)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "getMessageByMsgID failed")
+		}
+		return fromMessage, toMessage, ErrDuplicateMessage
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.attachParents(from, "", []*ChatMessage{fromMessage}); err != nil {
+		return nil, nil, errors.WithMessage(err, "attachParents failed")
+	}
+	if toMessage != fromMessage {
+		if err := s.attachParents(to, "", []*ChatMessage{toMessage}); err != nil {
+			return nil, nil, errors.WithMessage(err, "attachParents failed")
+		}
+	}
+	return fromMessage, toMessage, nil
+}
+
+func (s *MysqlChatStorage) putMessage(msgID string, from string, to string, content string, replyTo int64, ttl time.Duration) (*ChatMessage, *ChatMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	conversation := DirectConversation(from, to)
+	var conversationReplyTo int64
+	if replyTo != 0 {
+		if conversationReplyTo, err = s.replyParent(tx, from, conversation, replyTo); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 先锁住会话分配会话中的序号，A->B 和 B->A 在这里串行
+	conversationSeq, err := s.nextConversationSeq(tx, conversation)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "nextConversationSeq failed")
+	}
+	now := time.Now()
+	expiresAt := expiresAt(now, ttl)
+	if _, err := tx.Exec(
+		"INSERT INTO `chat_conversation_message` (`conversation`, `seq`, `timestamp`, `msg_id`, `from`, `to`, `content`, `reply_to`, `expires_at`) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		conversation, conversationSeq, now, nullString(msgID), from, to, content, conversationReplyTo, nullTime(expiresAt),
+	); err != nil {
+		return nil, nil, errors.Wrap(err, "tx.Exec failed")
+	}
+
+	// 再加入双方的索引，按用户名顺序分配信箱序号，避免和其他会话互相等锁。自己发给自己只加入一次
+	owners := []string{from}
+	if to != from {
+		owners = append(owners, to)
+	}
+	sort.Strings(owners)
+	messages := map[string]*ChatMessage{}
+	for _, owner := range owners {
+		seq, err := s.nextSeq(tx, owner)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "nextSeq failed")
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO `chat_user_message` (`owner`, `seq`, `conversation`, `conversation_seq`) VALUES (?, ?, ?, ?)",
+			owner, seq, conversation, conversationSeq,
+		); err != nil {
+			return nil, nil, errors.Wrap(err, "tx.Exec failed")
+		}
+		if _, err := tx.Exec(
+			"INSERT IGNORE INTO `chat_user_conversation` (`username`, `conversation`, `peer`) VALUES (?, ?, ?)",
+			owner, conversation, peerOwner(owner, &ChatMessage{From: from, To: to}),
+		); err != nil {
+			return nil, nil, errors.Wrap(err, "tx.Exec failed")
+		}
+		message := &ChatMessage{
+			Seq: seq, Timestamp: now, MsgID: msgID, From: from, To: to, Content: content, ConversationSeq: conversationSeq,
+			ExpiresAt: expiresAt,
+		}
+		if conversationReplyTo != 0 {
+			if message.ReplyTo, err = s.userSeq(tx, owner, conversation, conversationReplyTo); err != nil {
+				return nil, nil, errors.WithMessage(err, "userSeq failed")
+			}
+		}
+		messages[owner] = message
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, errors.Wrap(err, "tx.Commit failed")
+	}
+	return messages[from], messages[to], nil
+}
+
+// getMessageByMsgID 查找已经保存的消息，返回发送方和接收方看到的消息，没有时返回 nil
+func (s *MysqlChatStorage) getMessageByMsgID(msgID string, from string, to string) (*ChatMessage, *ChatMessage, error) {
+	rows, err := s.db.Query(
+		"SELECT u.`owner`, "+mysqlMessageColumns+" FROM "+mysqlMessageTables+
+			" WHERE c.`conversation` = ? AND c.`from` = ? AND c.`msg_id` = ? AND u.`owner` IN (?, ?) ORDER BY u.`seq`",
+		DirectConversation(from, to), from, msgID, from, to,
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "db.Query failed")
+	}
+	defer rows.Close()
+
+	messages := map[string]*ChatMessage{}
+	for rows.Next() {
+		var owner string
+		message, err := scanMessage(mysqlScannerFunc(func(dest ...interface{}) error {
+			return rows.Scan(append([]interface{}{&owner}, dest...)...)
+		}))
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "scanMessage failed")
+		}
+		if _, ok := messages[owner]; !ok {
+			messages[owner] = message
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "rows.Err")
+	}
+	if messages[from] == nil || messages[to] == nil {
+		return nil, nil, nil
+	}
+	return messages[from], messages[to], nil
+}
+
+func (s *MysqlChatStorage) GetMessageByUser(from string, seq int64) ([]*ChatMessage, error) {
+	messages, err := s.queryMessages(
+		"SELECT "+mysqlMessageColumns+" FROM "+mysqlMessageTables+" WHERE u.`owner` = ? AND u.`seq` >= ? AND u.`hidden` = 0 ORDER BY u.`seq`",
+		from, seq,
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "queryMessages failed")
+	}
+
+	if err := s.attachReactions(messages); err != nil {
+		return nil, errors.WithMessage(err, "attachReactions failed")
+	}
+	if err := s.attachParents(from, "", messages); err != nil {
+		return nil, errors.WithMessage(err, "attachParents failed")
+	}
+	return messages, nil
+}
+
+func (s *MysqlChatStorage) GetContacts(username string) ([]string, error) {
+	return s.queryStrings(
+		"SELECT `peer` AS `contact` FROM `chat_user_conversation` WHERE `username` = ? AND `peer` != ? "+
+			"UNION "+
+			"SELECT DISTINCT m2.`username` FROM `chat_room_member` m1 JOIN `chat_room_member` m2 ON m1.`room` = m2.`room` "+
+			"WHERE m1.`username` = ? AND m2.`username` != ? "+
+			"ORDER BY `contact`",
+		username, username, username, username,
+	)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+// 和 scanMessage 对应。私聊消息从用户的索引 u 连接会话中的消息 c 读出，序号和回复的序号换成 u 的信箱序号
+// 房间消息没有接收方，序号就是会话中的序号
+const (
+	mysqlMessageColumns = "u.`seq`, c.`timestamp`, IFNULL(c.`msg_id`, ''), c.`from`, c.`to`, c.`content`, c.`edited_at`, c.`deleted_at`, " +
+		"IFNULL((SELECT MIN(r.`seq`) FROM `chat_user_message` r " +
+		"WHERE r.`owner` = u.`owner` AND r.`conversation` = c.`conversation` AND r.`conversation_seq` = c.`reply_to`), 0), c.`seq`, c.`expires_at`"
+	mysqlMessageTables = "`chat_user_message` u JOIN `chat_conversation_message` c " +
+		"ON c.`conversation` = u.`conversation` AND c.`seq` = u.`conversation_seq`"
+	mysqlRoomMessageColumns = "`seq`, `timestamp`, IFNULL(`msg_id`, ''), `from`, '', `content`, `edited_at`, `deleted_at`, `reply_to`, 0, `expires_at`"
+)
+
+type mysqlScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type mysqlScannerFunc func(dest ...interface{}) error
+
+func (f mysqlScannerFunc) Scan(dest ...interface{}) error {
+	return f(dest...)
+}
+
+func scanMessage(scanner mysqlScanner) (*ChatMessage, error) {
+	var message ChatMessage
+	var editedAt, deletedAt, expiresAt sql.NullTime
+	if err := scanner.Scan(
+		&message.Seq, &message.Timestamp, &message.MsgID, &message.From, &message.To, &message.Content, &editedAt, &deletedAt, &message.ReplyTo,
+		&message.ConversationSeq, &expiresAt,
+	); err != nil {
+		return nil, errors.Wrap(err, "Scan failed")
+	}
+	if editedAt.Valid {
+		message.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		message.DeletedAt = &deletedAt.Time
+	}
+	if expiresAt.Valid {
+		message.ExpiresAt = &expiresAt.Time
+	}
+	return &message, nil
+}
+
+// nextSeq 在事务中为用户分配下一个序号，upsert 会锁住该用户的序号行直到事务结束
+func (s *MysqlChatStorage) nextSeq(tx *sql.Tx, username string) (int64, error) {
+	if _, err := tx.Exec(
+		"INSERT INTO `chat_user_seq` (`username`, `seq`) VALUES (?, 1) ON DUPLICATE KEY UPDATE `seq` = `seq` + 1",
+		username,
+	); err != nil {
+		return 0, errors.Wrap(err, "tx.Exec failed")
+	}
+	var seq int64
+	if err := tx.QueryRow("SELECT `seq` FROM `chat_user_seq` WHERE `username` = ?", username).Scan(&seq); err != nil {
+		return 0, errors.Wrap(err, "tx.QueryRow failed")
+	}
+	return seq, nil
 }
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+func (s *MysqlChatStorage) GetConversations(username string) ([]*Conversation, error) {
+	rows, err := s.db.Query(
+		"SELECT uc.`conversation`, uc.`peer`, '', c.`seq` FROM `chat_user_conversation` uc "+
+			"JOIN `chat_conversation` c ON c.`conversation` = uc.`conversation` WHERE uc.`username` = ? "+
+			"UNION ALL "+
+			"SELECT '', '', r.`name`, r.`seq` FROM `chat_room_member` m JOIN `chat_room` r ON r.`name` = m.`room` WHERE m.`username` = ?",
+		username, username,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Query failed")
+	}
+	defer rows.Close()
+
+	var conversations []*Conversation
+	for rows.Next() {
+		var conversation Conversation
+		if err := rows.Scan(&conversation.ID, &conversation.Peer, &conversation.Room, &conversation.Seq); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan failed")
+		}
+		if conversation.Room != "" {
+			conversation.ID = RoomConversation(conversation.Room)
+		}
+		conversations = append(conversations, &conversation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+
+	// 数据库的排序规则和 LocalChatStorage 不一样，统一按字节排序
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].ID < conversations[j].ID
+	})
+	return conversations, nil
+}
+
+// nextConversationSeq 在事务中为会话分配下一个序号，upsert 会锁住会话行直到事务结束
+func (s *MysqlChatStorage) nextConversationSeq(tx *sql.Tx, conversation string) (int64, error) {
+	if _, err := tx.Exec(
+		"INSERT INTO `chat_conversation` (`conversation`, `seq`) VALUES (?, 1) ON DUPLICATE KEY UPDATE `seq` = `seq` + 1",
+		conversation,
+	); err != nil {
+		return 0, errors.Wrap(err, "tx.Exec failed")
+	}
+	var seq int64
+	if err := tx.QueryRow("SELECT `seq` FROM `chat_conversation` WHERE `conversation` = ?", conversation).Scan(&seq); err != nil {
+		return 0, errors.Wrap(err, "tx.QueryRow failed")
+	}
+	return seq, nil
+}
+
+// userSeq 会话中的消息在 owner 信箱中的序号，没有时为 0
+func (s *MysqlChatStorage) userSeq(q mysqlQueryer, owner string, conversation string, conversationSeq int64) (int64, error) {
+	var seq sql.NullInt64
+	if err := q.QueryRow(
+		"SELECT MIN(`seq`) FROM `chat_user_message` WHERE `owner` = ? AND `conversation` = ? AND `conversation_seq` = ?",
+		owner, conversation, conversationSeq,
+	).Scan(&seq); err != nil {
+		return 0, errors.Wrap(err, "QueryRow failed")
+	}
+	return seq.Int64, nil
+}
+
+// replyParent 校验回复的消息必须是同一个会话中的、没有被删除的消息，replyTo 是发送方信箱的序号，返回会话中的序号
+func (s *MysqlChatStorage) replyParent(tx *sql.Tx, from string, conversation string, replyTo int64) (int64, error) {
+	var parentConversation string
+	var seq int64
+	err := tx.QueryRow(
+		"SELECT c.`conversation`, c.`seq` FROM "+mysqlMessageTables+
+			" WHERE u.`owner` = ? AND u.`seq` = ? AND u.`hidden` = 0 AND c.`deleted_at` IS NULL",
+		from, replyTo,
+	).Scan(&parentConversation, &seq)
+	if err == sql.ErrNoRows {
+		return 0, ErrMessageNotFound
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "tx.QueryRow failed")
+	}
+	if parentConversation != conversation {
+		return 0, ErrMessageNotFound
+	}
+	return seq, nil
+}
+
+// peerMessage 对方看到的同一条消息，自己发给自己时返回 nil。在事务中读出，包含事务中已经做的修改
+func (s *MysqlChatStorage) peerMessage(tx *sql.Tx, username string, message *ChatMessage) (*ChatMessage, error) {
+	peer := peerOwner(username, message)
+	if peer == username {
+		return nil, nil
+	}
+	peerMessage, err := scanMessage(tx.QueryRow(
+		"SELECT "+mysqlMessageColumns+" FROM "+mysqlMessageTables+
+			" WHERE u.`owner` = ? AND u.`conversation` = ? AND u.`conversation_seq` = ? ORDER BY u.`seq` LIMIT 1",
+		peer, conversationID(message), message.ConversationSeq,
+	))
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "scanMessage failed")
+	}
+	peerMessage.Reactions = message.Reactions
+	return peerMessage, nil
+}
+
+func (s *MysqlChatStorage) queryMessages(query string, args ...interface{}) ([]*ChatMessage, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Query failed")
+	}
+	defer rows.Close()
+
+	var messages []*ChatMessage
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, errors.WithMessage(err, "scanMessage failed")
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return messages, nil
+}
+
+// mysqlMessageKey 历史版本和表情回应按消息所在的会话保存：私聊时 owner 是会话 ID、room 为空，seq 是会话中的序号；房间时 owner 为空
+func mysqlMessageKey(message *ChatMessage) (string, string, int64) {
+	if message.Room != "" {
+		return "", message.Room, message.Seq
+	}
+	return conversationID(message), "", message.ConversationSeq
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func (s *MysqlChatStorage) UpdateReadCursor(username string, peer string, room string, seq int64) (*ReadCursor, int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	// 找到序号不超过 seq 的最后一条消息
+	var row *sql.Row
+	if room != "" {
+		var member int
+		if err := tx.QueryRow(
+			"SELECT COUNT(*) FROM `chat_room_member` WHERE `room` = ? AND `username` = ?", room, username,
+		).Scan(&member); err != nil {
+			return nil, 0, errors.Wrap(err, "tx.QueryRow failed")
+		}
+		if member == 0 {
+			if err := s.checkRoom(tx, room); err != nil {
+				return nil, 0, err
+			}
+			return nil, 0, ErrNotRoomMember
+		}
+		row = tx.QueryRow(
+			"SELECT `seq`, `timestamp` FROM `chat_room_message` WHERE `room` = ? AND `seq` <= ? ORDER BY `seq` DESC LIMIT 1", room, seq,
+		)
+	} else {
+		row = tx.QueryRow(
+			"SELECT u.`seq`, c.`timestamp` FROM "+mysqlMessageTables+" WHERE u.`owner` = ? AND u.`seq` <= ? ORDER BY u.`seq` DESC LIMIT 1",
+			username, seq,
+		)
+	}
+	cursor := &ReadCursor{Peer: peer, Room: room}
+	if err := row.Scan(&cursor.Seq, &cursor.Timestamp); err != nil && err != sql.ErrNoRows {
+		return nil, 0, errors.Wrap(err, "QueryRow failed")
+	}
+
+	// 锁住已读位置，同一个会话并发更新时串行
+	prev := &ReadCursor{Peer: peer, Room: room}
+	if err := tx.QueryRow(
+		"SELECT `seq`, `timestamp` FROM `chat_read_cursor` WHERE `username` = ? AND `peer` = ? AND `room` = ? FOR UPDATE",
+		username, peer, room,
+	).Scan(&prev.Seq, &prev.Timestamp); err != nil && err != sql.ErrNoRows {
+		return nil, 0, errors.Wrap(err, "tx.QueryRow failed")
+	}
+	if cursor.Seq <= prev.Seq {
+		return prev, prev.Seq, nil
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO `chat_read_cursor` (`username`, `peer`, `room`, `seq`, `timestamp`) VALUES (?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE `seq` = VALUES(`seq`), `timestamp` = VALUES(`timestamp`)",
+		username, peer, room, cursor.Seq, cursor.Timestamp,
+	); err != nil {
+		return nil, 0, errors.Wrap(err, "tx.Exec failed")
+	}
+
+	// 对方信箱中对应的序号，自己发给自己的旧消息可能有两个序号，取前一个
+	if peer != "" {
+		if err := tx.QueryRow(
+			"SELECT p.`seq` FROM `chat_user_message` u JOIN `chat_user_message` p "+
+				"ON p.`owner` = ? AND p.`conversation` = u.`conversation` AND p.`conversation_seq` = u.`conversation_seq` "+
+				"WHERE u.`owner` = ? AND u.`conversation` = ? AND u.`seq` <= ? ORDER BY u.`seq` DESC, p.`seq` ASC LIMIT 1",
+			peer, username, DirectConversation(username, peer), cursor.Seq,
+		).Scan(&cursor.PeerSeq); err != nil && err != sql.ErrNoRows {
+			return nil, 0, errors.Wrap(err, "tx.QueryRow failed")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, errors.Wrap(err, "tx.Commit failed")
+	}
+	return cursor, prev.Seq, nil
+}
+
+func (s *MysqlChatStorage) GetUnreadCounts(username string) ([]*UnreadCount, error) {
+	rows, err := s.db.Query(
+		"SELECT c.`from`, '', COUNT(*) FROM "+mysqlMessageTables+" "+
+			"LEFT JOIN `chat_read_cursor` rc ON rc.`username` = u.`owner` AND rc.`peer` = c.`from` AND rc.`room` = '' "+
+			"WHERE u.`owner` = ? AND c.`from` != ? AND u.`seq` > IFNULL(rc.`seq`, 0) AND c.`deleted_at` IS NULL AND u.`hidden` = 0 "+
+			"GROUP BY c.`from` "+
+			"UNION ALL "+
+			"SELECT '', rm.`room`, COUNT(*) FROM `chat_room_member` mem "+
+			"JOIN `chat_room_message` rm ON rm.`room` = mem.`room` "+
+			"LEFT JOIN `chat_read_cursor` c ON c.`username` = mem.`username` AND c.`peer` = '' AND c.`room` = rm.`room` "+
+			"LEFT JOIN `chat_room_hidden_message` h ON h.`room` = rm.`room` AND h.`username` = mem.`username` AND h.`seq` = rm.`seq` "+
+			"WHERE mem.`username` = ? AND rm.`from` != ? AND rm.`seq` > IFNULL(c.`seq`, 0) AND rm.`deleted_at` IS NULL AND h.`seq` IS NULL "+
+			"GROUP BY rm.`room`",
+		username, username, username, username,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Query failed")
+	}
+	defer rows.Close()
+
+	var counts []*UnreadCount
+	for rows.Next() {
+		var count UnreadCount
+		if err := rows.Scan(&count.Peer, &count.Room, &count.Count); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan failed")
+		}
+		counts = append(counts, &count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+
+	return counts, nil
+}
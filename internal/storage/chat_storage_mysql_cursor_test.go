@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMysqlChatStorageUpdateReadCursor(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		name    string
+		prevSeq int64
+		// 对方信箱中的序号，0 表示查不到
+		peerSeq     int64
+		wantSeq     int64
+		wantPrevSeq int64
+	}{
+		{name: "advance", prevSeq: 1, peerSeq: 3, wantSeq: 4, wantPrevSeq: 1},
+		{name: "advance without peer message", prevSeq: 0, peerSeq: 0, wantSeq: 4, wantPrevSeq: 0},
+		{name: "not advanced", prevSeq: 4, wantSeq: 4, wantPrevSeq: 4},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New failed: %v", err)
+			}
+			defer db.Close()
+			s := &MysqlChatStorage{db: db}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT u.`seq`, c.`timestamp` FROM")).
+				WithArgs("bob", 10).
+				WillReturnRows(sqlmock.NewRows([]string{"seq", "timestamp"}).AddRow(4, now))
+			prevRows := sqlmock.NewRows([]string{"seq", "timestamp"})
+			if c.prevSeq != 0 {
+				prevRows.AddRow(c.prevSeq, now)
+			}
+			mock.ExpectQuery(regexp.QuoteMeta("FROM `chat_read_cursor`")).
+				WithArgs("bob", "alice", "").
+				WillReturnRows(prevRows)
+			if c.wantSeq == c.wantPrevSeq {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `chat_read_cursor`")).
+					WithArgs("bob", "alice", "", 4, now).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				peerRows := sqlmock.NewRows([]string{"seq"})
+				if c.peerSeq != 0 {
+					peerRows.AddRow(c.peerSeq)
+				}
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT p.`seq` FROM `chat_user_message` u JOIN `chat_user_message` p")).
+					WithArgs("alice", "bob", DirectConversation("bob", "alice"), 4).
+					WillReturnRows(peerRows)
+				mock.ExpectCommit()
+			}
+
+			cursor, prevSeq, err := s.UpdateReadCursor("bob", "alice", "", 10)
+			if err != nil {
+				t.Fatalf("UpdateReadCursor failed: %v", err)
+			}
+			if cursor.Seq != c.wantSeq || prevSeq != c.wantPrevSeq || cursor.PeerSeq != c.peerSeq {
+				t.Fatalf("cursor = (%d, %d), prev = %d, want (%d, %d), prev = %d",
+					cursor.Seq, cursor.PeerSeq, prevSeq, c.wantSeq, c.peerSeq, c.wantPrevSeq)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("ExpectationsWereMet failed: %v", err)
+			}
+		})
+	}
+}
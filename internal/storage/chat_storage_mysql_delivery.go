@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func (s *MysqlChatStorage) GetUndelivered(username string) ([]*ChatMessage, error) {
+	var seq int64
+	if err := s.db.QueryRow(
+		"SELECT `seq` FROM `chat_delivery_cursor` WHERE `username` = ?", username,
+	).Scan(&seq); err != nil && err != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "db.QueryRow failed")
+	}
+	return s.GetMessageByUser(username, seq+1)
+}
+
+func (s *MysqlChatStorage) MarkDelivered(username string, seq int64) ([]*ChatMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	var last int64
+	if err := tx.QueryRow("SELECT `seq` FROM `chat_user_seq` WHERE `username` = ?", username).Scan(&last); err != nil && err != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "tx.QueryRow failed")
+	}
+	if seq > last {
+		seq = last
+	}
+
+	// 先插入再加锁，同一个用户并发更新时串行
+	if _, err := tx.Exec("INSERT IGNORE INTO `chat_delivery_cursor` (`username`, `seq`) VALUES (?, 0)", username); err != nil {
+		return nil, errors.Wrap(err, "tx.Exec failed")
+	}
+	var prev int64
+	if err := tx.QueryRow(
+		"SELECT `seq` FROM `chat_delivery_cursor` WHERE `username` = ? FOR UPDATE", username,
+	).Scan(&prev); err != nil {
+		return nil, errors.Wrap(err, "tx.QueryRow failed")
+	}
+	if seq <= prev {
+		return nil, nil
+	}
+	if _, err := tx.Exec("UPDATE `chat_delivery_cursor` SET `seq` = ? WHERE `username` = ?", seq, username); err != nil {
+		return nil, errors.Wrap(err, "tx.Exec failed")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "tx.Commit failed")
+	}
+
+	// 新投递的消息在发送方信箱中的样子，d 是接收方的索引
+	messages, err := s.queryMessages(
+		"SELECT "+mysqlMessageColumns+" FROM "+mysqlMessageTables+
+			" JOIN `chat_user_message` d ON d.`conversation` = c.`conversation` AND d.`conversation_seq` = c.`seq`"+
+			" WHERE d.`owner` = ? AND d.`seq` > ? AND d.`seq` <= ? AND c.`from` != ? AND u.`owner` = c.`from` ORDER BY d.`seq`",
+		username, prev, seq, username,
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "queryMessages failed")
+	}
+	return messages, nil
+}
@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func (s *MysqlChatStorage) EditMessage(username string, seq int64, content string) (*ChatMessage, *ChatMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	message, err := s.lockMessage(tx, username, seq)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message.From != username {
+		return nil, nil, ErrNotMessageSender
+	}
+	if err := s.editMessage(tx, message, content, time.Now()); err != nil {
+		return nil, nil, err
+	}
+	peerMessage, err := s.peerMessage(tx, username, message)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "peerMessage failed")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, errors.Wrap(err, "tx.Commit failed")
+	}
+	if err := s.attachParents(username, "", []*ChatMessage{message}); err != nil {
+		return nil, nil, errors.WithMessage(err, "attachParents failed")
+	}
+	if peerMessage != nil {
+		if err := s.attachParents(peerOwner(username, message), "", []*ChatMessage{peerMessage}); err != nil {
+			return nil, nil, errors.WithMessage(err, "attachParents failed")
+		}
+	}
+	return message, peerMessage, nil
+}
+
+func (s *MysqlChatStorage) EditRoomMessage(room string, username string, seq int64, content string) (*ChatMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	message, err := s.lockRoomMessage(tx, room, username, seq)
+	if err != nil {
+		return nil, err
+	}
+	if message.From != username {
+		return nil, ErrNotMessageSender
+	}
+	if err := s.editMessage(tx, message, content, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "tx.Commit failed")
+	}
+	if err := s.attachParents("", room, []*ChatMessage{message}); err != nil {
+		return nil, errors.WithMessage(err, "attachParents failed")
+	}
+	return message, nil
+}
+
+func (s *MysqlChatStorage) DeleteMessage(username string, seq int64, forEveryone bool) (*ChatMessage, *ChatMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	message, err := s.lockMessage(tx, username, seq)
+	if err != nil {
+		return nil, nil, err
+	}
+	var peerMessage *ChatMessage
+	if !forEveryone {
+		if _, err := tx.Exec("UPDATE `chat_user_message` SET `hidden` = 1 WHERE `owner` = ? AND `seq` = ?", username, seq); err != nil {
+			return nil, nil, errors.Wrap(err, "tx.Exec failed")
+		}
+		message.Hidden = true
+	} else {
+		if message.From != username {
+			return nil, nil, ErrNotMessageSender
+		}
+		if err := s.deleteMessage(tx, message, time.Now()); err != nil {
+			return nil, nil, err
+		}
+		if peerMessage, err = s.peerMessage(tx, username, message); err != nil {
+			return nil, nil, errors.WithMessage(err, "peerMessage failed")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, errors.Wrap(err, "tx.Commit failed")
+	}
+	return message, peerMessage, nil
+}
+
+func (s *MysqlChatStorage) DeleteRoomMessage(room string, username string, seq int64, forEveryone bool) (*ChatMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	message, err := s.lockRoomMessage(tx, room, username, seq)
+	if err != nil {
+		return nil, err
+	}
+	if !forEveryone {
+		if _, err := tx.Exec(
+			"INSERT IGNORE INTO `chat_room_hidden_message` (`room`, `username`, `seq`) VALUES (?, ?, ?)", room, username, seq,
+		); err != nil {
+			return nil, errors.Wrap(err, "tx.Exec failed")
+		}
+	} else {
+		if message.From != username {
+			return nil, ErrNotMessageSender
+		}
+		if err := s.deleteMessage(tx, message, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "tx.Commit failed")
+	}
+	return message, nil
+}
+
+// lockMessage 锁住 owner 信箱中的消息，返回的消息带上表情回应
+func (s *MysqlChatStorage) lockMessage(tx *sql.Tx, owner string, seq int64) (*ChatMessage, error) {
+	message, err := scanMessage(tx.QueryRow(
+		"SELECT "+mysqlMessageColumns+" FROM "+mysqlMessageTables+
+			" WHERE u.`owner` = ? AND u.`seq` = ? AND u.`hidden` = 0 AND c.`deleted_at` IS NULL FOR UPDATE",
+		owner, seq,
+	))
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "scanMessage failed")
+	}
+
+	key, room, keySeq := mysqlMessageKey(message)
+	reactions, err := s.queryReactions(tx, key, room, keySeq, keySeq)
+	if err != nil {
+		return nil, errors.WithMessage(err, "queryReactions failed")
+	}
+	message.Reactions = reactions[keySeq]
+	return message, nil
+}
+
+func (s *MysqlChatStorage) lockRoomMessage(tx *sql.Tx, room string, username string, seq int64) (*ChatMessage, error) {
+	var member int
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM `chat_room_member` WHERE `room` = ? AND `username` = ?", room, username,
+	).Scan(&member); err != nil {
+		return nil, errors.Wrap(err, "tx.QueryRow failed")
+	}
+	if member == 0 {
+		if err := s.checkRoom(tx, room); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotRoomMember
+	}
+
+	message, err := scanMessage(tx.QueryRow(
+		"SELECT "+mysqlRoomMessageColumns+" FROM `chat_room_message` m "+
+			"WHERE `room` = ? AND `seq` = ? AND `deleted_at` IS NULL AND NOT EXISTS ("+
+			"SELECT 1 FROM `chat_room_hidden_message` h WHERE h.`room` = m.`room` AND h.`username` = ? AND h.`seq` = m.`seq`"+
+			") FOR UPDATE",
+		room, seq, username,
+	))
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "scanMessage failed")
+	}
+	message.Room = room
+
+	reactions, err := s.queryReactions(tx, "", room, seq, seq)
+	if err != nil {
+		return nil, errors.WithMessage(err, "queryReactions failed")
+	}
+	message.Reactions = reactions[seq]
+	return message, nil
+}
+
+// editMessage 当前内容保存为历史版本
+func (s *MysqlChatStorage) editMessage(tx *sql.Tx, message *ChatMessage, content string, now time.Time) error {
+	written := message.Timestamp
+	if message.EditedAt != nil {
+		written = *message.EditedAt
+	}
+	owner, room, seq := mysqlMessageKey(message)
+	if _, err := tx.Exec(
+		"INSERT INTO `chat_message_revision` (`owner`, `room`, `seq`, `timestamp`, `content`) VALUES (?, ?, ?, ?, ?)",
+		owner, room, seq, written, message.Content,
+	); err != nil {
+		return errors.Wrap(err, "tx.Exec failed")
+	}
+	if err := s.updateMessage(tx, message, "`content` = ?, `edited_at` = ?", content, now); err != nil {
+		return err
+	}
+	message.Content = content
+	message.EditedAt = &now
+	return nil
+}
+
+// deleteMessage 消息变成墓碑，历史版本和表情回应一起删除
+func (s *MysqlChatStorage) deleteMessage(tx *sql.Tx, message *ChatMessage, now time.Time) error {
+	owner, room, seq := mysqlMessageKey(message)
+	for _, table := range []string{"chat_message_revision", "chat_message_reaction"} {
+		if _, err := tx.Exec(
+			"DELETE FROM `"+table+"` WHERE `owner` = ? AND `room` = ? AND `seq` = ?", owner, room, seq,
+		); err != nil {
+			return errors.Wrap(err, "tx.Exec failed")
+		}
+	}
+	if err := s.updateMessage(tx, message, "`content` = '', `deleted_at` = ?", now); err != nil {
+		return err
+	}
+	message.Content = ""
+	message.Reactions = nil
+	message.DeletedAt = &now
+	return nil
+}
+
+// updateMessage 私聊消息修改会话中的那一份
+func (s *MysqlChatStorage) updateMessage(tx *sql.Tx, message *ChatMessage, set string, args ...interface{}) error {
+	query := "UPDATE `chat_conversation_message` SET " + set + " WHERE `conversation` = ? AND `seq` = ?"
+	args = append(args, conversationID(message), message.ConversationSeq)
+	if message.Room != "" {
+		query = "UPDATE `chat_room_message` SET " + set + " WHERE `room` = ? AND `seq` = ?"
+		args[len(args)-2] = message.Room
+		args[len(args)-1] = message.Seq
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return errors.Wrap(err, "tx.Exec failed")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+func (s *MysqlChatStorage) GetHistory(username string, peer string, room string, before int64, after int64, limit int) ([]*ChatMessage, error) {
+	// 有 before 或者都为 0 时倒序取最后 limit 条，再翻转成升序
+	latest := before > 0 || after == 0
+	order := "ASC"
+	if latest {
+		order = "DESC"
+	}
+
+	var messages []*ChatMessage
+	var err error
+	if room != "" {
+		if err := s.checkRoomMember(room, username); err != nil {
+			return nil, err
+		}
+		query := "SELECT " + mysqlRoomMessageColumns + " FROM `chat_room_message` m WHERE `room` = ? AND `seq` > ?"
+		args := []interface{}{room, after}
+		if before > 0 {
+			query += " AND `seq` < ?"
+			args = append(args, before)
+		}
+		query += " AND NOT EXISTS (" +
+			"SELECT 1 FROM `chat_room_hidden_message` h WHERE h.`room` = m.`room` AND h.`username` = ? AND h.`seq` = m.`seq`" +
+			") ORDER BY `seq` " + order + mysqlLimit(limit)
+		args = append(args, username)
+		messages, err = s.queryMessages(query, args...)
+		for _, message := range messages {
+			message.Room = room
+		}
+	} else {
+		// (owner, conversation, conversation_seq) 上有索引，按会话中的序号范围查找
+		query := "SELECT " + mysqlMessageColumns + " FROM " + mysqlMessageTables +
+			" WHERE u.`owner` = ? AND u.`conversation` = ? AND u.`conversation_seq` > ? AND u.`hidden` = 0"
+		args := []interface{}{username, DirectConversation(username, peer), after}
+		if before > 0 {
+			query += " AND u.`conversation_seq` < ?"
+			args = append(args, before)
+		}
+		query += " ORDER BY u.`conversation_seq` " + order + mysqlLimit(limit)
+		messages, err = s.queryMessages(query, args...)
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "queryMessages failed")
+	}
+	if latest {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	owner := ""
+	if room == "" {
+		owner = username
+	}
+	if err := s.attachReactions(messages); err != nil {
+		return nil, errors.WithMessage(err, "attachReactions failed")
+	}
+	if err := s.attachParents(owner, room, messages); err != nil {
+		return nil, errors.WithMessage(err, "attachParents failed")
+	}
+	return messages, nil
+}
+
+func mysqlLimit(limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d", limit)
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// mysqlMigration 一个版本的结构变更。DDL 会隐式提交事务，每一条都要能重复执行，中途失败后重新执行时跳过已经完成的
+// DML 在一个事务中执行，和版本号一起提交，要么全部生效要么都不生效
+type mysqlMigration struct {
+	DDL []mysqlDDL
+	DML []string
+}
+
+// mysqlDDL Column 不为空时表中已经有这一列就跳过，Index 同理。ALTER TABLE 一次加上的列和索引是一起生效的，检查其中一个就够了
+// 没有 Column 和 Index 的语句本身就可以重复执行，比如 CREATE TABLE IF NOT EXISTS 和 MODIFY
+type mysqlDDL struct {
+	Table  string
+	Column string
+	Index  string
+	Stmt   string
+}
+
+// 数据库结构变更，按版本顺序追加，已发布的版本不能修改
+var mysqlMigrations = []*mysqlMigration{
+	{
+		DDL: []mysqlDDL{
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_user` (" +
+				"`username` VARCHAR(64) NOT NULL," +
+				"`password_hash` VARCHAR(128) NOT NULL," +
+				"`created_at` DATETIME NOT NULL," +
+				"PRIMARY KEY (`username`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_revoked_token` (" +
+				"`id` VARCHAR(64) NOT NULL," +
+				"`expires_at` DATETIME NOT NULL," +
+				"PRIMARY KEY (`id`)," +
+				"KEY `idx_expires_at` (`expires_at`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			// 每个用户的信箱序号，私聊消息在 chat_user_message 中的序号由它分配
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_user_seq` (" +
+				"`username` VARCHAR(64) NOT NULL," +
+				"`seq` BIGINT NOT NULL," +
+				"PRIMARY KEY (`username`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			// 私聊消息在会话中只保存一份，序号属于会话，reply_to 也是会话中的序号，0 表示不是回复
+			// 没有 msg_id 的消息为 NULL，不参与唯一约束。ft_content 是搜索用的全文索引，ngram 分词按相邻两个字切分，中文没有空格也能匹配
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_conversation` (" +
+				"`conversation` VARCHAR(160) NOT NULL," +
+				"`seq` BIGINT NOT NULL DEFAULT 0," +
+				"PRIMARY KEY (`conversation`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_conversation_message` (" +
+				"`id` BIGINT NOT NULL AUTO_INCREMENT," +
+				"`conversation` VARCHAR(160) NOT NULL," +
+				"`seq` BIGINT NOT NULL," +
+				"`timestamp` DATETIME(6) NOT NULL," +
+				"`msg_id` VARCHAR(64) NULL," +
+				"`from` VARCHAR(64) NOT NULL," +
+				"`to` VARCHAR(64) NOT NULL," +
+				"`content` TEXT NOT NULL," +
+				"`edited_at` DATETIME(6) NULL," +
+				"`deleted_at` DATETIME(6) NULL," +
+				"`reply_to` BIGINT NOT NULL DEFAULT 0," +
+				"`expires_at` DATETIME(6) NULL," +
+				"PRIMARY KEY (`id`)," +
+				"UNIQUE KEY `uk_conversation_seq` (`conversation`, `seq`)," +
+				"UNIQUE KEY `uk_conversation_from_msg_id` (`conversation`, `from`, `msg_id`)," +
+				"KEY `idx_conversation_reply_to` (`conversation`, `reply_to`)," +
+				"KEY `idx_expires_at` (`expires_at`)," +
+				"KEY `idx_timestamp` (`timestamp`)," +
+				"FULLTEXT KEY `ft_content` (`content`) WITH PARSER ngram" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			// 每个用户的私聊消息索引，seq 是 chat_user_seq 分配的信箱序号，hidden 表示用户自己删除了这条消息
+			// 自己发给自己的消息也只有一项，会话中的一条消息在一个信箱中只有一个序号
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_user_message` (" +
+				"`owner` VARCHAR(64) NOT NULL," +
+				"`seq` BIGINT NOT NULL," +
+				"`conversation` VARCHAR(160) NOT NULL," +
+				"`conversation_seq` BIGINT NOT NULL," +
+				"`hidden` TINYINT(1) NOT NULL DEFAULT 0," +
+				"PRIMARY KEY (`owner`, `seq`)," +
+				"UNIQUE KEY `uk_owner_conversation_seq` (`owner`, `conversation`, `conversation_seq`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			// 每个用户参与的私聊会话
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_user_conversation` (" +
+				"`username` VARCHAR(64) NOT NULL," +
+				"`conversation` VARCHAR(160) NOT NULL," +
+				"`peer` VARCHAR(64) NOT NULL," +
+				"PRIMARY KEY (`username`, `conversation`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_room` (" +
+				"`name` VARCHAR(64) NOT NULL," +
+				"`owner` VARCHAR(64) NOT NULL," +
+				"`seq` BIGINT NOT NULL DEFAULT 0," +
+				"`created_at` DATETIME NOT NULL," +
+				"PRIMARY KEY (`name`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_room_member` (" +
+				"`room` VARCHAR(64) NOT NULL," +
+				"`username` VARCHAR(64) NOT NULL," +
+				"`joined_at` DATETIME NOT NULL," +
+				"PRIMARY KEY (`room`, `username`)," +
+				"KEY `idx_username` (`username`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			// 列和索引同 chat_conversation_message，房间消息没有接收方
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_room_message` (" +
+				"`id` BIGINT NOT NULL AUTO_INCREMENT," +
+				"`room` VARCHAR(64) NOT NULL," +
+				"`seq` BIGINT NOT NULL," +
+				"`timestamp` DATETIME(6) NOT NULL," +
+				"`msg_id` VARCHAR(64) NULL," +
+				"`from` VARCHAR(64) NOT NULL," +
+				"`content` TEXT NOT NULL," +
+				"`edited_at` DATETIME(6) NULL," +
+				"`deleted_at` DATETIME(6) NULL," +
+				"`reply_to` BIGINT NOT NULL DEFAULT 0," +
+				"`expires_at` DATETIME(6) NULL," +
+				"PRIMARY KEY (`id`)," +
+				"UNIQUE KEY `uk_room_seq` (`room`, `seq`)," +
+				"UNIQUE KEY `uk_room_from_msg_id` (`room`, `from`, `msg_id`)," +
+				"KEY `idx_room_reply_to` (`room`, `reply_to`)," +
+				"KEY `idx_expires_at` (`expires_at`)," +
+				"KEY `idx_timestamp` (`timestamp`)," +
+				"FULLTEXT KEY `ft_content` (`content`) WITH PARSER ngram" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_room_hidden_message` (" +
+				"`room` VARCHAR(64) NOT NULL," +
+				"`username` VARCHAR(64) NOT NULL," +
+				"`seq` BIGINT NOT NULL," +
+				"PRIMARY KEY (`room`, `username`, `seq`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			// 私聊时 owner 是会话 ID、room 为空，房间时 owner 为空，seq 是会话中的序号
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_message_revision` (" +
+				"`id` BIGINT NOT NULL AUTO_INCREMENT," +
+				"`owner` VARCHAR(160) NOT NULL DEFAULT ''," +
+				"`room` VARCHAR(64) NOT NULL DEFAULT ''," +
+				"`seq` BIGINT NOT NULL," +
+				"`timestamp` DATETIME(6) NOT NULL," +
+				"`content` TEXT NOT NULL," +
+				"PRIMARY KEY (`id`)," +
+				"KEY `idx_owner_room_seq` (`owner`, `room`, `seq`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			// owner 和 room 同 chat_message_revision。id 的顺序就是回应的顺序
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_message_reaction` (" +
+				"`id` BIGINT NOT NULL AUTO_INCREMENT," +
+				"`owner` VARCHAR(160) NOT NULL DEFAULT ''," +
+				"`room` VARCHAR(64) NOT NULL DEFAULT ''," +
+				"`seq` BIGINT NOT NULL," +
+				"`emoji` VARCHAR(64) NOT NULL," +
+				"`username` VARCHAR(64) NOT NULL," +
+				"PRIMARY KEY (`id`)," +
+				"UNIQUE KEY `uk_owner_room_seq_emoji_username` (`owner`, `room`, `seq`, `emoji`, `username`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			// 私聊时 room 为空，房间时 peer 为空
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_read_cursor` (" +
+				"`username` VARCHAR(64) NOT NULL," +
+				"`peer` VARCHAR(64) NOT NULL DEFAULT ''," +
+				"`room` VARCHAR(64) NOT NULL DEFAULT ''," +
+				"`seq` BIGINT NOT NULL," +
+				"`timestamp` DATETIME(6) NOT NULL," +
+				"PRIMARY KEY (`username`, `peer`, `room`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			// 投递位置，信箱中之后的消息还在离线队列中
+			{Stmt: "CREATE TABLE IF NOT EXISTS `chat_delivery_cursor` (" +
+				"`username` VARCHAR(64) NOT NULL," +
+				"`seq` BIGINT NOT NULL DEFAULT 0," +
+				"PRIMARY KEY (`username`)" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+		},
+	},
+}
+
+const (
+	// mysqlMigrationLock 多个实例同时启动时只有一个执行结构变更，其他的等它完成后看到最新的版本号
+	mysqlMigrationLock        = "chat_schema_migration"
+	mysqlMigrationLockTimeout = 600
+)
+
+func migrateMysql(db *sql.DB) error {
+	ctx := context.Background()
+	// GET_LOCK 属于连接，加锁、变更和释放锁都要在同一个连接上
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "db.Conn failed")
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", mysqlMigrationLock, mysqlMigrationLockTimeout).Scan(&locked); err != nil {
+		return errors.Wrap(err, "conn.QueryRowContext failed")
+	}
+	if locked.Int64 != 1 {
+		return errors.Errorf("get lock [%s] timeout", mysqlMigrationLock)
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", mysqlMigrationLock)
+
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `chat_schema_migration` ("+
+		"`version` INT NOT NULL,"+
+		"`applied_at` DATETIME NOT NULL,"+
+		"PRIMARY KEY (`version`)"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"); err != nil {
+		return errors.Wrap(err, "conn.ExecContext failed")
+	}
+
+	var version int
+	if err := conn.QueryRowContext(ctx, "SELECT IFNULL(MAX(`version`), 0) FROM `chat_schema_migration`").Scan(&version); err != nil {
+		return errors.Wrap(err, "conn.QueryRowContext failed")
+	}
+
+	for i := version; i < len(mysqlMigrations); i++ {
+		if err := applyMysqlMigration(ctx, conn, i+1, mysqlMigrations[i]); err != nil {
+			return errors.WithMessagef(err, "migration [%d] failed", i+1)
+		}
+	}
+
+	return nil
+}
+
+func applyMysqlMigration(ctx context.Context, conn *sql.Conn, version int, migration *mysqlMigration) error {
+	for _, ddl := range migration.DDL {
+		applied, err := mysqlDDLApplied(ctx, conn, ddl)
+		if err != nil {
+			return errors.WithMessage(err, "mysqlDDLApplied failed")
+		}
+		if applied {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, ddl.Stmt); err != nil {
+			return errors.Wrap(err, "conn.ExecContext failed")
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "conn.BeginTx failed")
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range migration.DML {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrap(err, "tx.ExecContext failed")
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO `chat_schema_migration` (`version`, `applied_at`) VALUES (?, ?)", version, time.Now()); err != nil {
+		return errors.Wrap(err, "tx.ExecContext failed")
+	}
+	return errors.Wrap(tx.Commit(), "tx.Commit failed")
+}
+
+// mysqlDDLApplied 根据 information_schema 判断列或者索引是否已经存在
+func mysqlDDLApplied(ctx context.Context, conn *sql.Conn, ddl mysqlDDL) (bool, error) {
+	var query string
+	var name string
+	switch {
+	case ddl.Column != "":
+		query = "SELECT COUNT(*) FROM information_schema.`COLUMNS` " +
+			"WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = ? AND `COLUMN_NAME` = ?"
+		name = ddl.Column
+	case ddl.Index != "":
+		query = "SELECT COUNT(*) FROM information_schema.`STATISTICS` " +
+			"WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = ? AND `INDEX_NAME` = ?"
+		name = ddl.Index
+	default:
+		return false, nil
+	}
+
+	var count int
+	if err := conn.QueryRowContext(ctx, query, ddl.Table, name).Scan(&count); err != nil {
+		return false, errors.Wrap(err, "conn.QueryRowContext failed")
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+)
+
+// expectMysqlMigration 按 applyMysqlMigration 的顺序设置期望，applied 表示带检查的 DDL 已经执行过
+func expectMysqlMigration(mock sqlmock.Sqlmock, version int, migration *mysqlMigration, applied bool) {
+	for _, ddl := range migration.DDL {
+		if ddl.Column != "" || ddl.Index != "" {
+			name := ddl.Column
+			if name == "" {
+				name = ddl.Index
+			}
+			count := 0
+			if applied {
+				count = 1
+			}
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM information_schema.")).
+				WithArgs(ddl.Table, name).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+			if applied {
+				continue
+			}
+		}
+		mock.ExpectExec(regexp.QuoteMeta(ddl.Stmt)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectBegin()
+	for _, stmt := range migration.DML {
+		mock.ExpectExec(regexp.QuoteMeta(stmt)).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `chat_schema_migration`")).
+		WithArgs(version, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func expectMysqlMigrationLock(mock sqlmock.Sqlmock, version int) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).
+		WithArgs(mysqlMigrationLock, mysqlMigrationLockTimeout).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS `chat_schema_migration`")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT IFNULL(MAX(`version`), 0) FROM `chat_schema_migration`")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+}
+
+func expectMysqlMigrationUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT RELEASE_LOCK(?)")).
+		WithArgs(mysqlMigrationLock).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// testMysqlMigrations 测试跳过已经执行的 DDL 和 DML 回滚，和实际的结构无关
+var testMysqlMigrations = []*mysqlMigration{
+	{
+		DDL: []mysqlDDL{{Stmt: "CREATE TABLE IF NOT EXISTS `chat_test` (`id` BIGINT NOT NULL)"}},
+	},
+	{
+		DDL: []mysqlDDL{{Table: "chat_test", Column: "value", Stmt: "ALTER TABLE `chat_test` ADD COLUMN `value` BIGINT NOT NULL DEFAULT 0"}},
+		DML: []string{"UPDATE `chat_test` SET `value` = `id`"},
+	},
+}
+
+func TestMigrateMysql(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		migrations []*mysqlMigration
+		expect     func(mock sqlmock.Sqlmock, migrations []*mysqlMigration)
+		wantErr    bool
+	}{
+		{
+			name:       "up to date",
+			migrations: mysqlMigrations,
+			expect: func(mock sqlmock.Sqlmock, migrations []*mysqlMigration) {
+				expectMysqlMigrationLock(mock, len(migrations))
+				expectMysqlMigrationUnlock(mock)
+			},
+		},
+		{
+			name:       "fresh database",
+			migrations: mysqlMigrations,
+			expect: func(mock sqlmock.Sqlmock, migrations []*mysqlMigration) {
+				expectMysqlMigrationLock(mock, 0)
+				for i, migration := range migrations {
+					expectMysqlMigration(mock, i+1, migration, false)
+				}
+				expectMysqlMigrationUnlock(mock)
+			},
+		},
+		{
+			// 上次在 DDL 执行之后、版本号提交之前退出，重新执行时跳过已经存在的列
+			name:       "resume after partial ddl",
+			migrations: testMysqlMigrations,
+			expect: func(mock sqlmock.Sqlmock, migrations []*mysqlMigration) {
+				expectMysqlMigrationLock(mock, 1)
+				expectMysqlMigration(mock, 2, migrations[1], true)
+				expectMysqlMigrationUnlock(mock)
+			},
+		},
+		{
+			name:       "lock timeout",
+			migrations: mysqlMigrations,
+			expect: func(mock sqlmock.Sqlmock, migrations []*mysqlMigration) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).
+					WithArgs(mysqlMigrationLock, mysqlMigrationLockTimeout).
+					WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(0))
+			},
+			wantErr: true,
+		},
+		{
+			name:       "lock error",
+			migrations: mysqlMigrations,
+			expect: func(mock sqlmock.Sqlmock, migrations []*mysqlMigration) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).
+					WithArgs(mysqlMigrationLock, mysqlMigrationLockTimeout).
+					WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(nil))
+			},
+			wantErr: true,
+		},
+		{
+			// DML 失败时回滚，版本号不前进，锁照常释放
+			name:       "dml failure rolls back",
+			migrations: testMysqlMigrations,
+			expect: func(mock sqlmock.Sqlmock, migrations []*mysqlMigration) {
+				migration := migrations[1]
+				expectMysqlMigrationLock(mock, 1)
+				for _, ddl := range migration.DDL {
+					mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM information_schema.")).
+						WithArgs(ddl.Table, ddl.Column).
+						WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+					mock.ExpectExec(regexp.QuoteMeta(ddl.Stmt)).WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(migration.DML[0])).WillReturnError(errors.New("deadlock"))
+				mock.ExpectRollback()
+				expectMysqlMigrationUnlock(mock)
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			defer func(migrations []*mysqlMigration) { mysqlMigrations = migrations }(mysqlMigrations)
+			mysqlMigrations = c.migrations
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New failed: %v", err)
+			}
+			defer db.Close()
+			c.expect(mock, c.migrations)
+
+			err = migrateMysql(db)
+			if c.wantErr != (err != nil) {
+				t.Fatalf("migrateMysql error = %v, want error %v", err, c.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("ExpectationsWereMet failed: %v", err)
+			}
+		})
+	}
+}
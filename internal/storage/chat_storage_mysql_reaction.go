@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func (s *MysqlChatStorage) ReactMessage(username string, seq int64, emoji string, remove bool) (*ChatMessage, *ChatMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	message, err := s.lockMessage(tx, username, seq)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.react(tx, message, username, emoji, remove); err != nil {
+		return nil, nil, err
+	}
+	peerMessage, err := s.peerMessage(tx, username, message)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "peerMessage failed")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, errors.Wrap(err, "tx.Commit failed")
+	}
+	return message, peerMessage, nil
+}
+
+func (s *MysqlChatStorage) ReactRoomMessage(room string, username string, seq int64, emoji string, remove bool) (*ChatMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	message, err := s.lockRoomMessage(tx, room, username, seq)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.react(tx, message, username, emoji, remove); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "tx.Commit failed")
+	}
+	return message, nil
+}
+
+// react 修改消息的回应，并读出修改后的全部回应
+func (s *MysqlChatStorage) react(tx *sql.Tx, message *ChatMessage, username string, emoji string, remove bool) error {
+	query := "INSERT IGNORE INTO `chat_message_reaction` (`owner`, `room`, `seq`, `emoji`, `username`) VALUES (?, ?, ?, ?, ?)"
+	if remove {
+		query = "DELETE FROM `chat_message_reaction` WHERE `owner` = ? AND `room` = ? AND `seq` = ? AND `emoji` = ? AND `username` = ?"
+	}
+	owner, room, seq := mysqlMessageKey(message)
+	if _, err := tx.Exec(query, owner, room, seq, emoji, username); err != nil {
+		return errors.Wrap(err, "tx.Exec failed")
+	}
+
+	reactions, err := s.queryReactions(tx, owner, room, seq, seq)
+	if err != nil {
+		return errors.WithMessage(err, "queryReactions failed")
+	}
+	message.Reactions = reactions[seq]
+	return nil
+}
+
+// attachReactions 读出一批消息的回应，私聊消息可能属于不同的会话，每个会话读一次
+func (s *MysqlChatStorage) attachReactions(messages []*ChatMessage) error {
+	type group struct {
+		owner    string
+		room     string
+		minSeq   int64
+		maxSeq   int64
+		messages []*ChatMessage
+	}
+	var groups []*group
+	index := map[[2]string]*group{}
+	for _, message := range messages {
+		owner, room, seq := mysqlMessageKey(message)
+		g, ok := index[[2]string{owner, room}]
+		if !ok {
+			g = &group{owner: owner, room: room, minSeq: seq, maxSeq: seq}
+			index[[2]string{owner, room}] = g
+			groups = append(groups, g)
+		}
+		if seq < g.minSeq {
+			g.minSeq = seq
+		}
+		if seq > g.maxSeq {
+			g.maxSeq = seq
+		}
+		g.messages = append(g.messages, message)
+	}
+
+	for _, g := range groups {
+		reactions, err := s.queryReactions(s.db, g.owner, g.room, g.minSeq, g.maxSeq)
+		if err != nil {
+			return err
+		}
+		for _, message := range g.messages {
+			_, _, seq := mysqlMessageKey(message)
+			message.Reactions = reactions[seq]
+		}
+	}
+	return nil
+}
+
+type mysqlRowsQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryReactions 返回序号在 [minSeq, maxSeq] 之间的消息的回应，按表情聚合
+func (s *MysqlChatStorage) queryReactions(q mysqlRowsQueryer, owner string, room string, minSeq int64, maxSeq int64) (map[int64][]*Reaction, error) {
+	rows, err := q.Query(
+		"SELECT `seq`, `emoji`, `username` FROM `chat_message_reaction` "+
+			"WHERE `owner` = ? AND `room` = ? AND `seq` BETWEEN ? AND ? ORDER BY `seq`, `id`",
+		owner, room, minSeq, maxSeq,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Query failed")
+	}
+	defer rows.Close()
+
+	res := map[int64][]*Reaction{}
+	for rows.Next() {
+		var seq int64
+		var emoji, username string
+		if err := rows.Scan(&seq, &emoji, &username); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan failed")
+		}
+		var reaction *Reaction
+		for _, r := range res[seq] {
+			if r.Emoji == emoji {
+				reaction = r
+			}
+		}
+		if reaction == nil {
+			reaction = &Reaction{Emoji: emoji}
+			res[seq] = append(res[seq], reaction)
+		}
+		reaction.Users = append(reaction.Users, username)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return res, nil
+}
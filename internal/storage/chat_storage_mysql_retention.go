@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// mysqlExpireBatchSize 每个事务最多删除的消息数，避免长事务
+const mysqlExpireBatchSize = 500
+
+// mysqlExpiredMessage 私聊时 conversation 不为空，房间时 room 不为空
+type mysqlExpiredMessage struct {
+	conversation string
+	room         string
+	seq          int64
+	from         string
+	to           string
+}
+
+func (s *MysqlChatStorage) startJanitor() {
+	if s.options.Retention.Interval <= 0 {
+		s.options.Retention.Interval = 10 * time.Second
+	}
+	s.wg.Add(1)
+	go s.janitorLoop()
+}
+
+func (s *MysqlChatStorage) janitorLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.options.Retention.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			// 失败了下次重试即可
+			_ = s.expireMessages(now)
+		}
+	}
+}
+
+// expireMessages 分批删除过期的消息，直到没有过期的消息
+func (s *MysqlChatStorage) expireMessages(now time.Time) error {
+	for {
+		messages, err := s.expiredMessages(now)
+		if err != nil {
+			return errors.WithMessage(err, "expiredMessages failed")
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		if err := s.deleteMessages(messages); err != nil {
+			return errors.WithMessage(err, "deleteMessages failed")
+		}
+		if len(messages) < mysqlExpireBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *MysqlChatStorage) expiredMessages(now time.Time) ([]*mysqlExpiredMessage, error) {
+	retention := &s.options.Retention
+	var messages []*mysqlExpiredMessage
+	query := func(sql string, args ...interface{}) error {
+		if len(messages) >= mysqlExpireBatchSize {
+			return nil
+		}
+		rows, err := s.db.Query(sql+mysqlLimit(mysqlExpireBatchSize-len(messages)), args...)
+		if err != nil {
+			return errors.Wrap(err, "db.Query failed")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var message mysqlExpiredMessage
+			if err := rows.Scan(&message.conversation, &message.room, &message.seq, &message.from, &message.to); err != nil {
+				return errors.Wrap(err, "rows.Scan failed")
+			}
+			messages = append(messages, &message)
+		}
+		return errors.Wrap(rows.Err(), "rows.Err")
+	}
+
+	const directColumns = "SELECT `conversation`, '', `seq`, `from`, `to` FROM "
+	const roomColumns = "SELECT '', `room`, `seq`, `from`, '' FROM "
+	if err := query(directColumns+"`chat_conversation_message` WHERE `expires_at` <= ?", now); err != nil {
+		return nil, err
+	}
+	if err := query(roomColumns+"`chat_room_message` WHERE `expires_at` <= ?", now); err != nil {
+		return nil, err
+	}
+
+	// 私聊使用默认策略
+	if err := s.queryRetention(query, directColumns, "`chat_conversation_message`", "`conversation`", "", nil, retention.policy(""), now); err != nil {
+		return nil, err
+	}
+	// 房间有单独的策略时使用单独的策略，其他房间使用默认策略
+	var rooms []interface{}
+	for room, policy := range retention.Rooms {
+		rooms = append(rooms, room)
+		if err := s.queryRetention(query, roomColumns, "`chat_room_message`", "`room`", "`room` = ?", []interface{}{room}, policy, now); err != nil {
+			return nil, err
+		}
+	}
+	where := ""
+	if len(rooms) != 0 {
+		where = "`room` NOT IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(rooms)), ", ") + ")"
+	}
+	if err := s.queryRetention(query, roomColumns, "`chat_room_message`", "`room`", where, rooms, retention.policy(""), now); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// queryRetention 查找超过保留时间和保留条数的消息，partition 是会话的列，where 不为空时只查找部分会话
+func (s *MysqlChatStorage) queryRetention(
+	query func(sql string, args ...interface{}) error, columns string, table string, partition string,
+	where string, args []interface{}, policy *RetentionPolicy, now time.Time,
+) error {
+	if policy.MaxAge > 0 {
+		sql := columns + table + " WHERE `timestamp` < ?"
+		if where != "" {
+			sql += " AND " + where
+		}
+		if err := query(sql, append([]interface{}{now.Add(-policy.MaxAge)}, args...)...); err != nil {
+			return err
+		}
+	}
+	if policy.MaxCount > 0 {
+		sql := "SELECT t.*, ROW_NUMBER() OVER (PARTITION BY " + partition + " ORDER BY `seq` DESC) AS `n` FROM " + table + " t"
+		if where != "" {
+			sql += " WHERE " + where
+		}
+		if err := query(columns+"("+sql+") m WHERE m.`n` > ?", append(args, policy.MaxCount)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteMessages 在一个事务中删除消息和它们的历史版本、表情回应、索引
+func (s *MysqlChatStorage) deleteMessages(messages []*mysqlExpiredMessage) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	for _, message := range messages {
+		// 历史版本和表情回应的 key 见 mysqlMessageKey
+		stmts := []string{
+			"DELETE FROM `chat_message_revision` WHERE `owner` = ? AND `room` = ? AND `seq` = ?",
+			"DELETE FROM `chat_message_reaction` WHERE `owner` = ? AND `room` = ? AND `seq` = ?",
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt, message.conversation, message.room, message.seq); err != nil {
+				return errors.Wrap(err, "tx.Exec failed")
+			}
+		}
+		if message.room != "" {
+			if _, err := tx.Exec("DELETE FROM `chat_room_hidden_message` WHERE `room` = ? AND `seq` = ?", message.room, message.seq); err != nil {
+				return errors.Wrap(err, "tx.Exec failed")
+			}
+			if _, err := tx.Exec("DELETE FROM `chat_room_message` WHERE `room` = ? AND `seq` = ?", message.room, message.seq); err != nil {
+				return errors.Wrap(err, "tx.Exec failed")
+			}
+			continue
+		}
+		if _, err := tx.Exec(
+			"DELETE FROM `chat_user_message` WHERE `owner` IN (?, ?) AND `conversation` = ? AND `conversation_seq` = ?",
+			message.from, message.to, message.conversation, message.seq,
+		); err != nil {
+			return errors.Wrap(err, "tx.Exec failed")
+		}
+		if _, err := tx.Exec(
+			"DELETE FROM `chat_conversation_message` WHERE `conversation` = ? AND `seq` = ?", message.conversation, message.seq,
+		); err != nil {
+			return errors.Wrap(err, "tx.Exec failed")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "tx.Commit failed")
+	}
+	return nil
+}
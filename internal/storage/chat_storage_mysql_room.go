@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+const mysqlErrDupEntry = 1062
+
+func (s *MysqlChatStorage) CreateRoom(room string, owner string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.Exec("INSERT INTO `chat_room` (`name`, `owner`, `created_at`) VALUES (?, ?, ?)", room, owner, now); err != nil {
+		if e, ok := err.(*mysql.MySQLError); ok && e.Number == mysqlErrDupEntry {
+			return ErrRoomExists
+		}
+		return errors.Wrap(err, "tx.Exec failed")
+	}
+	if _, err := tx.Exec("INSERT INTO `chat_room_member` (`room`, `username`, `joined_at`) VALUES (?, ?, ?)", room, owner, now); err != nil {
+		return errors.Wrap(err, "tx.Exec failed")
+	}
+
+	return errors.Wrap(tx.Commit(), "tx.Commit failed")
+}
+
+func (s *MysqlChatStorage) JoinRoom(room string, username string) error {
+	if err := s.checkRoom(s.db, room); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(
+		"INSERT IGNORE INTO `chat_room_member` (`room`, `username`, `joined_at`) VALUES (?, ?, ?)",
+		room, username, time.Now(),
+	); err != nil {
+		return errors.Wrap(err, "db.Exec failed")
+	}
+	return nil
+}
+
+func (s *MysqlChatStorage) LeaveRoom(room string, username string) error {
+	if err := s.checkRoom(s.db, room); err != nil {
+		return err
+	}
+	res, err := s.db.Exec("DELETE FROM `chat_room_member` WHERE `room` = ? AND `username` = ?", room, username)
+	if err != nil {
+		return errors.Wrap(err, "db.Exec failed")
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.Wrap(err, "res.RowsAffected failed")
+	} else if n == 0 {
+		return ErrNotRoomMember
+	}
+	return nil
+}
+
+func (s *MysqlChatStorage) GetRoomMembers(room string) ([]string, error) {
+	if err := s.checkRoom(s.db, room); err != nil {
+		return nil, err
+	}
+	return s.queryStrings("SELECT `username` FROM `chat_room_member` WHERE `room` = ? ORDER BY `username`", room)
+}
+
+func (s *MysqlChatStorage) GetRoomsByUser(username string) ([]string, error) {
+	return s.queryStrings("SELECT `room` FROM `chat_room_member` WHERE `username` = ? ORDER BY `room`", username)
+}
+
+func (s *MysqlChatStorage) PutRoomMessage(msgID string, room string, from string, content string, replyTo int64, ttl time.Duration) (*ChatMessage, error) {
+	if msgID != "" {
+		message, err := s.getRoomMessageByMsgID(msgID, room, from)
+		if err != nil {
+			return nil, errors.WithMessage(err, "getRoomMessageByMsgID failed")
+		}
+		if message != nil {
+			return message, ErrDuplicateMessage
+		}
+	}
+
+	message, err := s.putRoomMessage(msgID, room, from, content, replyTo, ttl)
+	// 同一条消息并发重试，另一个请求已经写入
+	if e, ok := errors.Cause(err).(*mysql.MySQLError); ok && e.Number == mysqlErrDupEntry && msgID != "" {
+		message, err := s.getRoomMessageByMsgID(msgID, room, from)
+		if err != nil {
+			return nil, errors.WithMessage(err, "getRoomMessageByMsgID failed")
+		}
+		return message, ErrDuplicateMessage
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attachParents("", room, []*ChatMessage{message}); err != nil {
+		return nil, errors.WithMessage(err, "attachParents failed")
+	}
+	return message, nil
+}
+
+func (s *MysqlChatStorage) putRoomMessage(msgID string, room string, from string, content string, replyTo int64, ttl time.Duration) (*ChatMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Begin failed")
+	}
+	defer tx.Rollback()
+
+	var member int
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM `chat_room_member` WHERE `room` = ? AND `username` = ?", room, from,
+	).Scan(&member); err != nil {
+		return nil, errors.Wrap(err, "tx.QueryRow failed")
+	}
+	if member == 0 {
+		if err := s.checkRoom(tx, room); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotRoomMember
+	}
+	if replyTo != 0 {
+		var parent int
+		if err := tx.QueryRow(
+			"SELECT COUNT(*) FROM `chat_room_message` WHERE `room` = ? AND `seq` = ? AND `deleted_at` IS NULL", room, replyTo,
+		).Scan(&parent); err != nil {
+			return nil, errors.Wrap(err, "tx.QueryRow failed")
+		}
+		if parent == 0 {
+			return nil, ErrMessageNotFound
+		}
+	}
+
+	// 更新会锁住房间行，同一房间的消息序号串行分配
+	if _, err := tx.Exec("UPDATE `chat_room` SET `seq` = `seq` + 1 WHERE `name` = ?", room); err != nil {
+		return nil, errors.Wrap(err, "tx.Exec failed")
+	}
+	now := time.Now()
+	message := &ChatMessage{Timestamp: now, MsgID: msgID, From: from, Room: room, Content: content, ReplyTo: replyTo, ExpiresAt: expiresAt(now, ttl)}
+	if err := tx.QueryRow("SELECT `seq` FROM `chat_room` WHERE `name` = ?", room).Scan(&message.Seq); err != nil {
+		return nil, errors.Wrap(err, "tx.QueryRow failed")
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO `chat_room_message` (`room`, `seq`, `timestamp`, `msg_id`, `from`, `content`, `reply_to`, `expires_at`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		room, message.Seq, message.Timestamp, nullString(msgID), from, content, replyTo, nullTime(message.ExpiresAt),
+	); err != nil {
+		return nil, errors.Wrap(err, "tx.Exec failed")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "tx.Commit failed")
+	}
+	return message, nil
+}
+
+func (s *MysqlChatStorage) GetMessageByRoom(room string, username string, seq int64) ([]*ChatMessage, error) {
+	if err := s.checkRoom(s.db, room); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(
+		"SELECT "+mysqlRoomMessageColumns+" FROM `chat_room_message` m WHERE `room` = ? AND `seq` >= ? AND NOT EXISTS ("+
+			"SELECT 1 FROM `chat_room_hidden_message` h WHERE h.`room` = m.`room` AND h.`username` = ? AND h.`seq` = m.`seq`"+
+			") ORDER BY `seq`",
+		room, seq, username,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Query failed")
+	}
+	defer rows.Close()
+
+	var messages []*ChatMessage
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, errors.WithMessage(err, "scanMessage failed")
+		}
+		message.Room = room
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+
+	if err := s.attachReactions(messages); err != nil {
+		return nil, errors.WithMessage(err, "attachReactions failed")
+	}
+	if err := s.attachParents("", room, messages); err != nil {
+		return nil, errors.WithMessage(err, "attachParents failed")
+	}
+	return messages, nil
+}
+
+// getRoomMessageByMsgID 没有时返回 nil
+func (s *MysqlChatStorage) getRoomMessageByMsgID(msgID string, room string, from string) (*ChatMessage, error) {
+	message := &ChatMessage{MsgID: msgID, From: from, Room: room}
+	err := s.db.QueryRow(
+		"SELECT `seq`, `timestamp`, `content` FROM `chat_room_message` WHERE `room` = ? AND `from` = ? AND `msg_id` = ?",
+		room, from, msgID,
+	).Scan(&message.Seq, &message.Timestamp, &message.Content)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "db.QueryRow failed")
+	}
+	return message, nil
+}
+
+type mysqlQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *MysqlChatStorage) checkRoom(q mysqlQueryer, room string) error {
+	var name string
+	err := q.QueryRow("SELECT `name` FROM `chat_room` WHERE `name` = ?", room).Scan(&name)
+	if err == sql.ErrNoRows {
+		return ErrRoomNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "QueryRow failed")
+	}
+	return nil
+}
+
+// checkRoomMember 不是成员时区分房间不存在和不是成员
+func (s *MysqlChatStorage) checkRoomMember(room string, username string) error {
+	var member int
+	if err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM `chat_room_member` WHERE `room` = ? AND `username` = ?", room, username,
+	).Scan(&member); err != nil {
+		return errors.Wrap(err, "db.QueryRow failed")
+	}
+	if member == 0 {
+		if err := s.checkRoom(s.db, room); err != nil {
+			return err
+		}
+		return ErrNotRoomMember
+	}
+	return nil
+}
+
+func (s *MysqlChatStorage) queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Query failed")
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan failed")
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return values, nil
+}
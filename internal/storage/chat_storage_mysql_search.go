@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// mysqlNgramTokenSize 和服务端 ngram_token_size 的默认值一致，比它短的词全文索引匹配不到，改用 LIKE
+const mysqlNgramTokenSize = 2
+
+func (s *MysqlChatStorage) SearchMessages(username string, query *SearchQuery) ([]*ChatMessage, error) {
+	if match, _ := mysqlSearchCondition(query, ""); match == "" {
+		return nil, nil
+	}
+
+	messages, err := s.searchDirectMessages(username, query)
+	if err != nil {
+		return nil, errors.WithMessage(err, "searchDirectMessages failed")
+	}
+	roomMessages, err := s.searchRoomMessages(username, query)
+	if err != nil {
+		return nil, errors.WithMessage(err, "searchRoomMessages failed")
+	}
+
+	messages = append(messages, roomMessages...)
+	sort.Slice(messages, func(i, j int) bool {
+		if !messages[i].Timestamp.Equal(messages[j].Timestamp) {
+			return messages[i].Timestamp.After(messages[j].Timestamp)
+		}
+		if messages[i].Room != messages[j].Room {
+			return messages[i].Room < messages[j].Room
+		}
+		return messages[i].Seq > messages[j].Seq
+	})
+	if query.Limit > 0 && len(messages) > query.Limit {
+		messages = messages[:query.Limit]
+	}
+	return messages, nil
+}
+
+func (s *MysqlChatStorage) searchDirectMessages(username string, query *SearchQuery) ([]*ChatMessage, error) {
+	match, matchArgs := mysqlSearchCondition(query, "c.")
+	where, args := mysqlSearchFilter(query, "c.")
+	messages, err := s.queryMessages(
+		"SELECT "+mysqlMessageColumns+" FROM "+mysqlMessageTables+
+			" WHERE u.`owner` = ? AND u.`hidden` = 0 AND c.`deleted_at` IS NULL AND "+match+where+
+			" ORDER BY c.`timestamp` DESC"+mysqlLimit(query.Limit),
+		append(append([]interface{}{username}, matchArgs...), args...)...,
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "queryMessages failed")
+	}
+
+	if err := s.attachReactions(messages); err != nil {
+		return nil, errors.WithMessage(err, "attachReactions failed")
+	}
+	if err := s.attachParents(username, "", messages); err != nil {
+		return nil, errors.WithMessage(err, "attachParents failed")
+	}
+	return messages, nil
+}
+
+func (s *MysqlChatStorage) searchRoomMessages(username string, query *SearchQuery) ([]*ChatMessage, error) {
+	match, matchArgs := mysqlSearchCondition(query, "m.")
+	where, args := mysqlSearchFilter(query, "m.")
+	rows, err := s.db.Query(
+		"SELECT m.`room`, "+mysqlRoomMessageColumns+" FROM `chat_room_message` m "+
+			"JOIN `chat_room_member` rm ON rm.`room` = m.`room` AND rm.`username` = ? "+
+			"WHERE m.`deleted_at` IS NULL AND "+match+where+" AND NOT EXISTS ("+
+			"SELECT 1 FROM `chat_room_hidden_message` h WHERE h.`room` = m.`room` AND h.`username` = rm.`username` AND h.`seq` = m.`seq`"+
+			") ORDER BY m.`timestamp` DESC"+mysqlLimit(query.Limit),
+		append(append([]interface{}{username}, matchArgs...), args...)...,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Query failed")
+	}
+	defer rows.Close()
+
+	var messages []*ChatMessage
+	rooms := map[string][]*ChatMessage{}
+	for rows.Next() {
+		var room string
+		message, err := scanMessage(mysqlScannerFunc(func(dest ...interface{}) error {
+			return rows.Scan(append([]interface{}{&room}, dest...)...)
+		}))
+		if err != nil {
+			return nil, errors.WithMessage(err, "scanMessage failed")
+		}
+		message.Room = room
+		messages = append(messages, message)
+		rooms[room] = append(rooms[room], message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+
+	if err := s.attachReactions(messages); err != nil {
+		return nil, errors.WithMessage(err, "attachReactions failed")
+	}
+	for room, list := range rooms {
+		if err := s.attachParents("", room, list); err != nil {
+			return nil, errors.WithMessage(err, "attachParents failed")
+		}
+	}
+	return messages, nil
+}
+
+// mysqlSearchCondition 关键词按空白分成多个词，都要出现。每个词作为短语在全文索引中匹配，prefix 是消息表的别名前缀
+func mysqlSearchCondition(query *SearchQuery, prefix string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	var phrases []string
+	for _, word := range strings.Fields(query.Keyword) {
+		// 布尔模式中双引号是短语的边界，词里的去掉
+		word = strings.ReplaceAll(word, `"`, "")
+		if word == "" {
+			continue
+		}
+		if utf8.RuneCountInString(word) < mysqlNgramTokenSize {
+			conditions = append(conditions, prefix+"`content` LIKE ?")
+			args = append(args, "%"+mysqlEscapeLike(word)+"%")
+			continue
+		}
+		phrases = append(phrases, `+"`+word+`"`)
+	}
+	if len(phrases) != 0 {
+		conditions = append([]string{"MATCH(" + prefix + "`content`) AGAINST(? IN BOOLEAN MODE)"}, conditions...)
+		args = append([]interface{}{strings.Join(phrases, " ")}, args...)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// mysqlSearchFilter 发送方和时间范围
+func mysqlSearchFilter(query *SearchQuery, prefix string) (string, []interface{}) {
+	var where string
+	var args []interface{}
+	if query.From != "" {
+		where += " AND " + prefix + "`from` = ?"
+		args = append(args, query.From)
+	}
+	if !query.Since.IsZero() {
+		where += " AND " + prefix + "`timestamp` >= ?"
+		args = append(args, query.Since)
+	}
+	if !query.Until.IsZero() {
+		where += " AND " + prefix + "`timestamp` < ?"
+		args = append(args, query.Until)
+	}
+	return where, args
+}
+
+func mysqlEscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,246 @@
+//go:build mysql
+
+// 在真实的 MySQL 上测试，需要 MySQL 8.0 及以上。make test-mysql 用 docker 启动一个临时的实例并运行
+// 也可以手动指定：CHAT_TEST_MYSQL_ADDRESS=127.0.0.1:3306 go test -tags mysql ./internal/storage/
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+// newTestMysqlChatStorage 每个测试使用一个新建的库，结束时删除
+func newTestMysqlChatStorage(t *testing.T) *MysqlChatStorage {
+	t.Helper()
+	address := os.Getenv("CHAT_TEST_MYSQL_ADDRESS")
+	if address == "" {
+		t.Skip("CHAT_TEST_MYSQL_ADDRESS is not set")
+	}
+	options := &MysqlChatStorageOptions{
+		Username:        "root",
+		Password:        os.Getenv("CHAT_TEST_MYSQL_PASSWORD"),
+		Address:         address,
+		MaxOpenConns:    20,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: 60 * time.Second,
+	}
+	if username := os.Getenv("CHAT_TEST_MYSQL_USERNAME"); username != "" {
+		options.Username = username
+	}
+
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+	options.Database = "chat_test_" + hex.EncodeToString(buf)
+
+	cfg := mysql.NewConfig()
+	cfg.User = options.Username
+	cfg.Passwd = options.Password
+	cfg.Net = "tcp"
+	cfg.Addr = options.Address
+	root, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	t.Cleanup(func() { _ = root.Close() })
+	if _, err := root.Exec("CREATE DATABASE `" + options.Database + "` DEFAULT CHARSET utf8mb4"); err != nil {
+		t.Fatalf("create database failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := root.Exec("DROP DATABASE `" + options.Database + "`"); err != nil {
+			t.Errorf("drop database failed: %v", err)
+		}
+	})
+
+	s, err := NewMysqlChatStorageWithOptions(options)
+	if err != nil {
+		t.Fatalf("NewMysqlChatStorageWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	})
+	return s
+}
+
+func TestMysqlChatStoragePutMessage(t *testing.T) {
+	s := newTestMysqlChatStorage(t)
+
+	for _, m := range []struct {
+		from     string
+		to       string
+		content  string
+		wantFrom int64
+		// 接收方信箱中的序号，自己发给自己时和发送方相同
+		wantTo int64
+	}{
+		{"alice", "bob", "hello bob", 1, 1},
+		{"bob", "alice", "hello alice", 2, 2},
+		{"alice", "alice", "note to self", 3, 3},
+		{"carol", "bob", "hello from carol", 1, 3},
+	} {
+		fromMessage, toMessage, err := s.PutMessage("", m.from, m.to, m.content, 0, 0)
+		if err != nil {
+			t.Fatalf("PutMessage failed: %v", err)
+		}
+		if fromMessage.Seq != m.wantFrom || toMessage.Seq != m.wantTo {
+			t.Fatalf("PutMessage %s -> %s seq = %d, %d, want %d, %d", m.from, m.to, fromMessage.Seq, toMessage.Seq, m.wantFrom, m.wantTo)
+		}
+	}
+
+	// chat_user_seq 记录每个信箱分配到的最大序号
+	for username, want := range map[string]int64{"alice": 3, "bob": 3, "carol": 1} {
+		var seq int64
+		if err := s.db.QueryRow("SELECT `seq` FROM `chat_user_seq` WHERE `username` = ?", username).Scan(&seq); err != nil {
+			t.Fatalf("query chat_user_seq failed: %v", err)
+		}
+		if seq != want {
+			t.Fatalf("chat_user_seq of %s = %d, want %d", username, seq, want)
+		}
+	}
+
+	for _, c := range []struct {
+		username string
+		seq      int64
+		want     []string
+	}{
+		{"alice", 0, []string{"hello bob", "hello alice", "note to self"}},
+		// 自己发给自己的消息只出现一次
+		{"alice", 3, []string{"note to self"}},
+		{"bob", 2, []string{"hello alice", "hello from carol"}},
+		{"bob", 4, nil},
+		{"dave", 0, nil},
+	} {
+		messages, err := s.GetMessageByUser(c.username, c.seq)
+		if err != nil {
+			t.Fatalf("GetMessageByUser failed: %v", err)
+		}
+		if len(messages) != len(c.want) {
+			t.Fatalf("GetMessageByUser(%s, %d) = %d messages, want %d", c.username, c.seq, len(messages), len(c.want))
+		}
+		for i, message := range messages {
+			if message.Content != c.want[i] || message.Seq < c.seq {
+				t.Fatalf("GetMessageByUser(%s, %d)[%d] = %+v, want %s", c.username, c.seq, i, message, c.want[i])
+			}
+			if i > 0 && message.Seq <= messages[i-1].Seq {
+				t.Fatalf("GetMessageByUser(%s, %d) not ordered by seq", c.username, c.seq)
+			}
+		}
+	}
+}
+
+func TestMysqlChatStoragePutMessageConcurrent(t *testing.T) {
+	s := newTestMysqlChatStorage(t)
+
+	// 先建好会话和 bob 的信箱，之后不同的发送方同时发给 bob，bob 的信箱序号连续且不重复
+	const senders = 4
+	for i := 0; i < senders; i++ {
+		if _, _, err := s.PutMessage("", fmt.Sprintf("user%d", i), "bob", "hello", 0, 0); err != nil {
+			t.Fatalf("PutMessage failed: %v", err)
+		}
+	}
+	const n = 16
+	seqs := make(chan int64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, toMessage, err := s.PutMessage("", fmt.Sprintf("user%d", i%senders), "bob", "hello", 0, 0)
+			if err != nil {
+				t.Errorf("PutMessage failed: %v", err)
+				return
+			}
+			seqs <- toMessage.Seq
+		}(i)
+	}
+	wg.Wait()
+	close(seqs)
+
+	seen := map[int64]bool{}
+	for seq := range seqs {
+		if seq <= senders || seq > senders+n || seen[seq] {
+			t.Fatalf("seq %d duplicated or out of range", seq)
+		}
+		seen[seq] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("allocated %d seqs, want %d", len(seen), n)
+	}
+}
+
+func TestMysqlChatStoragePutMessageDedupe(t *testing.T) {
+	s := newTestMysqlChatStorage(t)
+
+	first, _, err := s.PutMessage("m1", "alice", "bob", "hello", 0, 0)
+	if err != nil {
+		t.Fatalf("PutMessage failed: %v", err)
+	}
+
+	// 重试返回之前保存的消息
+	fromMessage, toMessage, err := s.PutMessage("m1", "alice", "bob", "hello again", 0, 0)
+	if err != ErrDuplicateMessage {
+		t.Fatalf("PutMessage retry error = %v, want ErrDuplicateMessage", err)
+	}
+	if fromMessage.Seq != first.Seq || fromMessage.Content != "hello" || toMessage == nil || toMessage.Seq != 1 {
+		t.Fatalf("PutMessage retry = %+v, %+v, want the first message", fromMessage, toMessage)
+	}
+
+	// msgID 只在同一个发送方的会话中去重
+	if _, _, err := s.PutMessage("m1", "bob", "alice", "hello", 0, 0); err != nil {
+		t.Fatalf("PutMessage from other sender failed: %v", err)
+	}
+	if _, _, err := s.PutMessage("m1", "alice", "carol", "hello", 0, 0); err != nil {
+		t.Fatalf("PutMessage to other peer failed: %v", err)
+	}
+
+	// 并发重试时由唯一键兜底，只有一次写入
+	const n = 8
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := s.PutMessage("m2", "alice", "bob", "concurrent", 0, 0)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var stored int
+	for err := range errs {
+		switch {
+		case err == nil:
+			stored++
+		case errors.Cause(err) != ErrDuplicateMessage:
+			t.Fatalf("PutMessage error = %v, want ErrDuplicateMessage", err)
+		}
+	}
+	if stored != 1 {
+		t.Fatalf("stored %d times, want 1", stored)
+	}
+
+	messages, err := s.GetMessageByUser("bob", 0)
+	if err != nil {
+		t.Fatalf("GetMessageByUser failed: %v", err)
+	}
+	var contents []string
+	for _, message := range messages {
+		contents = append(contents, message.Content)
+	}
+	if fmt.Sprint(contents) != fmt.Sprint([]string{"hello", "hello", "concurrent"}) {
+		t.Fatalf("bob messages = %v, want [hello hello concurrent]", contents)
+	}
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+func (s *MysqlChatStorage) GetThread(username string, room string, seq int64) (*ChatMessage, []*ChatMessage, error) {
+	var parent *ChatMessage
+	var replies []*ChatMessage
+	var err error
+	if room != "" {
+		parent, replies, err = s.getRoomThread(username, room, seq)
+	} else {
+		parent, replies, err = s.getThread(username, seq)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	owner := ""
+	if room == "" {
+		owner = username
+	}
+	for _, message := range replies {
+		message.Parent = parent
+	}
+	if err := s.attachReactions(append([]*ChatMessage{parent}, replies...)); err != nil {
+		return nil, nil, errors.WithMessage(err, "attachReactions failed")
+	}
+	if err := s.attachParents(owner, room, []*ChatMessage{parent}); err != nil {
+		return nil, nil, errors.WithMessage(err, "attachParents failed")
+	}
+	return parent, replies, nil
+}
+
+func (s *MysqlChatStorage) getRoomThread(username string, room string, seq int64) (*ChatMessage, []*ChatMessage, error) {
+	if err := s.checkRoomMember(room, username); err != nil {
+		return nil, nil, err
+	}
+
+	notHidden := " AND NOT EXISTS (" +
+		"SELECT 1 FROM `chat_room_hidden_message` h WHERE h.`room` = m.`room` AND h.`username` = ? AND h.`seq` = m.`seq`)"
+	parent, err := scanMessage(s.db.QueryRow(
+		"SELECT "+mysqlRoomMessageColumns+" FROM `chat_room_message` m WHERE `room` = ? AND `seq` = ?"+notHidden, room, seq, username,
+	))
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "scanMessage failed")
+	}
+	parent.Room = room
+
+	replies, err := s.queryMessages(
+		"SELECT "+mysqlRoomMessageColumns+" FROM `chat_room_message` m WHERE `room` = ? AND `reply_to` = ?"+notHidden+" ORDER BY `seq`",
+		room, seq, username,
+	)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "queryMessages failed")
+	}
+	for _, message := range replies {
+		message.Room = room
+	}
+	return parent, replies, nil
+}
+
+// getThread 私聊时回复在同一个会话中，按会话中的序号查找
+func (s *MysqlChatStorage) getThread(username string, seq int64) (*ChatMessage, []*ChatMessage, error) {
+	parent, err := scanMessage(s.db.QueryRow(
+		"SELECT "+mysqlMessageColumns+" FROM "+mysqlMessageTables+" WHERE u.`owner` = ? AND u.`seq` = ? AND u.`hidden` = 0",
+		username, seq,
+	))
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "scanMessage failed")
+	}
+
+	replies, err := s.queryMessages(
+		"SELECT "+mysqlMessageColumns+" FROM "+mysqlMessageTables+
+			" WHERE u.`owner` = ? AND u.`conversation` = ? AND c.`reply_to` = ? AND u.`hidden` = 0 ORDER BY u.`seq`",
+		username, conversationID(parent), parent.ConversationSeq,
+	)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "queryMessages failed")
+	}
+	return parent, replies, nil
+}
+
+// attachParents 读出一批消息回复的消息，私聊时 owner 是读者，ReplyTo 是读者信箱的序号
+func (s *MysqlChatStorage) attachParents(owner string, room string, messages []*ChatMessage) error {
+	var seqs []interface{}
+	for _, message := range messages {
+		if message.ReplyTo != 0 {
+			seqs = append(seqs, message.ReplyTo)
+		}
+	}
+	if len(seqs) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(seqs)), ", ")
+	query := "SELECT " + mysqlMessageColumns + " FROM " + mysqlMessageTables + " WHERE u.`owner` = ? AND u.`seq` IN (" + placeholders + ")"
+	key := owner
+	if room != "" {
+		query = "SELECT " + mysqlRoomMessageColumns + " FROM `chat_room_message` WHERE `room` = ? AND `seq` IN (" + placeholders + ")"
+		key = room
+	}
+	list, err := s.queryMessages(query, append([]interface{}{key}, seqs...)...)
+	if err != nil {
+		return errors.WithMessage(err, "queryMessages failed")
+	}
+
+	parents := map[int64]*ChatMessage{}
+	for _, parent := range list {
+		parent.Room = room
+		parents[parent.Seq] = parent
+	}
+	for _, message := range messages {
+		if message.ReplyTo != 0 {
+			message.Parent = parents[message.ReplyTo]
+		}
+	}
+	return nil
+}
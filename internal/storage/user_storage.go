@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type User struct {
+	Username string
+	// bcrypt 哈希，已经包含了盐
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+type UserStorage interface {
+	// PutUser 添加新用户，用户已存在时返回 ErrUserExists
+	PutUser(user *User) error
+	// GetUser 用户不存在时返回 ErrUserNotFound
+	GetUser(username string) (*User, error)
+	// RevokeToken 记录注销的 token，expiresAt 之后 token 自然失效，记录可以清理
+	// 已经注销过时返回 ErrTokenRevoked，多个节点同时注销同一个 token 时只有一个成功
+	RevokeToken(id string, expiresAt time.Time) error
+	IsTokenRevoked(id string) (bool, error)
+	Close() error
+}
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user exists")
+	ErrTokenRevoked = errors.New("token revoked")
+)
+
+type UserStorageOptions struct {
+	Type  string `dft:"Local"`
+	Local LocalUserStorageOptions
+	Mysql MysqlChatStorageOptions
+}
+
+func NewUserStorageWithOptions(options *UserStorageOptions) (UserStorage, error) {
+	switch options.Type {
+	case "", "Local":
+		s, err := NewLocalUserStorageWithOptions(&options.Local)
+		if err != nil {
+			return nil, errors.WithMessage(err, "NewLocalUserStorageWithOptions failed")
+		}
+		return s, nil
+	case "Mysql":
+		s, err := NewMysqlUserStorageWithOptions(&options.Mysql)
+		if err != nil {
+			return nil, errors.WithMessage(err, "NewMysqlUserStorageWithOptions failed")
+		}
+		return s, nil
+	}
+	return nil, errors.Errorf("unsupported user storage type [%s]", options.Type)
+}
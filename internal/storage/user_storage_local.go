@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	userFileName         = "users.json"
+	revokedTokenFileName = "revoked_tokens.json"
+)
+
+type LocalUserStorageOptions struct {
+	// 数据目录，为空时用户只保存在内存中
+	Directory string
+}
+
+func NewLocalUserStorageWithOptions(options *LocalUserStorageOptions) (*LocalUserStorage, error) {
+	s := &LocalUserStorage{
+		options: options,
+		users:   map[string]*User{},
+		revoked: map[string]time.Time{},
+	}
+
+	if options.Directory == "" {
+		return s, nil
+	}
+
+	if err := s.load(userFileName, &s.users); err != nil {
+		return nil, errors.WithMessagef(err, "load [%s] failed", userFileName)
+	}
+	if err := s.load(revokedTokenFileName, &s.revoked); err != nil {
+		return nil, errors.WithMessagef(err, "load [%s] failed", revokedTokenFileName)
+	}
+
+	return s, nil
+}
+
+type LocalUserStorage struct {
+	options *LocalUserStorageOptions
+
+	users map[string]*User
+	// token id -> 过期时间
+	revoked map[string]time.Time
+	mutex   sync.RWMutex
+}
+
+func (s *LocalUserStorage) PutUser(user *User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.users[user.Username]; ok {
+		return ErrUserExists
+	}
+	s.users[user.Username] = user
+
+	if err := s.save(userFileName, s.users); err != nil {
+		delete(s.users, user.Username)
+		return errors.WithMessage(err, "save failed")
+	}
+	return nil
+}
+
+func (s *LocalUserStorage) GetUser(username string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *LocalUserStorage) RevokeToken(id string, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// 顺便清理已经过期的记录
+	now := time.Now()
+	for k, v := range s.revoked {
+		if v.Before(now) {
+			delete(s.revoked, k)
+		}
+	}
+	if _, ok := s.revoked[id]; ok {
+		return ErrTokenRevoked
+	}
+	s.revoked[id] = expiresAt
+
+	if err := s.save(revokedTokenFileName, s.revoked); err != nil {
+		return errors.WithMessage(err, "save failed")
+	}
+	return nil
+}
+
+func (s *LocalUserStorage) IsTokenRevoked(id string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.revoked[id]
+	return ok, nil
+}
+
+func (s *LocalUserStorage) Close() error {
+	return nil
+}
+
+func (s *LocalUserStorage) load(name string, v interface{}) error {
+	buf, err := os.ReadFile(filepath.Join(s.options.Directory, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "os.ReadFile failed")
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return errors.Wrap(err, "json.Unmarshal failed")
+	}
+	return nil
+}
+
+// save 整个文件重写，注册和注销都不频繁，不需要日志。调用方持有 s.mutex
+func (s *LocalUserStorage) save(name string, v interface{}) error {
+	if s.options.Directory == "" {
+		return nil
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal failed")
+	}
+	if err := os.MkdirAll(s.options.Directory, 0755); err != nil {
+		return errors.Wrap(err, "os.MkdirAll failed")
+	}
+	filename := filepath.Join(s.options.Directory, name)
+	if err := writeFileSync(filename+".tmp", buf); err != nil {
+		return errors.WithMessage(err, "writeFileSync failed")
+	}
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		return errors.Wrap(err, "os.Rename failed")
+	}
+	return syncDir(s.options.Directory)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+func NewMysqlUserStorageWithOptions(options *MysqlChatStorageOptions) (*MysqlUserStorage, error) {
+	db, err := openMysql(options)
+	if err != nil {
+		return nil, errors.WithMessage(err, "openMysql failed")
+	}
+
+	return &MysqlUserStorage{db: db}, nil
+}
+
+type MysqlUserStorage struct {
+	db *sql.DB
+}
+
+func (s *MysqlUserStorage) PutUser(user *User) error {
+	if _, err := s.db.Exec(
+		"INSERT INTO `chat_user` (`username`, `password_hash`, `created_at`) VALUES (?, ?, ?)",
+		user.Username, user.PasswordHash, user.CreatedAt,
+	); err != nil {
+		if e, ok := err.(*mysql.MySQLError); ok && e.Number == mysqlErrDupEntry {
+			return ErrUserExists
+		}
+		return errors.Wrap(err, "db.Exec failed")
+	}
+	return nil
+}
+
+func (s *MysqlUserStorage) GetUser(username string) (*User, error) {
+	var user User
+	err := s.db.QueryRow(
+		"SELECT `username`, `password_hash`, `created_at` FROM `chat_user` WHERE `username` = ?", username,
+	).Scan(&user.Username, &user.PasswordHash, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "db.QueryRow failed")
+	}
+	return &user, nil
+}
+
+func (s *MysqlUserStorage) RevokeToken(id string, expiresAt time.Time) error {
+	// 顺便清理已经过期的记录
+	if _, err := s.db.Exec("DELETE FROM `chat_revoked_token` WHERE `expires_at` < ?", time.Now()); err != nil {
+		return errors.Wrap(err, "db.Exec failed")
+	}
+	result, err := s.db.Exec(
+		"INSERT IGNORE INTO `chat_revoked_token` (`id`, `expires_at`) VALUES (?, ?)", id, expiresAt,
+	)
+	if err != nil {
+		return errors.Wrap(err, "db.Exec failed")
+	}
+	// 主键冲突时没有插入，说明其他节点已经注销过
+	n, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "result.RowsAffected failed")
+	}
+	if n == 0 {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+func (s *MysqlUserStorage) IsTokenRevoked(id string) (bool, error) {
+	var count int
+	if err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM `chat_revoked_token` WHERE `id` = ?", id,
+	).Scan(&count); err != nil {
+		return false, errors.Wrap(err, "db.QueryRow failed")
+	}
+	return count > 0, nil
+}
+
+func (s *MysqlUserStorage) Close() error {
+	return s.db.Close()
+}
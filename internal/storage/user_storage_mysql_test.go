@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMysqlUserStorageRevokeToken(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		// INSERT IGNORE 插入的行数，0 表示已经注销过
+		rowsAffected int64
+		wantErr      error
+	}{
+		{name: "revoke", rowsAffected: 1},
+		{name: "already revoked", rowsAffected: 0, wantErr: ErrTokenRevoked},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New failed: %v", err)
+			}
+			defer db.Close()
+			s := &MysqlUserStorage{db: db}
+
+			expiresAt := time.Now().Add(time.Hour)
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `chat_revoked_token`")).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO `chat_revoked_token`")).
+				WithArgs("t1", expiresAt).
+				WillReturnResult(sqlmock.NewResult(0, c.rowsAffected))
+
+			if err := s.RevokeToken("t1", expiresAt); err != c.wantErr {
+				t.Fatalf("RevokeToken error = %v, want %v", err, c.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("ExpectationsWereMet failed: %v", err)
+			}
+		})
+	}
+}